@@ -0,0 +1,134 @@
+// Command ai-eval scores receipt extraction against a labelled dataset.
+//
+//	go run ./cmd/ai-eval -dir ./testdata/receipts                 # live, QR + Gemini
+//	go run ./cmd/ai-eval -dir ./testdata/receipts -record ./rec   # live, save responses
+//	go run ./cmd/ai-eval -dir ./testdata/receipts -replay ./rec   # offline
+//	go run ./cmd/ai-eval -dir ./testdata/receipts -locale en      # compare prompt locales
+//
+// See internal/aieval for the dataset layout.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"lovelion/internal/aieval"
+	"lovelion/internal/config"
+	"lovelion/internal/services"
+)
+
+func main() {
+	dir := flag.String("dir", "", "dataset directory (required)")
+	extractorName := flag.String("extractor", "chain", "extractor to evaluate: gemini, qr or chain")
+	recordDir := flag.String("record", "", "save every response to this directory")
+	replayDir := flag.String("replay", "", "replay responses from this directory instead of calling any extractor")
+	locale := flag.String("locale", "", "override the prompt locale from hints.json (zh-TW, en, ja)")
+	promptVersion := flag.String("prompt-version", "", "evaluate a specific prompt version instead of the current one")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cases, hints, err := aieval.LoadDataset(*dir)
+	if err != nil {
+		log.Fatalf("load dataset: %v", err)
+	}
+	if len(cases) == 0 {
+		log.Fatalf("no cases found in %s", *dir)
+	}
+
+	if *locale != "" {
+		hints.Locale = *locale
+	}
+	if *promptVersion != "" {
+		hints.PromptVersion = *promptVersion
+	}
+
+	runner := aieval.Runner{Hints: hints}
+	if *replayDir != "" {
+		replayer := &aieval.Replayer{Dir: *replayDir}
+		runner.Image, runner.Text = replayer, replayer
+	} else {
+		image, text, err := buildExtractors(*extractorName, config.Load())
+		if err != nil {
+			log.Fatal(err)
+		}
+		runner.Image, runner.Text = image, text
+		if *recordDir != "" {
+			recorder := &aieval.Recorder{Dir: *recordDir, Image: image, Text: text}
+			runner.Image, runner.Text = recorder, recorder
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := runner.Run(ctx, cases)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printReport(report)
+}
+
+// buildExtractors wires the same extractors main.go uses for the worker.
+func buildExtractors(name string, cfg *config.Config) (services.ReceiptExtractor, services.TextExtractor, error) {
+	qr := services.NewEInvoiceQRExtractor()
+	if name == "qr" {
+		return qr, nil, nil
+	}
+	if cfg.GeminiAPIKey == "" {
+		return nil, nil, fmt.Errorf("GEMINI_API_KEY is required for extractor %q", name)
+	}
+	gemini := services.NewGeminiReceiptExtractor(cfg.GeminiAPIKey, cfg.GeminiModel, cfg.GeminiBaseURL)
+	switch name {
+	case "gemini":
+		return gemini, gemini, nil
+	case "chain":
+		return services.ChainReceiptExtractor{qr, gemini}, gemini, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown extractor %q", name)
+	}
+}
+
+func printReport(r aieval.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tFIELDS\tITEMS\tTOTAL ERR\tTIME\tERROR")
+	for _, c := range r.Cases {
+		correct := 0
+		for _, ok := range c.Fields {
+			if ok {
+				correct++
+			}
+		}
+		totalErr := "-"
+		if c.TotalError != nil {
+			totalErr = c.TotalError.String()
+		}
+		fmt.Fprintf(w, "%s\t%d/%d\t%d/%d\t%s\t%s\t%s\n",
+			c.Name, correct, len(c.Fields), c.ItemsMatched, c.ItemsExpected,
+			totalErr, c.Duration.Round(1e6), c.Error)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%d cases, %d failed\n", len(r.Cases), r.Failed)
+	for _, f := range r.FieldNames() {
+		fmt.Printf("  %-15s %6.1f%%\n", f, r.FieldAccuracy[f]*100)
+	}
+	fmt.Printf("  %-15s %6.1f%%\n", "item recall", r.ItemRecall*100)
+	fmt.Printf("  %-15s %6.1f%%\n", "total exact", r.TotalExact*100)
+	fmt.Printf("  %-15s %s\n", "total MAE", r.TotalMAE.String())
+}
@@ -0,0 +1,92 @@
+// Command image-gc runs one pass of the orphaned image collector that the
+// server also runs on a schedule (see services.ImageGC). By default it only
+// reports; pass -delete to remove orphans older than the grace period.
+//
+//	go run ./cmd/image-gc                      # dry run
+//	go run ./cmd/image-gc -delete              # delete orphans past IMAGE_GC_GRACE_HOURS
+//	go run ./cmd/image-gc -delete -grace 0     # delete everything orphaned right now
+//
+// -grace 0 can also delete objects of uploads that are still in flight, so
+// only use it when nobody is uploading, e.g. right after bin/refresh-database
+// has recreated the database.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"lovelion/internal/config"
+	"lovelion/internal/services"
+	"lovelion/internal/storage"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	cfg := config.Load()
+
+	del := flag.Bool("delete", false, "delete orphans past the grace period (default: dry run)")
+	grace := flag.Duration("grace", cfg.ImageGCGrace, "how long an orphan must have been seen before it is deleted")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	db, err := gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		log.Fatalf("❌ Failed to connect to database: %v", err)
+	}
+	store, err := storage.New(cfg)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize %s storage: %v", cfg.StorageBackend, err)
+	}
+
+	gc := services.NewImageGC(db, store, services.ImageGCConfig{DryRun: !*del}).WithGrace(*grace)
+	report, err := gc.Collect(context.Background())
+	if err != nil {
+		log.Fatalf("❌ Image GC failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	mode := "dry run"
+	if !report.DryRun {
+		mode = "delete"
+	}
+	fmt.Printf("🧹 Image GC (%s, grace %s): %d objects listed, %d orphans\n",
+		mode, report.Grace, report.Objects, len(report.Orphans))
+	for _, o := range report.Orphans {
+		status := "waiting"
+		switch {
+		case o.Deleted:
+			status = "deleted"
+		case o.Due && report.DryRun:
+			status = "would delete"
+		case o.Due:
+			status = "FAILED"
+		}
+		line := fmt.Sprintf("  %-12s %-6s %s", status, o.Kind, o.Ref)
+		if o.Detail != "" {
+			line += " (" + o.Detail + ")"
+		}
+		fmt.Printf("%s  first seen %s\n", line, o.FirstSeen.Format(time.RFC3339))
+	}
+	fmt.Printf("✅ Deleted %d, failed %d, dropped %d expired uploads\n", report.Deleted, report.Failed, report.ExpiredUploads)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
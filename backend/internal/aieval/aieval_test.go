@@ -0,0 +1,159 @@
+package aieval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lovelion/internal/services"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestReplay_RecordedDataset(t *testing.T) {
+	cases, hints, err := LoadDataset("testdata/dataset")
+	require.NoError(t, err)
+	require.Len(t, cases, 2)
+	assert.Equal(t, "dinner", cases[0].Name)
+	assert.True(t, cases[0].IsText())
+	assert.Equal(t, []string{"交通", "飲食"}, hints.Categories)
+
+	replayer := &Replayer{Dir: "testdata/recordings"}
+	report := Runner{Image: replayer, Text: replayer, Hints: hints}.Run(context.Background(), cases)
+
+	assert.Zero(t, report.Failed)
+	assert.InDelta(t, 0.5, report.FieldAccuracy["category"], 1e-9)
+	assert.InDelta(t, 1.0, report.FieldAccuracy["payment_method"], 1e-9)
+	assert.InDelta(t, 1.0, report.FieldAccuracy["currency"], 1e-9)
+	assert.InDelta(t, 0.5, report.ItemRecall, 1e-9)
+	assert.InDelta(t, 0.5, report.TotalExact, 1e-9)
+	assert.True(t, decimal.NewFromInt(100).Equal(report.TotalMAE), "dinner is off by 200 over 2 cases")
+}
+
+// stubExtractor answers every call with the same result.
+type stubExtractor struct {
+	data *services.ReceiptData
+	err  error
+}
+
+func (s stubExtractor) Extract(context.Context, []byte, string, services.ExtractHints) (*services.ReceiptData, error) {
+	return s.data, s.err
+}
+
+func (s stubExtractor) ExtractText(context.Context, string, services.ExtractHints) (*services.ReceiptData, error) {
+	return s.data, s.err
+}
+
+func TestRecorder_RoundTripsThroughReplayer(t *testing.T) {
+	dir := t.TempDir()
+	date := time.Date(2024, 7, 14, 12, 30, 0, 0, time.UTC)
+	live := stubExtractor{data: &services.ReceiptData{
+		Title: "全聯", Date: &date, Currency: "TWD", Tax: decimal.NewFromInt(5),
+		Items: []services.ReceiptItem{{Name: "牛奶", UnitPrice: decimal.NewFromInt(55), Quantity: decimal.NewFromInt(2)}},
+	}}
+	rec := &Recorder{Dir: dir, Image: live, Text: live}
+
+	_, err := rec.Extract(context.Background(), []byte("img"), "image/jpeg", services.ExtractHints{})
+	require.NoError(t, err)
+
+	got, err := (&Replayer{Dir: dir}).Extract(context.Background(), []byte("img"), "image/jpeg", services.ExtractHints{})
+	require.NoError(t, err)
+	assert.Equal(t, "全聯", got.Title)
+	require.NotNil(t, got.Date)
+	assert.True(t, date.Equal(*got.Date))
+	require.Len(t, got.Items, 1)
+	assert.True(t, decimal.NewFromInt(55).Equal(got.Items[0].UnitPrice))
+
+	_, err = (&Replayer{Dir: dir}).ExtractText(context.Background(), "never recorded", services.ExtractHints{})
+	assert.ErrorContains(t, err, "no recording")
+}
+
+func TestRecorder_ReplayReparsesModelOutput(t *testing.T) {
+	dir := t.TempDir()
+	// The parser normalises invoice numbers; a replay must go through it
+	// rather than hand back what was parsed at record time.
+	raw := `{"title": "7-ELEVEN", "invoice_number": "ab-1234 5678", "currency": "twd",
+		"items": [{"name": "拿鐵", "unit_price": 55, "quantity": 0}]}`
+	live := stubExtractor{data: &services.ReceiptData{Title: "stale", Raw: raw, PromptVersion: "zh-TW/v2"}}
+	rec := &Recorder{Dir: dir, Image: live, Text: live}
+	_, err := rec.ExtractText(context.Background(), "7-11 拿鐵 55", services.ExtractHints{})
+	require.NoError(t, err)
+
+	got, err := (&Replayer{Dir: dir}).ExtractText(context.Background(), "7-11 拿鐵 55", services.ExtractHints{})
+	require.NoError(t, err)
+	assert.Equal(t, "7-ELEVEN", got.Title)
+	assert.Equal(t, "AB12345678", got.InvoiceNumber)
+	assert.Equal(t, "TWD", got.Currency)
+	assert.Equal(t, "zh-TW/v2", got.PromptVersion)
+	require.Len(t, got.Items, 1)
+	assert.True(t, decimal.NewFromInt(1).Equal(got.Items[0].Quantity))
+
+	// Text entries without items fail on replay just as they would live.
+	empty := stubExtractor{data: &services.ReceiptData{Raw: `{"title": "x", "items": []}`}}
+	_, err = (&Recorder{Dir: dir, Text: empty}).ExtractText(context.Background(), "hello", services.ExtractHints{})
+	require.NoError(t, err)
+	_, err = (&Replayer{Dir: dir}).ExtractText(context.Background(), "hello", services.ExtractHints{})
+	assert.Error(t, err)
+}
+
+func TestRecorder_KeepsExtractorError(t *testing.T) {
+	dir := t.TempDir()
+	rec := &Recorder{Dir: dir, Text: stubExtractor{err: errors.New("gemini http 503: overloaded")}}
+
+	_, err := rec.ExtractText(context.Background(), "午餐 250", services.ExtractHints{})
+	require.Error(t, err)
+
+	_, err = (&Replayer{Dir: dir}).ExtractText(context.Background(), "午餐 250", services.ExtractHints{})
+	assert.EqualError(t, err, "gemini http 503: overloaded")
+}
+
+func TestScore(t *testing.T) {
+	date := time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC)
+	total := decimal.NewFromInt(215)
+	c := Case{Name: "c", Expected: Expected{
+		Title:         strPtr("7-ELEVEN 信義店"),
+		Date:          strPtr("2024-07-14"),
+		InvoiceNumber: strPtr("AB12345678"),
+		Total:         &total,
+		Items: []ExpectedItem{
+			{Name: "拿鐵", UnitPrice: decimal.NewFromInt(55), Quantity: decimal.NewFromInt(1)},
+			{Name: "御飯糰", UnitPrice: decimal.NewFromInt(40), Quantity: decimal.NewFromInt(2)},
+			{Name: "報紙", UnitPrice: decimal.NewFromInt(20), Quantity: decimal.NewFromInt(1)},
+		},
+	}}
+	got := &services.ReceiptData{
+		Title: "7-eleven信義店", Date: &date,
+		Items: []services.ReceiptItem{
+			{Name: "大杯拿鐵", UnitPrice: decimal.NewFromInt(55), Quantity: decimal.NewFromInt(1)},
+			{Name: "御飯糰", UnitPrice: decimal.NewFromInt(40), Quantity: decimal.NewFromInt(2)},
+		},
+		Tax: decimal.NewFromInt(10),
+	}
+
+	res := Score(c, got)
+	assert.Equal(t, map[string]bool{"title": true, "date": true, "invoice_number": false}, res.Fields)
+	assert.Equal(t, 3, res.ItemsExpected)
+	assert.Equal(t, 2, res.ItemsMatched)
+	require.NotNil(t, res.TotalError)
+	assert.True(t, decimal.NewFromInt(70).Equal(*res.TotalError), "145 extracted vs 215 expected")
+}
+
+func TestRunner_FailureCountsAgainstMetrics(t *testing.T) {
+	total := decimal.NewFromInt(100)
+	cases := []Case{{Name: "t", Text: "停車費 100", Expected: Expected{
+		Category: strPtr("交通"),
+		Total:    &total,
+		Items:    []ExpectedItem{{Name: "停車費", UnitPrice: total}},
+	}}}
+
+	report := Runner{Text: stubExtractor{err: errors.New("boom")}}.Run(context.Background(), cases)
+	assert.Equal(t, 1, report.Failed)
+	assert.Zero(t, report.FieldAccuracy["category"])
+	assert.Zero(t, report.ItemRecall)
+	assert.True(t, total.Equal(report.TotalMAE))
+}
@@ -0,0 +1,128 @@
+// Package aieval runs receipt extractors over a labelled dataset and scores
+// the output, so prompt or parser changes can be compared before shipping.
+//
+// A dataset is a directory of cases. Each case is one input file plus a
+// sibling "<name>.expected.json":
+//
+//	lunch.txt              quick-entry text (one line)
+//	lunch.expected.json
+//	7-11.jpg               receipt image (.jpg/.jpeg/.png/.webp/.heic)
+//	7-11.expected.json
+//	hints.json             optional ExtractHints shared by every case
+package aieval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"lovelion/internal/services"
+
+	"github.com/shopspring/decimal"
+)
+
+const expectedSuffix = ".expected.json"
+
+// Expected is the ground truth for one case. Nil pointer fields are not
+// scored, so a case can label only the fields it cares about.
+type Expected struct {
+	Title         *string          `json:"title"`
+	Date          *string          `json:"date"` // YYYY-MM-DD or YYYY-MM-DD HH:mm
+	Category      *string          `json:"category"`
+	PaymentMethod *string          `json:"payment_method"`
+	Currency      *string          `json:"currency"`
+	InvoiceNumber *string          `json:"invoice_number"`
+	Total         *decimal.Decimal `json:"total"`
+	Items         []ExpectedItem   `json:"items"`
+}
+
+// ExpectedItem is one labelled line item.
+type ExpectedItem struct {
+	Name      string          `json:"name"`
+	UnitPrice decimal.Decimal `json:"unit_price"`
+	Quantity  decimal.Decimal `json:"quantity"`
+}
+
+// Case is one input with its expected output.
+type Case struct {
+	Name     string
+	Text     string // set for .txt cases
+	Image    []byte // set for image cases
+	MimeType string
+	Expected Expected
+}
+
+// IsText reports whether the case goes through the text extraction path.
+func (c Case) IsText() bool { return c.Image == nil }
+
+var imageMimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".heic": "image/heic",
+	".heif": "image/heic",
+}
+
+// LoadDataset reads every case in dir, sorted by name. Inputs without an
+// expected file are skipped.
+func LoadDataset(dir string) ([]Case, services.ExtractHints, error) {
+	var hints services.ExtractHints
+	if raw, err := os.ReadFile(filepath.Join(dir, "hints.json")); err == nil {
+		if err := json.Unmarshal(raw, &hints); err != nil {
+			return nil, hints, fmt.Errorf("parse hints.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, hints, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, hints, err
+	}
+
+	var cases []Case
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		file := e.Name()
+		ext := strings.ToLower(filepath.Ext(file))
+		name := strings.TrimSuffix(file, filepath.Ext(file))
+
+		mime, isImage := imageMimeTypes[ext]
+		if ext != ".txt" && !isImage {
+			continue
+		}
+
+		expRaw, err := os.ReadFile(filepath.Join(dir, name+expectedSuffix))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, hints, err
+		}
+		c := Case{Name: name}
+		if err := json.Unmarshal(expRaw, &c.Expected); err != nil {
+			return nil, hints, fmt.Errorf("parse %s%s: %w", name, expectedSuffix, err)
+		}
+
+		input, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, hints, err
+		}
+		if isImage {
+			c.Image = input
+			c.MimeType = mime
+		} else {
+			c.Text = strings.TrimSpace(string(input))
+		}
+		cases = append(cases, c)
+	}
+
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, hints, nil
+}
@@ -0,0 +1,131 @@
+package aieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"lovelion/internal/services"
+)
+
+// recording is one stored extractor response. LLM answers are kept as the
+// model's raw output and parsed again on replay, so the parser and field
+// mapping are part of what an eval measures. Data is only stored for
+// extractors with no model output, such as the e-invoice QR reader.
+type recording struct {
+	Raw           string                `json:"raw,omitempty"`
+	PromptVersion string                `json:"prompt_version,omitempty"`
+	Data          *services.ReceiptData `json:"data,omitempty"`
+	Error         string                `json:"error,omitempty"`
+}
+
+// recordingKey names a recording after its input, so a recording stays valid
+// when cases are renamed and is reused across datasets.
+func recordingKey(image []byte, text string) string {
+	h := sha256.New()
+	if image != nil {
+		h.Write([]byte("image:"))
+		h.Write(image)
+	} else {
+		h.Write([]byte("text:"))
+		h.Write([]byte(text))
+	}
+	return hex.EncodeToString(h.Sum(nil)) + ".json"
+}
+
+// Recorder wraps live extractors and stores each response in Dir.
+type Recorder struct {
+	Dir   string
+	Image services.ReceiptExtractor
+	Text  services.TextExtractor
+}
+
+func (r *Recorder) Extract(ctx context.Context, image []byte, mimeType string, hints services.ExtractHints) (*services.ReceiptData, error) {
+	if r.Image == nil {
+		return nil, errors.New("no image extractor configured")
+	}
+	data, err := r.Image.Extract(ctx, image, mimeType, hints)
+	return data, r.save(recordingKey(image, ""), data, err)
+}
+
+func (r *Recorder) ExtractText(ctx context.Context, text string, hints services.ExtractHints) (*services.ReceiptData, error) {
+	if r.Text == nil {
+		return nil, errors.New("no text extractor configured")
+	}
+	data, err := r.Text.ExtractText(ctx, text, hints)
+	return data, r.save(recordingKey(nil, text), data, err)
+}
+
+// save writes the response and hands back the extractor's own error, so a
+// recorded failure still counts as a failure in the report.
+func (r *Recorder) save(key string, data *services.ReceiptData, extractErr error) error {
+	var rec recording
+	switch {
+	case data != nil && data.Raw != "":
+		rec.Raw, rec.PromptVersion = data.Raw, data.PromptVersion
+	case data != nil:
+		rec.Data = data
+	}
+	if extractErr != nil {
+		rec.Error = extractErr.Error()
+	}
+	raw, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir, key), raw, 0o644); err != nil {
+		return fmt.Errorf("save recording: %w", err)
+	}
+	return extractErr
+}
+
+// Replayer serves responses saved by Recorder without calling any model,
+// running recorded model output through the current parser.
+type Replayer struct {
+	Dir string
+}
+
+func (r *Replayer) Extract(_ context.Context, image []byte, _ string, _ services.ExtractHints) (*services.ReceiptData, error) {
+	return r.load(recordingKey(image, ""), false)
+}
+
+func (r *Replayer) ExtractText(_ context.Context, text string, _ services.ExtractHints) (*services.ReceiptData, error) {
+	return r.load(recordingKey(nil, text), true)
+}
+
+func (r *Replayer) load(key string, text bool) (*services.ReceiptData, error) {
+	raw, err := os.ReadFile(filepath.Join(r.Dir, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no recording %s", key)
+		}
+		return nil, err
+	}
+	var rec recording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, fmt.Errorf("parse recording %s: %w", key, err)
+	}
+	if rec.Error != "" {
+		return nil, errors.New(rec.Error)
+	}
+	if rec.Raw != "" {
+		data, err := services.ParseReceiptResponse(rec.Raw, text)
+		if err != nil {
+			return nil, err
+		}
+		data.PromptVersion = rec.PromptVersion
+		return data, nil
+	}
+	if rec.Data == nil {
+		return nil, fmt.Errorf("recording %s has no data", key)
+	}
+	return rec.Data, nil
+}
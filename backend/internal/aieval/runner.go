@@ -0,0 +1,68 @@
+package aieval
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"lovelion/internal/services"
+)
+
+// Runner feeds every case to the matching extractor and scores the output.
+type Runner struct {
+	Image services.ReceiptExtractor
+	Text  services.TextExtractor // optional; text cases fail without it
+	Hints services.ExtractHints
+}
+
+// Run evaluates the cases sequentially and returns the aggregated report.
+func (r Runner) Run(ctx context.Context, cases []Case) Report {
+	results := make([]CaseResult, 0, len(cases))
+	for _, c := range cases {
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		data, err := r.extract(ctx, c)
+		var res CaseResult
+		if err != nil {
+			res = scoreFailure(c, err)
+		} else {
+			res = Score(c, data)
+		}
+		res.Duration = time.Since(start)
+		results = append(results, res)
+	}
+	return Summarize(results)
+}
+
+func (r Runner) extract(ctx context.Context, c Case) (*services.ReceiptData, error) {
+	if c.IsText() {
+		if r.Text == nil {
+			return nil, errors.New("no text extractor configured")
+		}
+		return r.Text.ExtractText(ctx, c.Text, r.Hints)
+	}
+	if r.Image == nil {
+		return nil, errors.New("no image extractor configured")
+	}
+	return r.Image.Extract(ctx, c.Image, c.MimeType, r.Hints)
+}
+
+// scoreFailure counts a failed extraction as wrong on every labelled field and
+// off by the whole expected total, so failures drag the metrics down instead
+// of silently shrinking the sample.
+func scoreFailure(c Case, err error) CaseResult {
+	res := Score(c, &services.ReceiptData{})
+	for field := range res.Fields {
+		res.Fields[field] = false
+	}
+	res.ItemsMatched = 0
+	res.Total = nil
+	if c.Expected.Total != nil {
+		full := c.Expected.Total.Abs()
+		res.TotalError = &full
+	}
+	res.Error = err.Error()
+	return res
+}
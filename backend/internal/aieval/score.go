@@ -0,0 +1,176 @@
+package aieval
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"lovelion/internal/services"
+
+	"github.com/shopspring/decimal"
+)
+
+// priceTolerance absorbs float rounding in model output.
+var priceTolerance = decimal.RequireFromString("0.01")
+
+// CaseResult is the score for one case.
+type CaseResult struct {
+	Name          string           `json:"name"`
+	Error         string           `json:"error,omitempty"`
+	Fields        map[string]bool  `json:"fields"` // scored field → correct
+	ItemsExpected int              `json:"items_expected"`
+	ItemsMatched  int              `json:"items_matched"`
+	Total         *decimal.Decimal `json:"total,omitempty"`
+	TotalError    *decimal.Decimal `json:"total_error,omitempty"` // |extracted - expected|
+	Duration      time.Duration    `json:"duration_ns"`
+	PromptVersion string           `json:"prompt_version,omitempty"`
+}
+
+// Report aggregates every case.
+type Report struct {
+	Cases         []CaseResult       `json:"cases"`
+	FieldAccuracy map[string]float64 `json:"field_accuracy"`
+	ItemRecall    float64            `json:"item_recall"`
+	TotalMAE      decimal.Decimal    `json:"total_mean_abs_error"`
+	TotalExact    float64            `json:"total_exact_rate"`
+	Failed        int                `json:"failed"`
+}
+
+// Score compares one extraction against its expected output.
+func Score(c Case, got *services.ReceiptData) CaseResult {
+	res := CaseResult{Name: c.Name, Fields: map[string]bool{}, PromptVersion: got.PromptVersion}
+	exp := c.Expected
+
+	checkText := func(field string, want *string, have string) {
+		if want != nil {
+			res.Fields[field] = normalize(*want) == normalize(have)
+		}
+	}
+	checkText("title", exp.Title, got.Title)
+	checkText("category", exp.Category, got.Category)
+	checkText("payment_method", exp.PaymentMethod, got.PaymentMethod)
+	checkText("currency", exp.Currency, got.Currency)
+	checkText("invoice_number", exp.InvoiceNumber, got.InvoiceNumber)
+
+	if exp.Date != nil {
+		res.Fields["date"] = dateMatches(*exp.Date, got.Date)
+	}
+
+	res.ItemsExpected = len(exp.Items)
+	res.ItemsMatched = matchItems(exp.Items, got.Items)
+
+	if exp.Total != nil {
+		total := extractedTotal(got)
+		diff := total.Sub(*exp.Total).Abs()
+		res.Total = &total
+		res.TotalError = &diff
+	}
+	return res
+}
+
+// Summarize computes aggregate metrics over scored cases.
+func Summarize(cases []CaseResult) Report {
+	r := Report{Cases: cases, FieldAccuracy: map[string]float64{}}
+
+	checked := map[string]int{}
+	correct := map[string]int{}
+	var itemsExpected, itemsMatched, totals, exact int
+	sumErr := decimal.Zero
+
+	for _, c := range cases {
+		if c.Error != "" {
+			r.Failed++
+		}
+		for field, ok := range c.Fields {
+			checked[field]++
+			if ok {
+				correct[field]++
+			}
+		}
+		itemsExpected += c.ItemsExpected
+		itemsMatched += c.ItemsMatched
+		if c.TotalError != nil {
+			totals++
+			sumErr = sumErr.Add(*c.TotalError)
+			if c.TotalError.LessThanOrEqual(priceTolerance) {
+				exact++
+			}
+		}
+	}
+
+	for field, n := range checked {
+		r.FieldAccuracy[field] = float64(correct[field]) / float64(n)
+	}
+	if itemsExpected > 0 {
+		r.ItemRecall = float64(itemsMatched) / float64(itemsExpected)
+	}
+	if totals > 0 {
+		r.TotalMAE = sumErr.Div(decimal.NewFromInt(int64(totals))).Round(2)
+		r.TotalExact = float64(exact) / float64(totals)
+	}
+	return r
+}
+
+// FieldNames returns the scored fields in a stable order for printing.
+func (r Report) FieldNames() []string {
+	names := make([]string, 0, len(r.FieldAccuracy))
+	for f := range r.FieldAccuracy {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchItems greedily pairs expected items with extracted ones. A pair needs
+// a matching name (equal or one containing the other, ignoring case and
+// spaces) and the same unit price; quantity must match when labelled.
+func matchItems(want []ExpectedItem, got []services.ReceiptItem) int {
+	used := make([]bool, len(got))
+	matched := 0
+	for _, w := range want {
+		wn := normalize(w.Name)
+		for i, g := range got {
+			if used[i] {
+				continue
+			}
+			gn := normalize(g.Name)
+			nameOK := wn == gn || (wn != "" && gn != "" && (strings.Contains(gn, wn) || strings.Contains(wn, gn)))
+			priceOK := g.UnitPrice.Sub(w.UnitPrice).Abs().LessThanOrEqual(priceTolerance)
+			qtyOK := w.Quantity.IsZero() || g.Quantity.Equal(w.Quantity)
+			if nameOK && priceOK && qtyOK {
+				used[i] = true
+				matched++
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// extractedTotal mirrors what the worker writes to total_amount: items plus
+// separately listed tax and tip.
+func extractedTotal(d *services.ReceiptData) decimal.Decimal {
+	total := d.Tax.Add(d.Tip)
+	for _, it := range d.Items {
+		qty := it.Quantity
+		if qty.IsZero() {
+			qty = decimal.NewFromInt(1)
+		}
+		total = total.Add(it.UnitPrice.Mul(qty))
+	}
+	return total
+}
+
+func dateMatches(want string, got *time.Time) bool {
+	if got == nil {
+		return want == ""
+	}
+	if len(want) > len("2006-01-02") {
+		return got.Format("2006-01-02 15:04") == want
+	}
+	return got.Format("2006-01-02") == want
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
@@ -0,0 +1,137 @@
+// Package events fans out space-scoped change notifications (transaction
+// writes, AI status transitions) to connected SSE clients. Events are
+// invalidation hints: clients refetch the transaction rather than trusting
+// the payload as the full state.
+package events
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event types pushed to clients.
+const (
+	TypeTransactionCreated = "transaction.created"
+	TypeTransactionUpdated = "transaction.updated"
+	TypeTransactionDeleted = "transaction.deleted"
+	TypeAIStatus           = "ai.status"
+)
+
+// subscriberBuffer is how many events a slow client may lag behind before
+// further events to it are dropped.
+const subscriberBuffer = 32
+
+// Event is a single change notification for one space.
+type Event struct {
+	Type          string    `json:"type"`
+	SpaceID       uuid.UUID `json:"space_id"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	AIStatus      string    `json:"ai_status,omitempty"`
+	AIError       string    `json:"ai_error,omitempty"`
+}
+
+// Bus is an in-process publish/subscribe hub keyed by space. With a
+// Postgres relay attached (see UsePostgres) Publish goes through
+// LISTEN/NOTIFY so every replica's subscribers see it.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[uuid.UUID]map[chan Event]struct{}
+	closed bool
+	relay  *pgRelay // nil → deliver locally
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[uuid.UUID]map[chan Event]struct{})}
+}
+
+// Subscribe registers a listener for one space. The returned cancel func must
+// be called when the listener goes away; the channel is closed by cancel or
+// by Close.
+func (b *Bus) Subscribe(spaceID uuid.UUID) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[spaceID] == nil {
+		b.subs[spaceID] = make(map[chan Event]struct{})
+	}
+	b.subs[spaceID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subs[spaceID][ch]; !ok {
+				return // already closed by Close
+			}
+			delete(b.subs[spaceID], ch)
+			if len(b.subs[spaceID]) == 0 {
+				delete(b.subs, spaceID)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// Publish sends e to every subscriber of e.SpaceID. It never blocks: a
+// subscriber whose buffer is full misses the event.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	relay := b.relay
+	b.mu.RUnlock()
+	if relay != nil {
+		err := relay.notify(e)
+		if err == nil {
+			return
+		}
+		slog.Warn("events: pg notify failed, delivering locally", "error", err)
+	}
+	b.deliver(e)
+}
+
+// deliver fans e out to local subscribers only.
+func (b *Bus) deliver(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs[e.SpaceID] {
+		select {
+		case ch <- e:
+		default:
+			slog.Warn("events: subscriber lagging, event dropped", "space_id", e.SpaceID, "type", e.Type)
+		}
+	}
+}
+
+// Close disconnects every subscriber and stops the Postgres relay. Called on
+// shutdown so open SSE streams end before the HTTP server drains.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for spaceID, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(b.subs, spaceID)
+	}
+	relay := b.relay
+	b.relay = nil
+	b.mu.Unlock()
+
+	// Stopped outside the lock: the relay goroutine may be waiting on it in deliver.
+	if relay != nil {
+		relay.close()
+	}
+}
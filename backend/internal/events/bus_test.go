@@ -0,0 +1,86 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+func TestBus_DeliversOnlyToSameSpace(t *testing.T) {
+	bus := NewBus()
+	spaceA, spaceB := uuid.New(), uuid.New()
+
+	chA, cancelA := bus.Subscribe(spaceA)
+	defer cancelA()
+	chB, cancelB := bus.Subscribe(spaceB)
+	defer cancelB()
+
+	bus.Publish(Event{Type: TypeAIStatus, SpaceID: spaceA, TransactionID: "t1", AIStatus: "processing"})
+
+	got := receive(t, chA)
+	assert.Equal(t, "t1", got.TransactionID)
+	assert.Equal(t, "processing", got.AIStatus)
+	assert.Empty(t, chB)
+}
+
+func TestBus_CancelClosesChannel(t *testing.T) {
+	bus := NewBus()
+	spaceID := uuid.New()
+	ch, cancel := bus.Subscribe(spaceID)
+	cancel()
+	cancel() // idempotent
+
+	_, ok := <-ch
+	assert.False(t, ok)
+	bus.Publish(Event{Type: TypeTransactionCreated, SpaceID: spaceID}) // no panic on closed subscriber
+}
+
+func TestBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewBus()
+	spaceID := uuid.New()
+	ch, cancel := bus.Subscribe(spaceID)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			bus.Publish(Event{Type: TypeTransactionUpdated, SpaceID: spaceID})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a full subscriber")
+	}
+	assert.Len(t, ch, subscriberBuffer)
+}
+
+func TestBus_CloseEndsSubscriptions(t *testing.T) {
+	bus := NewBus()
+	ch, cancel := bus.Subscribe(uuid.New())
+	bus.Close()
+	cancel() // safe after Close
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	late, _ := bus.Subscribe(uuid.New())
+	_, ok = <-late
+	assert.False(t, ok, "subscribing after Close returns a closed channel")
+}
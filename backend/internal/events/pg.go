@@ -0,0 +1,87 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// pgChannel is the LISTEN/NOTIFY channel shared by all replicas.
+const pgChannel = "lovelion_events"
+
+// pgRelay publishes events with pg_notify and feeds notifications received
+// on pgChannel back into the local bus.
+type pgRelay struct {
+	db       *gorm.DB
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+// UsePostgres routes Publish through Postgres LISTEN/NOTIFY so events reach
+// subscribers on every replica, including this one. dsn is the same
+// connection string the app uses; the listener keeps its own connection.
+func (b *Bus) UsePostgres(db *gorm.DB, dsn string) error {
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("events: pg listener", "event", ev, "error", err)
+		}
+	})
+	if err := listener.Listen(pgChannel); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("listen %s: %w", pgChannel, err)
+	}
+
+	relay := &pgRelay{db: db, listener: listener, done: make(chan struct{})}
+	go relay.run(b)
+
+	b.mu.Lock()
+	b.relay = relay
+	b.mu.Unlock()
+	return nil
+}
+
+func (r *pgRelay) notify(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return r.db.Exec("SELECT pg_notify(?, ?)", pgChannel, string(payload)).Error
+}
+
+// run forwards notifications until close. A nil notification means the
+// listener reconnected; anything sent meanwhile is lost, which is acceptable
+// for invalidation hints.
+func (r *pgRelay) run(b *Bus) {
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case n, ok := <-r.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				slog.Warn("events: bad pg payload", "error", err)
+				continue
+			}
+			b.deliver(e)
+		case <-ping.C:
+			go func() { _ = r.listener.Ping() }()
+		}
+	}
+}
+
+func (r *pgRelay) close() {
+	close(r.done)
+	_ = r.listener.Close()
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"lovelion/internal/models"
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdminAIAttemptHandler exposes the stored request / raw model output of each
+// extraction attempt to admins.
+type AdminAIAttemptHandler struct {
+	db     *gorm.DB
+	worker *services.AIWorker // nil when receipt extraction is disabled
+}
+
+func NewAdminAIAttemptHandler(db *gorm.DB, worker *services.AIWorker) *AdminAIAttemptHandler {
+	return &AdminAIAttemptHandler{db: db, worker: worker}
+}
+
+// List returns every attempt for a transaction, newest first.
+func (h *AdminAIAttemptHandler) List(c *gin.Context) {
+	var attempts []models.AIExtractionAttempt
+	err := h.db.Where("transaction_id = ?", c.Param("id")).
+		Order("created_at DESC").
+		Find(&attempts).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch extraction attempts"})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
+
+// Reparse re-runs parsing and mapping on an attempt's stored output without
+// calling the LLM, and returns the new attempt.
+func (h *AdminAIAttemptHandler) Reparse(c *gin.Context) {
+	if h.worker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Receipt extraction is disabled"})
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attempt ID"})
+		return
+	}
+
+	attempt, err := h.worker.Reparse(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, attempt)
+}
@@ -3,25 +3,26 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"lovelion/internal/models"
+	"lovelion/internal/services"
+	"lovelion/internal/utils/errorx"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	db        *gorm.DB
-	jwtSecret string
-	jwtExpiry time.Duration
+	db       *gorm.DB
+	sessions *services.Sessions
 }
 
-func NewAuthHandler(db *gorm.DB, jwtSecret string, jwtExpiry time.Duration) *AuthHandler {
-	return &AuthHandler{db: db, jwtSecret: jwtSecret, jwtExpiry: jwtExpiry}
+// NewAuthHandler signs users in to sessions. The session store is shared
+// with the session handler.
+func NewAuthHandler(db *gorm.DB, sessions *services.Sessions) *AuthHandler {
+	return &AuthHandler{db: db, sessions: sessions}
 }
 
 type RegisterRequest struct {
@@ -36,8 +37,12 @@ type LoginRequest struct {
 }
 
 type TokenResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	*services.TokenPair
+	User *models.User `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type UpdateMeRequest struct {
@@ -108,7 +113,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 			ID:      uuid.New(),
 			SpaceID: space.ID,
 			UserID:  user.ID,
-			Role:    "owner",
+			Role:    models.SpaceRoleOwner,
 		}
 
 		if err := tx.Create(member).Error; err != nil {
@@ -127,14 +132,13 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	userValue, _ := c.Get("registeredUser")
 	user := userValue.(*models.User)
 
-	// Generate token
-	token, err := h.generateToken(user.ID)
+	pair, err := h.sessions.Start(c.Request.Context(), user.ID, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, TokenResponse{Token: token, User: user})
+	c.JSON(http.StatusCreated, TokenResponse{TokenPair: pair, User: user})
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
@@ -157,14 +161,46 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Generate token
-	token, err := h.generateToken(user.ID)
+	pair, err := h.sessions.Start(c.Request.Context(), user.ID, clientInfo(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{TokenPair: pair, User: &user})
+}
+
+// Refresh rotates the refresh token and issues a new access token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := h.sessions.Refresh(c.Request.Context(), req.RefreshToken, clientInfo(c))
+	if errorx.Is(err, errorx.ErrExpired) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, TokenResponse{Token: token, User: &user})
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout ends the current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if sessionID := currentSessionID(c); sessionID != uuid.Nil {
+		if err := h.sessions.Revoke(c.Request.Context(), currentUserID(c), sessionID); err != nil && !errorx.Is(err, errorx.ErrNotFound) {
+			respondError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
 
 func (h *AuthHandler) GetMe(c *gin.Context) {
@@ -238,16 +274,17 @@ func (h *AuthHandler) UpdateMe(c *gin.Context) {
 		return
 	}
 
+	// A new password signs out every other device
+	if req.NewPassword != "" {
+		if _, err := h.sessions.RevokeAll(c.Request.Context(), userID, currentSessionID(c)); err != nil {
+			respondError(c, err)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, user)
 }
 
-func (h *AuthHandler) generateToken(userID uuid.UUID) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID.String(),
-		"exp":     time.Now().Add(h.jwtExpiry).Unix(),
-		"iat":     time.Now().Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(h.jwtSecret))
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"lovelion/internal/models"
+	"lovelion/internal/services"
 	"lovelion/internal/testutil"
 
 	"github.com/google/uuid"
@@ -16,7 +17,7 @@ func TestAuthHandler_Register(t *testing.T) {
 	db := testutil.TestDB(t)
 	router := testutil.TestRouter()
 
-	handler := NewAuthHandler(db, "test-secret", 7*24*time.Hour)
+	handler := NewAuthHandler(db, services.NewSessions(db, "test-secret", 7*24*time.Hour))
 	router.POST("/api/users/register", handler.Register)
 
 	tests := []struct {
@@ -78,7 +79,7 @@ func TestAuthHandler_Register_DuplicateUsername(t *testing.T) {
 	db := testutil.TestDB(t)
 	router := testutil.TestRouter()
 
-	handler := NewAuthHandler(db, "test-secret", 7*24*time.Hour)
+	handler := NewAuthHandler(db, services.NewSessions(db, "test-secret", 7*24*time.Hour))
 	router.POST("/api/users/register", handler.Register)
 
 	// First registration
@@ -103,7 +104,7 @@ func TestAuthHandler_Login(t *testing.T) {
 	db := testutil.TestDB(t)
 	router := testutil.TestRouter()
 
-	handler := NewAuthHandler(db, "test-secret", 7*24*time.Hour)
+	handler := NewAuthHandler(db, services.NewSessions(db, "test-secret", 7*24*time.Hour))
 	router.POST("/api/users/register", handler.Register)
 	router.POST("/api/users/login", handler.Login)
 
@@ -163,7 +164,7 @@ func TestAuthHandler_GetMe(t *testing.T) {
 	user := testutil.CreateTestUser(t, db)
 
 	router := testutil.TestRouter()
-	handler := NewAuthHandler(db, "test-secret", 7*24*time.Hour)
+	handler := NewAuthHandler(db, services.NewSessions(db, "test-secret", 7*24*time.Hour))
 
 	// With auth context
 	router.GET("/api/users/me", testutil.AuthContext(user.ID), handler.GetMe)
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultDashboardCurrency matches the default base currency of a space.
+const defaultDashboardCurrency = "TWD"
+
+type DashboardHandler struct {
+	db *gorm.DB
+}
+
+func NewDashboardHandler(db *gorm.DB) *DashboardHandler {
+	return &DashboardHandler{db: db}
+}
+
+// Get returns the current user's overview across their spaces. ?currency=
+// picks the currency this month's spend is converted to.
+func (h *DashboardHandler) Get(c *gin.Context) {
+	currency := strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+	if currency == "" {
+		currency = defaultDashboardCurrency
+	}
+	if len(currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency"})
+		return
+	}
+
+	userID := currentUserID(c)
+	dash, err := services.UserDashboard(c.Request.Context(), h.db, userID, currency, time.Now())
+	if err != nil {
+		slog.Error("dashboard failed", "user_id", userID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dashboard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dash)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"lovelion/internal/events"
+	"lovelion/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseHeartbeat keeps proxies from closing an idle stream.
+const sseHeartbeat = 25 * time.Second
+
+type EventsHandler struct {
+	bus *events.Bus
+}
+
+func NewEventsHandler(bus *events.Bus) *EventsHandler {
+	return &EventsHandler{bus: bus}
+}
+
+// Stream pushes the space's transaction and AI status events as
+// Server-Sent Events until the client disconnects or the server shuts down.
+// GET /api/spaces/:id/events
+func (h *EventsHandler) Stream(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	ch, cancel := h.bus.Subscribe(space.ID)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable nginx response buffering
+	c.Status(http.StatusOK)
+	c.SSEvent("ready", gin.H{"space_id": space.ID})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case e, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent(e.Type, e)
+			return true
+		case <-heartbeat.C:
+			_, _ = io.WriteString(w, ": ping\n\n")
+			return true
+		}
+	})
+}
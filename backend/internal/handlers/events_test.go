@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lovelion/internal/events"
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvent reads lines until it sees an SSE "event:" line and returns its name
+// and the following data line.
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var name string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:") && name != "":
+			return name, strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+}
+
+func TestEventsHandler_StreamsSpaceEvents(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	space := &models.Space{ID: uuid.New()}
+
+	router := testutil.TestRouter()
+	router.GET("/api/spaces/:id/events", func(c *gin.Context) {
+		c.Set("space", space)
+		c.Next()
+	}, NewEventsHandler(bus).Stream)
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/spaces/" + space.ID.String() + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	reader := bufio.NewReader(resp.Body)
+	name, _ := readEvent(t, reader)
+	require.Equal(t, "ready", name)
+
+	// Events for other spaces are filtered out.
+	bus.Publish(events.Event{Type: events.TypeTransactionCreated, SpaceID: uuid.New(), TransactionID: "other"})
+	bus.Publish(events.Event{Type: events.TypeAIStatus, SpaceID: space.ID, TransactionID: "t1", AIStatus: "completed"})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		name, data := readEvent(t, reader)
+		assert.Equal(t, events.TypeAIStatus, name)
+		assert.Contains(t, data, `"transaction_id":"t1"`)
+		assert.Contains(t, data, `"ai_status":"completed"`)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not streamed")
+	}
+}
@@ -2,30 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
-	"image"
-	_ "image/jpeg"
-	_ "image/png"
 	"io"
-	"log/slog"
 	"mime/multipart"
 	"net/http"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"lovelion/internal/imaging"
 	"lovelion/internal/middleware"
 	"lovelion/internal/models"
 	"lovelion/internal/services"
 	"lovelion/internal/utils/errorx"
 
-	"github.com/bbrks/go-blurhash"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
 const (
-	maxImageSize  = 5 * 1024 * 1024 // 5MB
+	maxImageSize  = services.MaxImageSize
 	maxImageCount = 10
 )
 
@@ -58,6 +53,7 @@ type ExpenseDetailRequest struct {
 	BillingAmount decimal.Decimal      `json:"billing_amount"`
 	HandlingFee   decimal.Decimal      `json:"handling_fee"`
 	PaymentMethod string               `json:"payment_method"`
+	LocationURL   string               `json:"location_url" binding:"max=500"`
 	Items         []ExpenseItemRequest `json:"items"`
 }
 
@@ -70,6 +66,12 @@ type CreateExpenseRequest struct {
 	Expense     ExpenseDetailRequest `json:"expense"`
 	Debts       []DebtRequest        `json:"debts"`
 	AIExtract   bool                 `json:"ai_extract"`
+	// UsePhotoMetadata fills date and location_url from the attached
+	// photos' EXIF.
+	UsePhotoMetadata bool `json:"use_photo_metadata"`
+	// ImageUploadIDs are direct uploads created without an entity_id (see
+	// ImageHandler.CreateUploadIntent), attached after any multipart images.
+	ImageUploadIDs []uuid.UUID `json:"image_upload_ids"`
 }
 
 type UpdateExpenseRequest struct {
@@ -119,7 +121,7 @@ func toDebtInputs(reqs []DebtRequest) []services.DebtInput {
 // (with optional images and ai_extract flag) content types. The multipart
 // form has two fields:
 //   - data:   JSON string matching CreateExpenseRequest
-//   - images: 0..N files (jpg/jpeg/png)
+//   - images: 0..N files (JPEG, PNG, WebP or HEIC, sniffed from content)
 func (h *ExpenseHandler) Create(c *gin.Context) {
 	spaceVal, _ := c.Get("space")
 	space := spaceVal.(*models.Space)
@@ -133,7 +135,7 @@ func (h *ExpenseHandler) Create(c *gin.Context) {
 	if req.AIExtract {
 		// AI extraction can run on either an attached image or a non-empty
 		// title (quick text entry). At least one must be present.
-		if len(images) == 0 && strings.TrimSpace(req.Title) == "" {
+		if len(images) == 0 && len(req.ImageUploadIDs) == 0 && strings.TrimSpace(req.Title) == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "AI extraction requires an image or text"})
 			return
 		}
@@ -160,11 +162,15 @@ func (h *ExpenseHandler) Create(c *gin.Context) {
 			BillingAmount: req.Expense.BillingAmount,
 			HandlingFee:   req.Expense.HandlingFee,
 			PaymentMethod: req.Expense.PaymentMethod,
+			LocationURL:   req.Expense.LocationURL,
 			Items:         toExpenseItemInputs(req.Expense.Items),
 		},
-		Debts:     toDebtInputs(req.Debts),
-		Images:    images,
-		AIExtract: req.AIExtract,
+		Debts:            toDebtInputs(req.Debts),
+		Images:           images,
+		UploadIDs:        req.ImageUploadIDs,
+		AIExtract:        req.AIExtract,
+		CreatedBy:        currentUserID(c),
+		UsePhotoMetadata: req.UsePhotoMetadata,
 	})
 	if err != nil {
 		respondError(c, err)
@@ -184,6 +190,9 @@ func parseCreateExpenseRequest(c *gin.Context) (CreateExpenseRequest, []services
 		if err := c.ShouldBindJSON(&req); err != nil {
 			return req, nil, err
 		}
+		if len(req.ImageUploadIDs) > maxImageCount {
+			return req, nil, errorx.New("BAD_REQUEST", "too many images")
+		}
 		return req, nil, nil
 	}
 
@@ -201,7 +210,7 @@ func parseCreateExpenseRequest(c *gin.Context) (CreateExpenseRequest, []services
 		return req, nil, err
 	}
 	files := form.File["images"]
-	if len(files) > maxImageCount {
+	if len(files)+len(req.ImageUploadIDs) > maxImageCount {
 		return req, nil, errorx.New("BAD_REQUEST", "too many images")
 	}
 
@@ -212,19 +221,16 @@ func parseCreateExpenseRequest(c *gin.Context) (CreateExpenseRequest, []services
 	return req, uploads, nil
 }
 
-// readImageUploads reads each file into memory, validates size + extension,
-// and computes a blurhash. Returns a slice in the original form order.
+// readImageUploads reads each file into memory and validates its size and
+// sniffed format; the file name and extension are not trusted. Decoding,
+// resizing and the blurhash happen in the service.
+// Returns a slice in the original form order.
 func readImageUploads(files []*multipart.FileHeader) ([]services.ImageUpload, error) {
 	uploads := make([]services.ImageUpload, 0, len(files))
 	for _, fh := range files {
 		if fh.Size > maxImageSize {
 			return nil, errorx.New("BAD_REQUEST", "image exceeds 5MB: "+fh.Filename)
 		}
-		ext := strings.ToLower(filepath.Ext(fh.Filename))
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-			return nil, errorx.New("BAD_REQUEST", "only jpg/jpeg/png allowed: "+fh.Filename)
-		}
-
 		f, err := fh.Open()
 		if err != nil {
 			return nil, err
@@ -234,47 +240,15 @@ func readImageUploads(files []*multipart.FileHeader) ([]services.ImageUpload, er
 		if err != nil {
 			return nil, err
 		}
-
-		// Best-effort blurhash; non-fatal if the image can't be decoded.
-		var blurHash string
-		if decoded, _, decodeErr := image.Decode(newBytesReader(body)); decodeErr == nil {
-			if h, hashErr := blurhash.Encode(4, 3, decoded); hashErr == nil {
-				blurHash = h
-			}
-		} else {
-			slog.Warn("blurhash decode failed", "file", fh.Filename, "error", decodeErr)
+		if imaging.Sniff(body) == "" {
+			return nil, errorx.New("BAD_REQUEST", services.MsgUnsupportedImageType+": "+fh.Filename)
 		}
 
-		uploads = append(uploads, services.ImageUpload{
-			FileName:    fh.Filename,
-			Body:        body,
-			ContentType: fh.Header.Get("Content-Type"),
-			BlurHash:    blurHash,
-		})
+		uploads = append(uploads, services.ImageUpload{Body: body})
 	}
 	return uploads, nil
 }
 
-// newBytesReader wraps a byte slice so image.Decode can consume it without
-// pulling in an extra bytes import via strings.NewReader.
-func newBytesReader(b []byte) io.Reader {
-	return &bytesReader{data: b}
-}
-
-type bytesReader struct {
-	data []byte
-	pos  int
-}
-
-func (r *bytesReader) Read(p []byte) (int, error) {
-	if r.pos >= len(r.data) {
-		return 0, io.EOF
-	}
-	n := copy(p, r.data[r.pos:])
-	r.pos += n
-	return n, nil
-}
-
 func (h *ExpenseHandler) Update(c *gin.Context) {
 	spaceVal, _ := c.Get("space")
 	space := spaceVal.(*models.Space)
@@ -312,6 +286,7 @@ func (h *ExpenseHandler) Update(c *gin.Context) {
 			BillingAmount: req.Expense.BillingAmount,
 			HandlingFee:   req.Expense.HandlingFee,
 			PaymentMethod: req.Expense.PaymentMethod,
+			LocationURL:   req.Expense.LocationURL,
 			Items:         toExpenseItemInputs(req.Expense.Items),
 		},
 		Debts:     toDebtInputs(req.Debts),
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"lovelion/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FilesHandler serves objects of the local storage backend. Every request
+// must carry the signature LocalStorage put in the URL.
+type FilesHandler struct {
+	local *storage.LocalStorage
+}
+
+func NewFilesHandler(local *storage.LocalStorage) *FilesHandler {
+	return &FilesHandler{local: local}
+}
+
+// Serve handles GET /files/*key.
+func (h *FilesHandler) Serve(c *gin.Context) {
+	key := c.Param("key")
+	if err := h.local.Verify(key, c.Query("sig"), c.Query("exp")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired file link"})
+		return
+	}
+	p, err := h.local.Path(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if _, err := os.Stat(p); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age="+strconv.FormatInt(cacheMaxAge(c.Query("exp")), 10))
+	c.File(p)
+}
+
+// cacheMaxAge returns how many seconds a response may be cached: no longer
+// than the signature stays valid, and a day for links that never expire.
+// exp has already been checked by Verify.
+func cacheMaxAge(exp string) int64 {
+	const day = int64(24 * time.Hour / time.Second)
+	if exp == "" {
+		return day
+	}
+	n, _ := strconv.ParseInt(exp, 10, 64)
+	return min(max(n-time.Now().Unix(), 0), day)
+}
+
+// Upload handles PUT /files/*key for URLs from LocalStorage.PresignedUploadURL,
+// standing in for a bucket's presigned PUT. The body must be exactly the
+// signed size.
+func (h *FilesHandler) Upload(c *gin.Context) {
+	key := c.Param("key")
+	size, err := h.local.VerifyUpload(key, c.Request.URL.Query(), c.ContentType())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired upload link"})
+		return
+	}
+	if c.Request.ContentLength != size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Length does not match the upload link"})
+		return
+	}
+	body := io.LimitReader(c.Request.Body, size)
+	if _, err := h.local.Upload(c.Request.Context(), key, body, c.ContentType()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
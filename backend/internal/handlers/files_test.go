@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lovelion/internal/storage"
+	"lovelion/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesHandler_Serve(t *testing.T) {
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://api.test", "secret")
+	require.NoError(t, err)
+	fullURL, err := local.Upload(context.Background(), "space/cover.png", bytes.NewReader([]byte("png-bytes")), "image/png")
+	require.NoError(t, err)
+
+	router := testutil.TestRouter()
+	router.GET(storage.FilesRoute+"*key", NewFilesHandler(local).Serve)
+
+	path := strings.TrimPrefix(fullURL, "http://api.test")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	testutil.ExpectStatus(t, w, 200)
+	assert.Equal(t, "png-bytes", w.Body.String())
+	assert.Equal(t, "private, max-age=86400", w.Header().Get("Cache-Control"))
+
+	// A link signed for a minute must not be cached past its expiry.
+	short := strings.TrimPrefix(local.SignedURL("space/cover.png", time.Minute), "http://api.test")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", short, nil))
+	testutil.ExpectStatus(t, w, 200)
+	assert.Contains(t, []string{"private, max-age=59", "private, max-age=60"}, w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/files/space/cover.png", nil))
+	testutil.ExpectStatus(t, w, 403)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", strings.Replace(path, "cover.png", "other.png", 1), nil))
+	testutil.ExpectStatus(t, w, 403)
+}
+
+func TestFilesHandler_Upload(t *testing.T) {
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://api.test", "secret")
+	require.NoError(t, err)
+	router := testutil.TestRouter()
+	router.PUT(storage.FilesRoute+"*key", NewFilesHandler(local).Upload)
+
+	signed, err := local.PresignedUploadURL(context.Background(), "uploads/abc", "image/jpeg", 5, time.Minute)
+	require.NoError(t, err)
+	path := strings.TrimPrefix(signed, "http://api.test")
+
+	put := func(path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	testutil.ExpectStatus(t, put(path, "image/png", "12345"), 403)
+	testutil.ExpectStatus(t, put(path, "image/jpeg", "123456"), 400)
+	_, err = local.Stat(context.Background(), "uploads/abc")
+	assert.ErrorIs(t, err, storage.ErrNotFound, "rejected uploads write nothing")
+
+	testutil.ExpectStatus(t, put(path, "image/jpeg", "12345"), 200)
+	data, _, err := local.Download(context.Background(), "uploads/abc")
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(data))
+}
@@ -1,31 +1,50 @@
 package handlers
 
 import (
-	"fmt"
-	"image"
-	_ "image/jpeg"
-	_ "image/png"
-	"log/slog"
+	"context"
+	"io"
 	"net/http"
-	"path/filepath"
 	"strings"
 
+	"lovelion/internal/imaging"
 	"lovelion/internal/models"
+	"lovelion/internal/services"
 	"lovelion/internal/storage"
 
-	"github.com/bbrks/go-blurhash"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type ImageHandler struct {
-	db      *gorm.DB
-	storage *storage.R2Storage
+	db        *gorm.DB
+	storage   storage.Storage
+	access    *services.ImageAccess
+	imageURLs *services.ImageURLSigner
+	imageOpts imaging.Options
+	uploads   *services.DirectUploads
 }
 
-func NewImageHandler(db *gorm.DB, r2 *storage.R2Storage) *ImageHandler {
-	return &ImageHandler{db: db, storage: r2}
+func NewImageHandler(db *gorm.DB, store storage.Storage) *ImageHandler {
+	return &ImageHandler{db: db, storage: store, access: services.NewImageAccess(db)}
+}
+
+// WithImageURLs signs the URLs of returned images.
+func (h *ImageHandler) WithImageURLs(signer *services.ImageURLSigner) *ImageHandler {
+	h.imageURLs = signer
+	return h
+}
+
+// WithImageOptions sets how uploaded originals are downscaled.
+func (h *ImageHandler) WithImageOptions(opts imaging.Options) *ImageHandler {
+	h.imageOpts = opts
+	return h
+}
+
+// WithDirectUploads enables the presigned upload endpoints.
+func (h *ImageHandler) WithDirectUploads(uploads *services.DirectUploads) *ImageHandler {
+	h.uploads = uploads
+	return h
 }
 
 // Upload handles file upload and creates a database record
@@ -39,6 +58,12 @@ func (h *ImageHandler) Upload(c *gin.Context) {
 		return
 	}
 
+	spaceID, err := h.access.CheckWrite(c.Request.Context(), currentUserID(c), entityType, entityID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
 	// Get file
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -46,10 +71,10 @@ func (h *ImageHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	// Validation
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only jpg, jpeg, and png are allowed"})
+	// The format is checked by sniffing the content in ProcessImage, not
+	// from the file name.
+	if file.Size > maxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image exceeds 5MB"})
 		return
 	}
 
@@ -59,54 +84,29 @@ func (h *ImageHandler) Upload(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
 		return
 	}
-	defer f.Close()
-
-	// Generate BlurHash
-	// We need to decode the image first. Since we need to upload the original file reader later,
-	// and decoding consumes the reader, we might need to handle this carefully.
-	// However, multipart.File implements Seek, so we can rewind.
-	imgData, _, err := image.Decode(f)
+	body, err := io.ReadAll(f)
+	f.Close()
 	if err != nil {
-		// Log warning but don't fail upload? Or fail?
-		// For now, if not an image we can decode, just skip blurhash or error.
-		// User only uploads jpg/png validated above, so should be fine.
-		slog.Warn("failed to decode image for blurhash", "error", err)
-	}
-
-	var blurHashStr string
-	if imgData != nil {
-		// Components x=4, y=3 usually good compromise
-		blurHashStr, err = blurhash.Encode(4, 3, imgData)
-		if err != nil {
-			slog.Warn("failed to encode blurhash", "error", err)
-		}
-	}
-
-	// Rewind file for S3 upload
-	if _, err := f.Seek(0, 0); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process file"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
 		return
 	}
 
-	// Generate key
-	fileUUID := uuid.New()
-	key := fmt.Sprintf("%s/%s%s", entityType, fileUUID.String(), ext)
-
-	// Upload to R2
-	fullURL, err := h.storage.Upload(c.Request.Context(), key, f, file.Header.Get("Content-Type"))
+	// Orient, strip EXIF, resize, compute the BlurHash and upload the
+	// original plus variants; only keys are stored, URLs are signed per
+	// response.
+	processed, err := services.ProcessImage(body, h.imageOpts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload to storage: " + err.Error()})
+		respondError(c, err)
 		return
 	}
-
-	// Create DB Record
-	image := models.Image{
-		ID:         fileUUID,
-		EntityID:   entityID,
-		EntityType: entityType,
-		FilePath:   fullURL,
-		BlurHash:   blurHashStr,
+	ctx := c.Request.Context()
+	created, keys, err := services.StoreImage(ctx, h.storage, entityType, entityID, processed)
+	if err != nil {
+		h.deleteKeys(keys)
+		respondError(c, err)
+		return
 	}
+	image := *created
 
 	// Determine sort order
 	var maxOrder int
@@ -120,40 +120,129 @@ func (h *ImageHandler) Upload(c *gin.Context) {
 	}
 
 	if err := h.db.Create(&image).Error; err != nil {
-		// Attempt to delete from R2 if DB insert fails
-		_ = h.storage.Delete(c.Request.Context(), key)
+		// Attempt to delete from storage if DB insert fails
+		h.deleteKeys(keys)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image record"})
 		return
 	}
+	image.Duplicates = services.TransactionImageDuplicates(ctx, h.db, spaceID, &image, processed)
 
-	c.JSON(http.StatusCreated, image)
+	response := []models.Image{image}
+	h.imageURLs.Images(ctx, response)
+	c.JSON(http.StatusCreated, response[0])
 }
 
-// List images for an entity
-func (h *ImageHandler) List(c *gin.Context) {
-	entityID := c.Query("entity_id")
-	entityType := c.Query("entity_type")
-	entityIDs := c.Query("entity_ids") // comma separated
+// UploadIntentRequest describes an image the client wants to PUT to
+// storage itself. entity_id may be omitted for photos of an expense that is
+// about to be created in space_id; those uploads are passed to the create
+// request as image_upload_ids instead of being finalized.
+type UploadIntentRequest struct {
+	EntityType  string `json:"entity_type" binding:"required"`
+	EntityID    string `json:"entity_id"`
+	SpaceID     string `json:"space_id"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+}
 
-	query := h.db.Model(&models.Image{})
+// CreateUploadIntent returns a presigned PUT URL and the ID of the pending
+// upload. The client uploads the file to that URL with the returned headers,
+// then calls FinalizeUpload.
+func (h *ImageHandler) CreateUploadIntent(c *gin.Context) {
+	if h.uploads == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Direct uploads are not enabled"})
+		return
+	}
+	var req UploadIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input := services.UploadIntentInput{
+		EntityType:  req.EntityType,
+		EntityID:    req.EntityID,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+	}
+	if req.EntityID == "" {
+		spaceID, err := uuid.Parse(req.SpaceID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "entity_id or space_id is required"})
+			return
+		}
+		input.SpaceID = spaceID
+	}
+	intent, err := h.uploads.CreateIntent(c.Request.Context(), currentUserID(c), input)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, intent)
+}
 
-	if entityIDs != "" {
-		ids := strings.Split(entityIDs, ",")
-		query = query.Where("entity_id IN ?", ids)
-	} else if entityID != "" {
-		query = query.Where("entity_id = ?", entityID)
+// FinalizeUpload checks an uploaded object, generates its variants and
+// BlurHash and attaches it to the entity named in the intent.
+func (h *ImageHandler) FinalizeUpload(c *gin.Context) {
+	if h.uploads == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Direct uploads are not enabled"})
+		return
 	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	ctx := c.Request.Context()
+	image, err := h.uploads.Finalize(ctx, currentUserID(c), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	response := []models.Image{*image}
+	h.imageURLs.Images(ctx, response)
+	c.JSON(http.StatusCreated, response[0])
+}
 
-	if entityType != "" {
-		query = query.Where("entity_type = ?", entityType)
+// deleteKeys removes objects written by a failed upload. It uses a
+// background context so cleanup still runs if the request was cancelled.
+func (h *ImageHandler) deleteKeys(keys []string) {
+	for _, key := range keys {
+		_ = h.storage.Delete(context.Background(), key)
+	}
+}
+
+// List images for an entity. The caller must be a member of the space each
+// entity belongs to.
+func (h *ImageHandler) List(c *gin.Context) {
+	entityType := c.Query("entity_type")
+	var ids []string
+	if entityIDs := c.Query("entity_ids"); entityIDs != "" { // comma separated
+		ids = strings.Split(entityIDs, ",")
+	} else if entityID := c.Query("entity_id"); entityID != "" {
+		ids = []string{entityID}
+	}
+	if entityType == "" || len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entity_type and entity_id or entity_ids are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := currentUserID(c)
+	for _, id := range ids {
+		if _, err := h.access.Check(ctx, userID, entityType, id); err != nil {
+			respondError(c, err)
+			return
+		}
 	}
 
 	var images []models.Image
-	if err := query.Order("sort_order ASC").Find(&images).Error; err != nil {
+	err := h.db.Where("entity_type = ? AND entity_id IN ?", entityType, ids).
+		Order("sort_order ASC").Find(&images).Error
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
 		return
 	}
 
+	h.imageURLs.Images(ctx, images)
 	c.JSON(http.StatusOK, images)
 }
 
@@ -162,13 +251,48 @@ type ReorderRequest struct {
 	IDs []string `json:"ids"`
 }
 
-// Reorder images
+// Reorder images. All IDs must belong to the same entity, and the caller
+// must be a member of that entity's space.
 func (h *ImageHandler) Reorder(c *gin.Context) {
 	var req ReorderRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	seen := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if _, err := uuid.Parse(id); err != nil || seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+			return
+		}
+		seen[id] = true
+	}
+
+	var images []models.Image
+	if err := h.db.Where("id IN ?", req.IDs).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch images"})
+		return
+	}
+	if len(images) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	entityID, entityType := images[0].EntityID, images[0].EntityType
+	for _, img := range images[1:] {
+		if img.EntityID != entityID || img.EntityType != entityType {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Images must belong to the same entity"})
+			return
+		}
+	}
+	if _, err := h.access.CheckWrite(c.Request.Context(), currentUserID(c), entityType, entityID); err != nil {
+		respondError(c, err)
+		return
+	}
+	// Checked after access so outsiders can't probe which IDs exist.
+	if len(images) != len(req.IDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
 
 	err := h.db.Transaction(func(tx *gorm.DB) error {
 		for i, id := range req.IDs {
@@ -197,13 +321,21 @@ func (h *ImageHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// Delete from R2
-	key := h.storage.KeyFromURL(image.FilePath)
-	if err := h.storage.Delete(c.Request.Context(), key); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete from storage: " + err.Error()})
+	if _, err := h.access.CheckWrite(c.Request.Context(), currentUserID(c), image.EntityType, image.EntityID); err != nil {
+		respondError(c, err)
 		return
 	}
 
+	// Delete the original and its variants from storage. KeyFromURL is a
+	// no-op for keys; it still accepts rows written before images were
+	// stored by key.
+	for _, key := range image.Keys() {
+		if err := h.storage.Delete(c.Request.Context(), h.storage.KeyFromURL(key)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete from storage: " + err.Error()})
+			return
+		}
+	}
+
 	if err := h.db.Delete(&image).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete record"})
 		return
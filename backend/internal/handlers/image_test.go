@@ -3,13 +3,17 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"image"
+	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"lovelion/internal/models"
+	"lovelion/internal/services"
 	"lovelion/internal/storage"
 	"lovelion/internal/testutil"
 
@@ -46,11 +50,19 @@ func mockS3Client(t *testing.T) (*s3.Client, *httptest.Server) {
 	}), server
 }
 
+// fakePresigner signs keys deterministically so tests can assert on URLs.
+type fakePresigner struct{}
+
+func (fakePresigner) PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://signed.test/" + key, nil
+}
+
 func TestImageHandler_List(t *testing.T) {
 	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
 
 	// Create dummy data
-	entityID := "space_123"
+	entityID := createTestSpace(t, db, user.ID)
 	entityType := "space"
 
 	images := []models.Image{
@@ -58,14 +70,14 @@ func TestImageHandler_List(t *testing.T) {
 			ID:         uuid.New(),
 			EntityID:   entityID,
 			EntityType: entityType,
-			FilePath:   "https://example.com/img1.jpg",
+			FilePath:   "space/img1.jpg",
 			SortOrder:  1,
 		},
 		{
 			ID:         uuid.New(),
 			EntityID:   entityID,
 			EntityType: entityType,
-			FilePath:   "https://example.com/img2.jpg",
+			FilePath:   "space/img2.jpg",
 			SortOrder:  0,
 		},
 	}
@@ -77,9 +89,9 @@ func TestImageHandler_List(t *testing.T) {
 	}
 
 	// Setup handler
-	handler := NewImageHandler(db, nil)
+	handler := NewImageHandler(db, nil).WithImageURLs(services.NewImageURLSigner(fakePresigner{}, time.Minute))
 	router := testutil.TestRouter()
-	router.GET("/api/images", handler.List)
+	router.GET("/api/images", testutil.AuthContext(user.ID), handler.List)
 
 	// Test case 1: List by entity_id and entity_type
 	w := httptest.NewRecorder()
@@ -91,31 +103,58 @@ func TestImageHandler_List(t *testing.T) {
 	testutil.ParseResponse(t, w, &result)
 
 	if len(result) != 2 {
-		t.Errorf("Expected 2 images, got %d", len(result))
+		t.Fatalf("Expected 2 images, got %d", len(result))
 	}
 
 	// Check sorting (should be by SortOrder ASC, so img2 (0) then img1 (1))
 	if result[0].SortOrder != 0 {
 		t.Error("Images should be sorted by sort_order ASC")
 	}
+	if result[0].FilePath != "space/img2.jpg" || result[0].URL != "https://signed.test/space/img2.jpg" {
+		t.Errorf("Expected key and signed URL, got %q / %q", result[0].FilePath, result[0].URL)
+	}
+}
+
+func TestImageHandler_List_RequiresMembership(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	outsider := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, owner.ID)
+
+	handler := NewImageHandler(db, nil)
+	router := testutil.TestRouter()
+	router.GET("/api/images", testutil.AuthContext(outsider.ID), handler.List)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, testutil.JSONRequest("GET", "/api/images?entity_id="+spaceID+"&entity_type=space", nil))
+	testutil.ExpectStatus(t, w, http.StatusForbidden)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, testutil.JSONRequest("GET", "/api/images?entity_id=missing&entity_type=transaction", nil))
+	testutil.ExpectStatus(t, w, http.StatusNotFound)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, testutil.JSONRequest("GET", "/api/images?entity_id="+spaceID, nil))
+	testutil.ExpectStatus(t, w, http.StatusBadRequest)
 }
 
 func TestImageHandler_Reorder(t *testing.T) {
 	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
 
 	// Create dummy data
-	entityID := "space_123"
+	entityID := createTestSpace(t, db, user.ID)
 	entityType := "space"
 
-	img1 := models.Image{ID: uuid.New(), EntityID: entityID, EntityType: entityType, SortOrder: 0}
-	img2 := models.Image{ID: uuid.New(), EntityID: entityID, EntityType: entityType, SortOrder: 1}
+	img1 := models.Image{ID: uuid.New(), EntityID: entityID, EntityType: entityType, FilePath: "space/1.jpg", SortOrder: 0}
+	img2 := models.Image{ID: uuid.New(), EntityID: entityID, EntityType: entityType, FilePath: "space/2.jpg", SortOrder: 1}
 
 	db.Create(&img1)
 	db.Create(&img2)
 
 	handler := NewImageHandler(db, nil)
 	router := testutil.TestRouter()
-	router.POST("/api/images/reorder", handler.Reorder)
+	router.POST("/api/images/reorder", testutil.AuthContext(user.ID), handler.Reorder)
 
 	// Reorder swap
 	reqBody := ReorderRequest{
@@ -140,8 +179,65 @@ func TestImageHandler_Reorder(t *testing.T) {
 	}
 }
 
+func TestImageHandler_Reorder_RejectsMixedEntitiesAndOutsiders(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	outsider := testutil.CreateTestUser(t, db)
+	spaceA := createTestSpace(t, db, owner.ID)
+	spaceB := createTestSpace(t, db, owner.ID)
+
+	imgA := models.Image{ID: uuid.New(), EntityID: spaceA, EntityType: "space", FilePath: "space/a.jpg"}
+	imgB := models.Image{ID: uuid.New(), EntityID: spaceB, EntityType: "space", FilePath: "space/b.jpg"}
+	db.Create(&imgA)
+	db.Create(&imgB)
+
+	handler := NewImageHandler(db, nil)
+	ownerRouter := testutil.TestRouter()
+	ownerRouter.POST("/api/images/reorder", testutil.AuthContext(owner.ID), handler.Reorder)
+	outsiderRouter := testutil.TestRouter()
+	outsiderRouter.POST("/api/images/reorder", testutil.AuthContext(outsider.ID), handler.Reorder)
+
+	w := httptest.NewRecorder()
+	ownerRouter.ServeHTTP(w, testutil.JSONRequest("POST", "/api/images/reorder", ReorderRequest{
+		IDs: []string{imgA.ID.String(), imgB.ID.String()},
+	}))
+	testutil.ExpectStatus(t, w, http.StatusBadRequest)
+
+	w = httptest.NewRecorder()
+	outsiderRouter.ServeHTTP(w, testutil.JSONRequest("POST", "/api/images/reorder", ReorderRequest{
+		IDs: []string{imgA.ID.String()},
+	}))
+	testutil.ExpectStatus(t, w, http.StatusForbidden)
+}
+
+func TestImageHandler_Delete_RequiresMembership(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	outsider := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, owner.ID)
+
+	img := models.Image{ID: uuid.New(), EntityID: spaceID, EntityType: "space", FilePath: "space/a.jpg"}
+	db.Create(&img)
+
+	handler := NewImageHandler(db, nil)
+	router := testutil.TestRouter()
+	router.DELETE("/api/images/:id", testutil.AuthContext(outsider.ID), handler.Delete)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, testutil.JSONRequest("DELETE", "/api/images/"+img.ID.String(), nil))
+	testutil.ExpectStatus(t, w, http.StatusForbidden)
+
+	var count int64
+	db.Model(&models.Image{}).Where("id = ?", img.ID).Count(&count)
+	if count != 1 {
+		t.Error("Image should not be deleted by a non-member")
+	}
+}
+
 func TestImageHandler_Upload(t *testing.T) {
 	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, user.ID)
 	s3Client, server := mockS3Client(t)
 	defer server.Close()
 
@@ -149,7 +245,7 @@ func TestImageHandler_Upload(t *testing.T) {
 	handler := NewImageHandler(db, r2)
 
 	router := testutil.TestRouter()
-	router.POST("/api/images", handler.Upload)
+	router.POST("/api/images", testutil.AuthContext(user.ID), handler.Upload)
 
 	// Create multipart form
 	body := &bytes.Buffer{}
@@ -159,10 +255,13 @@ func TestImageHandler_Upload(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	io.Copy(part, bytes.NewBufferString("fake image content"))
+	photo := image.NewRGBA(image.Rect(0, 0, 1000, 800))
+	if err := jpeg.Encode(part, photo, nil); err != nil {
+		t.Fatal(err)
+	}
 
-	writer.WriteField("entity_id", "trip_123")
-	writer.WriteField("entity_type", "trip")
+	writer.WriteField("entity_id", spaceID)
+	writer.WriteField("entity_type", "space")
 	writer.Close()
 
 	req := httptest.NewRequest("POST", "/api/images", body)
@@ -176,7 +275,137 @@ func TestImageHandler_Upload(t *testing.T) {
 	var created models.Image
 	testutil.ParseResponse(t, w, &created)
 
-	if created.EntityID != "trip_123" {
-		t.Errorf("Expected EntityID trip_123, got %s", created.EntityID)
+	if created.EntityID != spaceID {
+		t.Errorf("Expected EntityID %s, got %s", spaceID, created.EntityID)
+	}
+	if created.FilePath != "space/"+created.ID.String()+".jpg" {
+		t.Errorf("Expected storage key in file_path, got %s", created.FilePath)
+	}
+	if created.ThumbPath != "space/"+created.ID.String()+"_thumb.jpg" {
+		t.Errorf("Expected thumb key, got %q", created.ThumbPath)
+	}
+	if created.MediumPath != "" {
+		t.Errorf("1000px originals need no medium variant, got %q", created.MediumPath)
+	}
+	if created.BlurHash == "" {
+		t.Error("Expected a BlurHash")
+	}
+}
+
+func TestImageHandler_Upload_RejectsUndecodableFile(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, user.ID)
+	s3Client, server := mockS3Client(t)
+	defer server.Close()
+
+	handler := NewImageHandler(db, storage.NewR2StorageFromClient(s3Client, "test-bucket", "https://r2.example.com"))
+	router := testutil.TestRouter()
+	router.POST("/api/images", testutil.AuthContext(user.ID), handler.Upload)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.jpg")
+	io.Copy(part, bytes.NewBufferString("fake image content"))
+	writer.WriteField("entity_id", spaceID)
+	writer.WriteField("entity_type", "space")
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/images", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	testutil.ExpectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestImageHandler_Upload_SniffsContentNotExtension(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, user.ID)
+	s3Client, server := mockS3Client(t)
+	defer server.Close()
+
+	handler := NewImageHandler(db, storage.NewR2StorageFromClient(s3Client, "test-bucket", "https://r2.example.com"))
+	router := testutil.TestRouter()
+	router.POST("/api/images", testutil.AuthContext(user.ID), handler.Upload)
+
+	upload := func(name string, write func(io.Writer)) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", name)
+		write(part)
+		writer.WriteField("entity_id", spaceID)
+		writer.WriteField("entity_type", "space")
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/images", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A real JPEG under a misleading name is accepted and stored as .jpg.
+	w := upload("IMG_0042.HEIC", func(w io.Writer) {
+		jpeg.Encode(w, image.NewRGBA(image.Rect(0, 0, 64, 64)), nil)
+	})
+	testutil.ExpectStatus(t, w, http.StatusCreated)
+	var created models.Image
+	testutil.ParseResponse(t, w, &created)
+	if created.FilePath != "space/"+created.ID.String()+".jpg" {
+		t.Errorf("Expected a .jpg key, got %s", created.FilePath)
+	}
+
+	// A GIF named .png is rejected.
+	w = upload("sneaky.png", func(w io.Writer) {
+		io.WriteString(w, "GIF89a\x01\x00\x01\x00")
+	})
+	testutil.ExpectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestImageHandler_DirectUpload(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, user.ID)
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://api.test", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewImageHandler(db, local).WithDirectUploads(services.NewDirectUploads(db, local))
+
+	router := testutil.TestRouter()
+	router.PUT(storage.FilesRoute+"*key", NewFilesHandler(local).Upload)
+	router.POST("/api/images/uploads", testutil.AuthContext(user.ID), handler.CreateUploadIntent)
+	router.POST("/api/images/uploads/:id/finalize", testutil.AuthContext(user.ID), handler.FinalizeUpload)
+
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 400, 300)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, testutil.JSONRequest("POST", "/api/images/uploads", map[string]any{
+		"entity_type": "space", "entity_id": spaceID,
+		"content_type": "image/jpeg", "size": photo.Len(),
+	}))
+	testutil.ExpectStatus(t, w, 201)
+	var intent services.UploadIntent
+	testutil.ParseResponse(t, w, &intent)
+
+	req := httptest.NewRequest(intent.Method, intent.UploadURL, bytes.NewReader(photo.Bytes()))
+	for k, v := range intent.Headers {
+		req.Header.Set(k, v)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	testutil.ExpectStatus(t, w, 200)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, testutil.JSONRequest("POST", "/api/images/uploads/"+intent.ID.String()+"/finalize", nil))
+	testutil.ExpectStatus(t, w, 201)
+	var created models.Image
+	testutil.ParseResponse(t, w, &created)
+	if created.EntityID != spaceID || created.BlurHash == "" {
+		t.Errorf("Expected a space image with a BlurHash, got %+v", created)
 	}
 }
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"lovelion/internal/models"
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MemberSummaryHandler struct {
+	db *gorm.DB
+}
+
+func NewMemberSummaryHandler(db *gorm.DB) *MemberSummaryHandler {
+	return &MemberSummaryHandler{db: db}
+}
+
+// Me returns the current user's paid total, share, balances and spend by
+// category in the space
+func (h *MemberSummaryHandler) Me(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	summary, err := services.SpaceMemberSummary(c.Request.Context(), h.db, space.ID, currentUserID(c))
+	if err != nil {
+		slog.Error("member summary failed", "space_id", space.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -54,6 +54,7 @@ func (h *PaymentHandler) Create(c *gin.Context) {
 		TotalAmount: req.TotalAmount,
 		PayerName:   req.PayerName,
 		PayeeName:   req.PayeeName,
+		CreatedBy:   currentUserID(c),
 	})
 	if err != nil {
 		respondError(c, err)
@@ -6,6 +6,7 @@ import (
 	"lovelion/internal/utils/errorx"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // respondError maps an AppError to the appropriate HTTP status and JSON response.
@@ -26,3 +27,14 @@ func respondError(c *gin.Context, err error) {
 
 	c.JSON(status, gin.H{"error": err.Error()})
 }
+
+// currentUserID returns the authenticated user set by AuthRequiredWithDB, or
+// uuid.Nil when the route isn't authenticated.
+func currentUserID(c *gin.Context) uuid.UUID {
+	if v, ok := c.Get("userID"); ok {
+		if id, ok := v.(uuid.UUID); ok {
+			return id
+		}
+	}
+	return uuid.Nil
+}
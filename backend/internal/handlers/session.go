@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SessionHandler struct {
+	sessions *services.Sessions
+}
+
+func NewSessionHandler(sessions *services.Sessions) *SessionHandler {
+	return &SessionHandler{sessions: sessions}
+}
+
+// currentSessionID is the session of the request's access token, as set by
+// AuthRequiredWithDB, which rejects tokens without one.
+func currentSessionID(c *gin.Context) uuid.UUID {
+	id, _ := c.Get("sessionID")
+	sessionID, _ := id.(uuid.UUID)
+	return sessionID
+}
+
+// List the current user's active sessions
+func (h *SessionHandler) List(c *gin.Context) {
+	sessions, err := h.sessions.List(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	current := currentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// Revoke one session
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("session_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Session ID"})
+		return
+	}
+
+	if err := h.sessions.Revoke(c.Request.Context(), currentUserID(c), sessionID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAll ends every session, or every other one with ?except_current=true
+func (h *SessionHandler) RevokeAll(c *gin.Context) {
+	keep := uuid.Nil
+	if c.Query("except_current") == "true" {
+		keep = currentSessionID(c)
+	}
+
+	revoked, err := h.sessions.RevokeAll(c.Request.Context(), currentUserID(c), keep)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
@@ -3,9 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"lovelion/internal/models"
+	"lovelion/internal/repositories"
+	"lovelion/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,13 +17,20 @@ import (
 )
 
 type SpaceHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	imageURLs *services.ImageURLSigner
 }
 
 func NewSpaceHandler(db *gorm.DB) *SpaceHandler {
 	return &SpaceHandler{db: db}
 }
 
+// WithImageURLs signs space images and cover image URLs in responses.
+func (h *SpaceHandler) WithImageURLs(signer *services.ImageURLSigner) *SpaceHandler {
+	h.imageURLs = signer
+	return h
+}
+
 type CreateSpaceRequest struct {
 	Name           string     `json:"name" binding:"required,min=1,max=100"`
 	Description    string     `json:"description"`
@@ -33,6 +43,8 @@ type CreateSpaceRequest struct {
 	StartDate      *time.Time `json:"start_date"`
 	EndDate        *time.Time `json:"end_date"`
 	IsPinned       bool       `json:"is_pinned"`
+	AILocale       string     `json:"ai_locale"`
+	AIInstructions string     `json:"ai_instructions"`
 }
 
 type UpdateSpaceRequest struct {
@@ -46,6 +58,8 @@ type UpdateSpaceRequest struct {
 	StartDate      *time.Time `json:"start_date"`
 	EndDate        *time.Time `json:"end_date"`
 	IsPinned       *bool      `json:"is_pinned"`
+	AILocale       string     `json:"ai_locale"`
+	AIInstructions *string    `json:"ai_instructions"`
 }
 
 func toJSON(v interface{}) (datatypes.JSON, error) {
@@ -59,10 +73,12 @@ func toJSON(v interface{}) (datatypes.JSON, error) {
 	return datatypes.JSON(bytes), nil
 }
 
-// List user's spaces
+// List user's spaces. Archived spaces are left out unless asked for with
+// ?status=archived or ?status=all.
 func (h *SpaceHandler) List(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 	spaceType := c.Query("type")
+	status := c.Query("status") // "" hides archived spaces, "all" shows everything
 
 	query := h.db.
 		Joins("JOIN space_members ON space_members.space_id = spaces.id").
@@ -74,6 +90,13 @@ func (h *SpaceHandler) List(c *gin.Context) {
 	if spaceType != "" {
 		query = query.Where("spaces.type = ?", spaceType)
 	}
+	switch status {
+	case "":
+		query = query.Where("spaces.status <> ?", models.SpaceStatusArchived)
+	case "all":
+	default:
+		query = query.Where("spaces.status = ?", status)
+	}
 
 	var spaces []models.Space
 	err := query.Order("spaces.is_pinned DESC, spaces.created_at DESC").
@@ -92,7 +115,7 @@ func (h *SpaceHandler) List(c *gin.Context) {
 
 	var results []spaceResponse
 	for i := range spaces {
-		spaces[i].PopulateCoverImage()
+		h.imageURLs.Space(c.Request.Context(), &spaces[i])
 
 		role := ""
 		memberCount := 0
@@ -123,17 +146,28 @@ func (h *SpaceHandler) Create(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.AIInstructions = strings.TrimSpace(req.AIInstructions)
+	if err := services.ValidatePromptSettings(req.AILocale, req.AIInstructions); err != nil {
+		respondError(c, err)
+		return
+	}
 
 	space := &models.Space{
-		ID:           uuid.New(),
-		UserID:       userID,
-		Name:         req.Name,
-		Description:  req.Description,
-		Type:         req.Type,
-		BaseCurrency: req.BaseCurrency,
-		StartDate:    req.StartDate,
-		EndDate:      req.EndDate,
-		IsPinned:     req.IsPinned,
+		ID:             uuid.New(),
+		UserID:         userID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Type:           req.Type,
+		BaseCurrency:   req.BaseCurrency,
+		StartDate:      req.StartDate,
+		EndDate:        req.EndDate,
+		IsPinned:       req.IsPinned,
+		AILocale:       req.AILocale,
+		AIInstructions: req.AIInstructions,
+	}
+
+	if space.AILocale == "" {
+		space.AILocale = services.DefaultPromptLocale
 	}
 
 	if space.BaseCurrency == "" {
@@ -178,7 +212,7 @@ func (h *SpaceHandler) Create(c *gin.Context) {
 			ID:      uuid.New(),
 			SpaceID: space.ID,
 			UserID:  userID,
-			Role:    "owner",
+			Role:    models.SpaceRoleOwner,
 		}
 
 		if err := tx.Create(member).Error; err != nil {
@@ -198,7 +232,9 @@ func (h *SpaceHandler) Create(c *gin.Context) {
 
 // Get a single space
 func (h *SpaceHandler) Get(c *gin.Context) {
-	space, _ := c.Get("space")
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+	h.imageURLs.Space(c.Request.Context(), space)
 	c.JSON(http.StatusOK, space)
 }
 
@@ -212,6 +248,14 @@ func (h *SpaceHandler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	instructions := space.AIInstructions
+	if req.AIInstructions != nil {
+		instructions = strings.TrimSpace(*req.AIInstructions)
+	}
+	if err := services.ValidatePromptSettings(req.AILocale, instructions); err != nil {
+		respondError(c, err)
+		return
+	}
 
 	if req.Name != "" {
 		space.Name = req.Name
@@ -251,6 +295,10 @@ func (h *SpaceHandler) Update(c *gin.Context) {
 	if req.IsPinned != nil {
 		space.IsPinned = *req.IsPinned
 	}
+	if req.AILocale != "" {
+		space.AILocale = req.AILocale
+	}
+	space.AIInstructions = instructions
 
 	if err := h.db.Save(&space).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update space"})
@@ -259,7 +307,7 @@ func (h *SpaceHandler) Update(c *gin.Context) {
 
 	// Reload images for response
 	h.db.Where("entity_id = ? AND entity_type = ?", space.ID, "space").Find(&space.Images)
-	space.PopulateCoverImage()
+	h.imageURLs.Space(c.Request.Context(), space)
 
 	c.JSON(http.StatusOK, space)
 }
@@ -295,19 +343,19 @@ func (h *SpaceHandler) Leave(c *gin.Context) {
 	h.db.Model(&models.SpaceMember{}).Where("space_id = ?", space.ID).Count(&memberCount)
 
 	// If owner and only member, suggest deleting instead
-	if member.Role == "owner" && memberCount == 1 {
+	if member.Role == models.SpaceRoleOwner && memberCount == 1 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "You are the only member and owner. Please delete the space instead of leaving."})
 		return
 	}
 
 	// If owner but there are others, force transfer ownership or block
-	if member.Role == "owner" && memberCount > 1 {
+	if member.Role == models.SpaceRoleOwner && memberCount > 1 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "You are the owner. Please transfer ownership before leaving or delete the space."})
 		return
 	}
 
-	// Proceed to remove membership
-	if err := h.db.Delete(&member).Error; err != nil {
+	// Proceed to remove membership, with any ownership offer made to them
+	if _, err := repositories.NewMemberRepo(h.db).Delete(c.Request.Context(), space.ID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave space"})
 		return
 	}
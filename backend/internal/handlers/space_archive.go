@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSpaceArchiveSize bounds an uploaded archive. Images make up nearly all
+// of it, so this leaves room for a few hundred receipts.
+const maxSpaceArchiveSize = 500 * 1024 * 1024
+
+type SpaceArchiveHandler struct {
+	archiver  *services.SpaceArchiver
+	imageURLs *services.ImageURLSigner
+}
+
+func NewSpaceArchiveHandler(archiver *services.SpaceArchiver) *SpaceArchiveHandler {
+	return &SpaceArchiveHandler{archiver: archiver}
+}
+
+// WithImageURLs signs the imported space's image URLs in the response.
+func (h *SpaceArchiveHandler) WithImageURLs(signer *services.ImageURLSigner) *SpaceArchiveHandler {
+	h.imageURLs = signer
+	return h
+}
+
+// Export streams the space as a zip archive.
+func (h *SpaceArchiveHandler) Export(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	archive, err := h.archiver.Manifest(c.Request.Context(), space.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.zip", space.Name, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Status(http.StatusOK)
+	// Headers are already sent once the zip starts, so a failure can only
+	// cut the download short.
+	if err := h.archiver.WriteArchive(c.Request.Context(), archive, c.Writer); err != nil {
+		slog.Error("space export failed", "space_id", space.ID, "error", err)
+		c.Abort()
+	}
+}
+
+// Import recreates an exported space, owned by the current user.
+func (h *SpaceArchiveHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSpaceArchiveSize)
+	file, err := c.FormFile("archive")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive is required"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	space, err := h.archiver.Import(c.Request.Context(), currentUserID(c), f, file.Size)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.imageURLs.Space(c.Request.Context(), space)
+	c.JSON(http.StatusCreated, space)
+}
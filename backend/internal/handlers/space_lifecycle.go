@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"lovelion/internal/models"
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SpaceLifecycleHandler struct {
+	lifecycle *services.SpaceLifecycle
+}
+
+func NewSpaceLifecycleHandler(lifecycle *services.SpaceLifecycle) *SpaceLifecycleHandler {
+	return &SpaceLifecycleHandler{lifecycle: lifecycle}
+}
+
+type UpdateSpaceStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active closed archived"`
+}
+
+// UpdateStatus closes, archives or reopens a space
+func (h *SpaceLifecycleHandler) UpdateStatus(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	var req UpdateSpaceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.lifecycle.SetStatus(c.Request.Context(), space.ID, req.Status)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+type DuplicateSpaceRequest struct {
+	Name string `json:"name" binding:"max=100"`
+}
+
+// Duplicate starts a new space from this one's settings and templates
+func (h *SpaceLifecycleHandler) Duplicate(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	var req DuplicateSpaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.lifecycle.Duplicate(c.Request.Context(), currentUserID(c), space.ID, req.Name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
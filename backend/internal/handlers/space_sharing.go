@@ -25,9 +25,11 @@ func NewSpaceSharingHandler(inviteService *services.InviteService, memberRepo *r
 }
 
 type CreateInviteRequest struct {
-	IsOneTime bool       `json:"is_one_time"`
-	MaxUses   int        `json:"max_uses"`
-	ExpiresAt *time.Time `json:"expires_at"`
+	Role             string     `json:"role" binding:"omitempty,oneof=editor contributor viewer"`
+	RequiresApproval bool       `json:"requires_approval"`
+	IsOneTime        bool       `json:"is_one_time"`
+	MaxUses          int        `json:"max_uses"`
+	ExpiresAt        *time.Time `json:"expires_at"`
 }
 
 // Create an invite link
@@ -43,9 +45,11 @@ func (h *SpaceSharingHandler) CreateInvite(c *gin.Context) {
 	}
 
 	invite, err := h.inviteService.Create(c.Request.Context(), space.ID, userID, services.CreateInviteParams{
-		IsOneTime: req.IsOneTime,
-		MaxUses:   req.MaxUses,
-		ExpiresAt: req.ExpiresAt,
+		Role:             req.Role,
+		RequiresApproval: req.RequiresApproval,
+		IsOneTime:        req.IsOneTime,
+		MaxUses:          req.MaxUses,
+		ExpiresAt:        req.ExpiresAt,
 	})
 	if err != nil {
 		respondError(c, err)
@@ -73,10 +77,15 @@ func (h *SpaceSharingHandler) JoinSpace(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 	token := c.Param("token")
 
-	if err := h.inviteService.Join(c.Request.Context(), token, userID); err != nil {
+	request, err := h.inviteService.Join(c.Request.Context(), token, userID)
+	if err != nil {
 		respondError(c, err)
 		return
 	}
+	if request != nil {
+		c.JSON(http.StatusAccepted, request)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully joined the space"})
 }
@@ -109,6 +118,14 @@ func (h *SpaceSharingHandler) UpdateMemberAlias(c *gin.Context) {
 		return
 	}
 
+	// Anyone may name themselves; naming others is an editor's job.
+	memberVal, _ := c.Get("member")
+	requestor := memberVal.(*models.SpaceMember)
+	if requestor.UserID != targetUserID && !requestor.HasRole(models.SpaceRoleEditor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your role in this space does not allow this action"})
+		return
+	}
+
 	var req UpdateMemberRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -129,6 +146,40 @@ func (h *SpaceSharingHandler) UpdateMemberAlias(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Alias updated successfully"})
 }
 
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=editor contributor viewer"`
+}
+
+// Change a member's role
+func (h *SpaceSharingHandler) UpdateMemberRole(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+	targetUserID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid User ID"})
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := h.memberRepo.UpdateRole(c.Request.Context(), space.ID, targetUserID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found in this space"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
+
 // Remove a member
 func (h *SpaceSharingHandler) RemoveMember(c *gin.Context) {
 	requestorID := c.MustGet("userID").(uuid.UUID)
@@ -143,7 +194,7 @@ func (h *SpaceSharingHandler) RemoveMember(c *gin.Context) {
 		return
 	}
 
-	isOwner := requestorMember.Role == "owner"
+	isOwner := requestorMember.Role == models.SpaceRoleOwner
 	isSelf := requestorID == targetUserID
 
 	if !isOwner && !isSelf {
@@ -201,3 +252,55 @@ func (h *SpaceSharingHandler) RevokeInvite(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked successfully"})
 }
+
+// List pending join requests
+func (h *SpaceSharingHandler) ListJoinRequests(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	requests, err := h.inviteService.ListRequests(c.Request.Context(), space.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// Approve a join request
+func (h *SpaceSharingHandler) ApproveJoinRequest(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request ID"})
+		return
+	}
+
+	if err := h.inviteService.ApproveRequest(c.Request.Context(), space.ID, requestID, userID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Join request approved"})
+}
+
+// Reject a join request
+func (h *SpaceSharingHandler) RejectJoinRequest(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+	requestID, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Request ID"})
+		return
+	}
+
+	if err := h.inviteService.RejectRequest(c.Request.Context(), space.ID, requestID, userID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Join request rejected"})
+}
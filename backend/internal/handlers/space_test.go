@@ -2,10 +2,18 @@ package handlers
 
 import (
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"lovelion/internal/middleware"
+	"lovelion/internal/models"
+	"lovelion/internal/repositories"
+	"lovelion/internal/services"
 	"lovelion/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSpaceHandler_List(t *testing.T) {
@@ -22,6 +30,35 @@ func TestSpaceHandler_List(t *testing.T) {
 	testutil.ExpectStatus(t, w, 200)
 }
 
+func TestSpaceHandler_List_HidesArchived(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	activeID := createTestSpace(t, db, user.ID)
+	archivedID := createTestSpace(t, db, user.ID)
+	db.Model(&models.Space{}).Where("id = ?", archivedID).Update("status", models.SpaceStatusArchived)
+
+	router := testutil.TestRouter()
+	handler := NewSpaceHandler(db)
+	router.GET("/api/spaces", testutil.AuthContext(user.ID), handler.List)
+
+	list := func(path string) []string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, testutil.JSONRequest("GET", path, nil))
+		testutil.ExpectStatus(t, w, 200)
+		var spaces []map[string]interface{}
+		testutil.ParseResponse(t, w, &spaces)
+		var ids []string
+		for _, s := range spaces {
+			ids = append(ids, s["id"].(string))
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{activeID}, list("/api/spaces"))
+	assert.Equal(t, []string{archivedID}, list("/api/spaces?status=archived"))
+	assert.Len(t, list("/api/spaces?status=all"), 2)
+}
+
 func TestSpaceHandler_Create(t *testing.T) {
 	db := testutil.TestDB(t)
 	user := testutil.CreateTestUser(t, db)
@@ -56,6 +93,21 @@ func TestSpaceHandler_Create(t *testing.T) {
 			body:       map[string]interface{}{},
 			wantStatus: 400,
 		},
+		{
+			name:       "unsupported ai locale",
+			body:       map[string]interface{}{"name": "X", "ai_locale": "fr"},
+			wantStatus: 400,
+		},
+		{
+			name:       "ai instructions at the limit in runes",
+			body:       map[string]interface{}{"name": "X", "ai_locale": "ja", "ai_instructions": strings.Repeat("飲", services.MaxPromptInstructionsLength)},
+			wantStatus: 201,
+		},
+		{
+			name:       "ai instructions too long",
+			body:       map[string]interface{}{"name": "X", "ai_instructions": strings.Repeat("a", services.MaxPromptInstructionsLength+1)},
+			wantStatus: 400,
+		},
 	}
 
 	for _, tt := range tests {
@@ -155,3 +207,29 @@ func TestSpaceHandler_Delete(t *testing.T) {
 	router.ServeHTTP(w, req)
 	testutil.ExpectStatus(t, w, 200)
 }
+
+func TestSpaceSharingHandler_UpdateMemberAlias(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	viewer := testutil.CreateTestUser(t, db)
+	spaceID := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{
+		ID: uuid.New(), SpaceID: uuid.MustParse(spaceID), UserID: viewer.ID, Role: models.SpaceRoleViewer,
+	}).Error)
+
+	router := testutil.TestRouter()
+	handler := NewSpaceSharingHandler(nil, repositories.NewMemberRepo(db))
+	router.PATCH("/api/spaces/:id/members/:user_id", testutil.AuthContext(viewer.ID), middleware.SpaceAccess(db), handler.UpdateMemberAlias)
+
+	rename := func(userID uuid.UUID) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, testutil.JSONRequest("PATCH", "/api/spaces/"+spaceID+"/members/"+userID.String(), map[string]interface{}{"alias": "Vic"}))
+		return w.Code
+	}
+	assert.Equal(t, 200, rename(viewer.ID), "anyone may set their own alias")
+	assert.Equal(t, 403, rename(owner.ID), "only editors rename others")
+
+	var member models.SpaceMember
+	require.NoError(t, db.First(&member, "space_id = ? AND user_id = ?", spaceID, viewer.ID).Error)
+	assert.Equal(t, "Vic", member.Alias)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	"lovelion/internal/models"
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SpaceTransferHandler struct {
+	transfers *services.SpaceTransfers
+}
+
+func NewSpaceTransferHandler(transfers *services.SpaceTransfers) *SpaceTransferHandler {
+	return &SpaceTransferHandler{transfers: transfers}
+}
+
+type NominateOwnerRequest struct {
+	UserID uuid.UUID `json:"user_id" binding:"required"`
+}
+
+// Nominate offers the space to another member
+func (h *SpaceTransferHandler) Nominate(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	var req NominateOwnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.transfers.Nominate(c.Request.Context(), space.ID, currentUserID(c), req.UserID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer offered", "pending_owner_id": req.UserID})
+}
+
+// Cancel withdraws a pending offer
+func (h *SpaceTransferHandler) Cancel(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	if err := h.transfers.Cancel(c.Request.Context(), space.ID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer cancelled"})
+}
+
+// Accept takes over a space offered to the current user
+func (h *SpaceTransferHandler) Accept(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	updated, err := h.transfers.Accept(c.Request.Context(), space.ID, currentUserID(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// Decline turns down a space offered to the current user
+func (h *SpaceTransferHandler) Decline(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	if err := h.transfers.Decline(c.Request.Context(), space.ID, currentUserID(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer declined"})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"lovelion/internal/models"
+	"lovelion/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SuggestionHandler struct {
+	svc *services.SuggestionService
+}
+
+func NewSuggestionHandler(svc *services.SuggestionService) *SuggestionHandler {
+	return &SuggestionHandler{svc: svc}
+}
+
+// Suggest returns the category and payment method this space has used before
+// for a title. Optional repeated ?item= params help when the title is new.
+// GET /api/spaces/:id/suggest?title=全聯&item=牛奶
+func (h *SuggestionHandler) Suggest(c *gin.Context) {
+	spaceVal, _ := c.Get("space")
+	space := spaceVal.(*models.Space)
+
+	title := strings.TrimSpace(c.Query("title"))
+	items := c.QueryArray("item")
+	if title == "" && len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+
+	suggestion, err := h.svc.Suggest(c.Request.Context(), space.ID, services.SuggestQuery{
+		Title:     title,
+		ItemNames: items,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestion)
+}
@@ -0,0 +1,139 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+	"golang.org/x/image/draw"
+)
+
+// Metadata is what Process keeps from a photo's EXIF block before the block
+// itself is dropped by re-encoding.
+type Metadata struct {
+	// TakenAt is the capture time as shown on the camera's clock, stored as
+	// UTC like every other naive date in the app.
+	TakenAt *time.Time
+	GPS     *LatLng
+}
+
+type LatLng struct {
+	Lat float64
+	Lng float64
+}
+
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// embeddedEXIF returns the part of data goexif can parse: the whole file for
+// JPEG, the "Exif" item for HEIC and the EXIF chunk for WebP. It returns nil
+// when the format carries no EXIF.
+func embeddedEXIF(format string, data []byte) []byte {
+	switch format {
+	case FormatJPEG:
+		return data
+	case FormatHEIC:
+		// The Exif item starts with a 4-byte offset and then the usual
+		// "Exif\0\0" header; goexif reads from that header on.
+		if i := bytes.Index(data, []byte("Exif\x00\x00")); i >= 0 {
+			return data[i:]
+		}
+	case FormatWebP:
+		// RIFF chunks: 4-byte id, little-endian size, payload padded to
+		// an even length. The first chunk starts after "RIFF<size>WEBP".
+		for p := 12; p+8 <= len(data); {
+			size := int(binary.LittleEndian.Uint32(data[p+4:]))
+			end := p + 8 + size
+			if size < 0 || end > len(data) {
+				return nil
+			}
+			if string(data[p:p+4]) == "EXIF" {
+				return data[p+8 : end]
+			}
+			p = end + size%2
+		}
+	}
+	return nil
+}
+
+// readEXIF returns the orientation (1 when absent) and metadata from a JPEG
+// or a raw EXIF block. Data without EXIF, or with a block goexif can't
+// parse, yields defaults.
+func readEXIF(data []byte) (orientation int, meta Metadata) {
+	x, err := exif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 1, meta
+	}
+
+	orientation = 1
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if o, err := tag.Int(0); err == nil && o >= 1 && o <= 8 {
+			orientation = o
+		}
+	}
+
+	for _, name := range []exif.FieldName{exif.DateTimeOriginal, exif.DateTime} {
+		tag, err := x.Get(name)
+		if err != nil || tag.Format() != tiff.StringVal {
+			continue
+		}
+		s, _ := tag.StringVal()
+		if t, err := time.ParseInLocation(exifTimeLayout, strings.TrimRight(s, "\x00 "), time.UTC); err == nil {
+			meta.TakenAt = &t
+			break
+		}
+	}
+
+	if lat, lng, err := x.LatLong(); err == nil && (lat != 0 || lng != 0) {
+		meta.GPS = &LatLng{Lat: lat, Lng: lng}
+	}
+	return orientation, meta
+}
+
+// orient applies an EXIF orientation so the pixels are upright. Values 5-8
+// swap width and height.
+func orient(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	// Work on a zero-origin copy so the index math below stays simple.
+	in := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+	out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotated 90° clockwise to display
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90° counter-clockwise to display
+				sx, sy = w-1-y, x
+			}
+			si := in.PixOffset(sx, sy)
+			di := out.PixOffset(x, y)
+			copy(out.Pix[di:di+4], in.Pix[si:si+4])
+		}
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEXIF struct {
+	orientation    uint16
+	takenAt        string
+	lat, lng       [3]uint32 // degrees, minutes, seconds
+	latRef, lngRef string
+}
+
+// withEXIF inserts a little-endian EXIF APP1 segment right after the JPEG
+// SOI marker.
+func withEXIF(t *testing.T, jpegData []byte, e testEXIF) []byte {
+	t.Helper()
+	const (
+		ifd0   = 8
+		exifAt = ifd0 + 2 + 3*12 + 4
+		dateAt = exifAt + 2 + 12 + 4
+		gpsAt  = dateAt + 20
+		latAt  = gpsAt + 2 + 4*12 + 4
+		lngAt  = latAt + 24
+		end    = lngAt + 24
+	)
+	le := binary.LittleEndian
+	tiff := make([]byte, end)
+	copy(tiff, "II")
+	le.PutUint16(tiff[2:], 42)
+	le.PutUint32(tiff[4:], ifd0)
+
+	entry := func(at int, tag, typ uint16, count, value uint32) {
+		le.PutUint16(tiff[at:], tag)
+		le.PutUint16(tiff[at+2:], typ)
+		le.PutUint32(tiff[at+4:], count)
+		le.PutUint32(tiff[at+8:], value)
+	}
+	const short, long, ascii, rational = 3, 4, 2, 5
+
+	le.PutUint16(tiff[ifd0:], 3)
+	entry(ifd0+2, 0x0112, short, 1, uint32(e.orientation))
+	entry(ifd0+14, 0x8769, long, 1, exifAt)
+	entry(ifd0+26, 0x8825, long, 1, gpsAt)
+
+	le.PutUint16(tiff[exifAt:], 1)
+	entry(exifAt+2, 0x9003, ascii, 20, dateAt)
+	copy(tiff[dateAt:], e.takenAt)
+
+	le.PutUint16(tiff[gpsAt:], 4)
+	entry(gpsAt+2, 1, ascii, 2, uint32(e.latRef[0]))
+	entry(gpsAt+14, 2, rational, 3, latAt)
+	entry(gpsAt+26, 3, ascii, 2, uint32(e.lngRef[0]))
+	entry(gpsAt+38, 4, rational, 3, lngAt)
+	for i := 0; i < 3; i++ {
+		le.PutUint32(tiff[latAt+i*8:], e.lat[i])
+		le.PutUint32(tiff[latAt+i*8+4:], 1)
+		le.PutUint32(tiff[lngAt+i*8:], e.lng[i])
+		le.PutUint32(tiff[lngAt+i*8+4:], 1)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	var out bytes.Buffer
+	out.Write(jpegData[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(jpegData[2:])
+	return out.Bytes()
+}
+
+func TestReadEXIF_NoEXIF(t *testing.T) {
+	orientation, meta := readEXIF(testJPEG(t, 4, 4))
+	if orientation != 1 || meta.TakenAt != nil || meta.GPS != nil {
+		t.Fatalf("expected defaults, got %d %+v", orientation, meta)
+	}
+}
+
+func TestEmbeddedEXIF_WebPChunk(t *testing.T) {
+	chunk := func(id string, payload []byte) []byte {
+		var b bytes.Buffer
+		b.WriteString(id)
+		binary.Write(&b, binary.LittleEndian, uint32(len(payload)))
+		b.Write(payload)
+		if len(payload)%2 == 1 {
+			b.WriteByte(0)
+		}
+		return b.Bytes()
+	}
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	body.Write(chunk("VP8X", make([]byte, 9))) // odd size exercises padding
+	body.Write(chunk("EXIF", []byte("II*\x00rest")))
+	data := append([]byte("RIFF\x00\x00\x00\x00"), body.Bytes()...)
+
+	assert.Equal(t, []byte("II*\x00rest"), embeddedEXIF(FormatWebP, data))
+	assert.Nil(t, embeddedEXIF(FormatWebP, data[:30]), "truncated chunk")
+	assert.Nil(t, embeddedEXIF(FormatPNG, data))
+}
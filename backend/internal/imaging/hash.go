@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// ContentHash is the hex SHA-256 of an upload as received, so the same file
+// uploaded twice always hashes the same whatever Process does with it.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// PerceptualHash is a 64-bit difference hash of img as 16 hex digits. It
+// survives re-encoding and resizing, so a receipt photo that went through a
+// messenger app still lands within a few bits of the original; compare two
+// hashes with HashDistance.
+func PerceptualHash(img image.Image) string {
+	// Shrink to 9×8 gray pixels and record whether each pixel is brighter
+	// than its right-hand neighbour.
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// HashDistance is the number of differing bits between two perceptual
+// hashes, or -1 if either isn't one.
+func HashDistance(a, b string) int {
+	x, errA := strconv.ParseUint(a, 16, 64)
+	y, errB := strconv.ParseUint(b, 16, 64)
+	if errA != nil || errB != nil {
+		return -1
+	}
+	return bits.OnesCount64(x ^ y)
+}
@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentHash(t *testing.T) {
+	data := testJPEG(t, 64, 48)
+	assert.Len(t, ContentHash(data), 64)
+	assert.Equal(t, ContentHash(data), ContentHash(append([]byte(nil), data...)))
+	assert.NotEqual(t, ContentHash(data), ContentHash(data[:len(data)-1]))
+}
+
+func TestPerceptualHash_SurvivesReencoding(t *testing.T) {
+	original := testJPEG(t, 800, 600)
+	res, err := Process(original, Options{})
+	require.NoError(t, err)
+	assert.Len(t, res.PerceptualHash, 16)
+
+	// The same picture, shrunk and recompressed the way a chat app would.
+	src, err := jpeg.Decode(bytes.NewReader(original))
+	require.NoError(t, err)
+	var smaller bytes.Buffer
+	require.NoError(t, jpeg.Encode(&smaller, resize(src, 400, 300), &jpeg.Options{Quality: 40}))
+	resent, err := Process(smaller.Bytes(), Options{})
+	require.NoError(t, err)
+	assert.NotEqual(t, res.ContentHash, resent.ContentHash)
+	assert.LessOrEqual(t, HashDistance(res.PerceptualHash, resent.PerceptualHash), 4)
+
+	// A different picture is far away.
+	other := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			other.Set(x, y, color.RGBA{uint8(255 - x), uint8(x ^ y), uint8(y), 255})
+		}
+	}
+	assert.Greater(t, HashDistance(res.PerceptualHash, PerceptualHash(other)), 10)
+}
+
+func TestHashDistance(t *testing.T) {
+	assert.Equal(t, 0, HashDistance("00000000000000ff", "00000000000000ff"))
+	assert.Equal(t, 8, HashDistance("0000000000000000", "00000000000000ff"))
+	assert.Equal(t, -1, HashDistance("", "00000000000000ff"))
+}
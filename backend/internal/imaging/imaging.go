@@ -0,0 +1,285 @@
+// Package imaging decodes uploaded photos and produces the resized variants
+// stored next to each original, so list views don't download full-size
+// phone photos. Every rendition is re-encoded from upright pixels, which
+// drops EXIF (including GPS) from anything that reaches storage. HEIC and
+// WebP uploads are transcoded to JPEG or PNG so every browser can show them.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/bbrks/go-blurhash"
+	"github.com/gen2brain/heic"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Longest side, in pixels, of each generated variant.
+const (
+	ThumbSize  = 320
+	MediumSize = 1280
+)
+
+const (
+	defaultJPEGQuality   = 90
+	defaultMaxMegapixels = 50
+)
+
+// Formats recognised by Sniff. Only FormatJPEG and FormatPNG are ever
+// stored.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+	FormatHEIC = "heic"
+)
+
+// ErrUnsupported is returned for data that isn't a decodable image in one of
+// the accepted formats.
+var ErrUnsupported = errors.New("imaging: unsupported or corrupt image")
+
+// ErrTooLarge is returned for images whose declared pixel dimensions exceed
+// Options.MaxMegapixels. It is checked from the header, before anything is
+// decoded.
+var ErrTooLarge = errors.New("imaging: image dimensions too large")
+
+// Options controls how originals are stored.
+type Options struct {
+	// MaxDimension downscales originals whose longest side exceeds it.
+	// Zero keeps originals at full size.
+	MaxDimension int
+	// MaxMegapixels rejects images with more pixels than this, in millions,
+	// before decoding them: a few compressed megabytes can declare a canvas
+	// that takes gigabytes to decode. Default 50.
+	MaxMegapixels int
+	JPEGQuality   int // default 90
+}
+
+// Encoded is one stored rendition of an image.
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Ext         string // ".jpg" or ".png"
+	Width       int
+	Height      int
+}
+
+// Result holds everything generated from one upload. Thumb and Medium are
+// nil when the original already fits within that size; clients then fall
+// back to the original.
+type Result struct {
+	Original Encoded
+	Thumb    *Encoded
+	Medium   *Encoded
+	BlurHash string
+	Metadata Metadata
+	// ContentHash identifies the exact upload, PerceptualHash the picture.
+	ContentHash    string
+	PerceptualHash string
+}
+
+// Sniff reports the format of data from its leading bytes, ignoring any
+// file name, or "" when it isn't one of the accepted formats.
+func Sniff(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && heicBrands[string(data[8:12])]:
+		return FormatHEIC
+	}
+	return ""
+}
+
+// heicBrands are the ISO-BMFF major brands used for HEIF/HEIC stills;
+// iPhones write "heic", other cameras often "mif1".
+var heicBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true,
+}
+
+// MimeType returns the content type of data as sniffed by Sniff, or "".
+func MimeType(data []byte) string {
+	return mimeTypes[Sniff(data)]
+}
+
+// Process decodes data, applies its EXIF orientation and builds the
+// original (downscaled when larger than opts.MaxDimension) plus its thumb
+// and medium variants. JPEG and PNG keep their format so transparency
+// survives; HEIC becomes JPEG and WebP becomes JPEG, or PNG when it has
+// alpha. The original is always re-encoded so no metadata is stored.
+func Process(data []byte, opts Options) (*Result, error) {
+	if opts.JPEGQuality <= 0 {
+		opts.JPEGQuality = defaultJPEGQuality
+	}
+	if opts.MaxMegapixels <= 0 {
+		opts.MaxMegapixels = defaultMaxMegapixels
+	}
+
+	input := Sniff(data)
+	cfg, err := decodeConfig(input, data)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > int64(opts.MaxMegapixels)*1_000_000 {
+		return nil, ErrTooLarge
+	}
+	src, err := decode(input, data)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	res := &Result{ContentHash: ContentHash(data)}
+	if block := embeddedEXIF(input, data); block != nil {
+		var orientation int
+		orientation, res.Metadata = readEXIF(block)
+		// libheif already applies the container's rotation while decoding,
+		// so the EXIF copy of it must not be applied twice.
+		if input != FormatHEIC {
+			src = orient(src, orientation)
+		}
+	}
+	format := outputFormat(input, src)
+
+	b := src.Bounds()
+	original := src
+	if w, h, ok := fit(b.Dx(), b.Dy(), opts.MaxDimension); ok {
+		original = resize(src, w, h)
+	}
+	enc, err := encode(original, format, opts.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+	res.Original = *enc
+
+	ob := original.Bounds()
+	// Each variant is scaled from the next size up, which is much cheaper
+	// than going back to a full-size original every time.
+	thumbSrc := original
+	if w, h, ok := fit(ob.Dx(), ob.Dy(), MediumSize); ok {
+		medium := resize(original, w, h)
+		if res.Medium, err = encode(medium, format, opts.JPEGQuality); err != nil {
+			return nil, err
+		}
+		thumbSrc = medium
+	}
+	if w, h, ok := fit(ob.Dx(), ob.Dy(), ThumbSize); ok {
+		thumbSrc = resize(thumbSrc, w, h)
+		if res.Thumb, err = encode(thumbSrc, format, opts.JPEGQuality); err != nil {
+			return nil, err
+		}
+	}
+
+	// The thumb is plenty for a 4x3 component hash and much faster to scan.
+	if hash, err := blurhash.Encode(4, 3, thumbSrc); err == nil {
+		res.BlurHash = hash
+	}
+	res.PerceptualHash = PerceptualHash(thumbSrc)
+	return res, nil
+}
+
+var (
+	mimeTypes = map[string]string{
+		FormatJPEG: "image/jpeg",
+		FormatPNG:  "image/png",
+		FormatWebP: "image/webp",
+		FormatHEIC: "image/heic",
+	}
+	exts = map[string]string{FormatJPEG: ".jpg", FormatPNG: ".png"}
+)
+
+func decode(format string, data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case FormatJPEG:
+		return jpeg.Decode(r)
+	case FormatPNG:
+		return png.Decode(r)
+	case FormatWebP:
+		return webp.Decode(r)
+	case FormatHEIC:
+		return heic.Decode(r)
+	}
+	return nil, ErrUnsupported
+}
+
+func decodeConfig(format string, data []byte) (image.Config, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case FormatJPEG:
+		return jpeg.DecodeConfig(r)
+	case FormatPNG:
+		return png.DecodeConfig(r)
+	case FormatWebP:
+		return webp.DecodeConfig(r)
+	case FormatHEIC:
+		return heic.DecodeConfig(r)
+	}
+	return image.Config{}, ErrUnsupported
+}
+
+// outputFormat picks the stored format for an upload.
+func outputFormat(input string, img image.Image) string {
+	switch input {
+	case FormatPNG:
+		return FormatPNG
+	case FormatWebP:
+		if o, ok := img.(interface{ Opaque() bool }); ok && !o.Opaque() {
+			return FormatPNG
+		}
+	}
+	return FormatJPEG
+}
+
+// fit scales w×h down to fit within max on its longest side. ok is false
+// when no resize is needed.
+func fit(w, h, max int) (int, int, bool) {
+	if max <= 0 || (w <= max && h <= max) {
+		return w, h, false
+	}
+	if w >= h {
+		return max, maxInt(1, h*max/w), true
+	}
+	return maxInt(1, w*max/h), max, true
+}
+
+func resize(src image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, format string, quality int) (*Encoded, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == FormatPNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	return &Encoded{
+		Data:        buf.Bytes(),
+		ContentType: mimeTypes[format],
+		Ext:         exts[format],
+		Width:       b.Dx(),
+		Height:      b.Dy(),
+	}, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
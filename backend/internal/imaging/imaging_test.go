@@ -0,0 +1,206 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestProcess_LargeJPEG(t *testing.T) {
+	res, err := Process(testJPEG(t, 4000, 3000), Options{MaxDimension: 2000})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2000, res.Original.Width)
+	assert.Equal(t, 1500, res.Original.Height)
+	assert.Equal(t, ".jpg", res.Original.Ext)
+	require.NotNil(t, res.Medium)
+	assert.Equal(t, MediumSize, res.Medium.Width)
+	assert.Equal(t, 960, res.Medium.Height)
+	require.NotNil(t, res.Thumb)
+	assert.Equal(t, ThumbSize, res.Thumb.Width)
+	assert.Equal(t, "image/jpeg", res.Thumb.ContentType)
+	assert.NotEmpty(t, res.BlurHash)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(res.Thumb.Data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, ThumbSize, cfg.Width)
+}
+
+func TestProcess_SmallPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 400))
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	res, err := Process(buf.Bytes(), Options{MaxDimension: 2000})
+	require.NoError(t, err)
+	assert.Equal(t, ".png", res.Original.Ext)
+	assert.Equal(t, 200, res.Original.Width)
+	assert.Nil(t, res.Medium)
+	require.NotNil(t, res.Thumb, "portrait 400px tall still gets a thumb")
+	assert.Equal(t, 160, res.Thumb.Width)
+	assert.Equal(t, ThumbSize, res.Thumb.Height)
+	assert.Equal(t, "image/png", res.Thumb.ContentType)
+	assert.Equal(t, Metadata{}, res.Metadata)
+}
+
+func TestProcess_AppliesOrientationAndStripsEXIF(t *testing.T) {
+	// A 40x20 landscape sensor image whose EXIF says "rotate 90° clockwise".
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{0, 0, 255, 255}
+			if x < 20 {
+				c = color.RGBA{255, 0, 0, 255} // left half red
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100}))
+	data := withEXIF(t, buf.Bytes(), testEXIF{
+		orientation: 6,
+		takenAt:     "2026:03:14 12:34:56",
+		lat:         [3]uint32{25, 2, 0}, latRef: "N",
+		lng: [3]uint32{121, 33, 0}, lngRef: "E",
+	})
+
+	res, err := Process(data, Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, res.Original.Width, "rotated to portrait")
+	assert.Equal(t, 40, res.Original.Height)
+	out, err := jpeg.Decode(bytes.NewReader(res.Original.Data))
+	require.NoError(t, err)
+	r, _, b, _ := out.At(10, 5).RGBA()
+	assert.Greater(t, r, b, "the sensor's left half ends up on top")
+	r, _, b, _ = out.At(10, 35).RGBA()
+	assert.Greater(t, b, r)
+
+	assert.NotContains(t, string(res.Original.Data), "Exif\x00\x00", "EXIF is not re-written")
+
+	require.NotNil(t, res.Metadata.TakenAt)
+	assert.Equal(t, time.Date(2026, 3, 14, 12, 34, 56, 0, time.UTC), *res.Metadata.TakenAt)
+	require.NotNil(t, res.Metadata.GPS)
+	assert.InDelta(t, 25.0333, res.Metadata.GPS.Lat, 0.001)
+	assert.InDelta(t, 121.55, res.Metadata.GPS.Lng, 0.001)
+}
+
+func TestOrient_AllValues(t *testing.T) {
+	// 3x2 image with a distinct value per pixel: index = y*3 + x.
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		src.Pix[i*4] = uint8(i)
+		src.Pix[i*4+3] = 0xff
+	}
+	// Expected top row of each result.
+	want := map[int][]uint8{
+		1: {0, 1, 2},
+		2: {2, 1, 0},
+		3: {5, 4, 3},
+		4: {3, 4, 5},
+		5: {0, 3},
+		6: {3, 0},
+		7: {5, 2},
+		8: {2, 5},
+	}
+	for o, row := range want {
+		out := orient(src, o)
+		var got []uint8
+		for x := 0; x < out.Bounds().Dx(); x++ {
+			r, _, _, _ := out.At(x, 0).RGBA()
+			got = append(got, uint8(r>>8))
+		}
+		assert.Equal(t, row, got, "orientation %d", o)
+	}
+}
+
+func TestProcess_RejectsGarbage(t *testing.T) {
+	_, err := Process([]byte("fake image content"), Options{})
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestProcess_RejectsHugeDimensionsBeforeDecoding(t *testing.T) {
+	// A PNG header declaring 30000x30000 with no pixel data behind it.
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 30000)
+	binary.BigEndian.PutUint32(data[20:], 30000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := Process(data, Options{})
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = Process(testJPEG(t, 400, 300), Options{MaxMegapixels: 1})
+	assert.NoError(t, err)
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestSniff(t *testing.T) {
+	assert.Equal(t, FormatJPEG, Sniff(testJPEG(t, 4, 4)))
+	assert.Equal(t, FormatWebP, Sniff(readFixture(t, "photo.webp")))
+	assert.Equal(t, FormatHEIC, Sniff(readFixture(t, "photo.heic")))
+	assert.Equal(t, "image/heic", MimeType(readFixture(t, "photo.heic")))
+	assert.Equal(t, "", Sniff([]byte("GIF89a")))
+	assert.Equal(t, "", Sniff(nil))
+}
+
+func TestProcess_TranscodesHEICToJPEG(t *testing.T) {
+	res, err := Process(readFixture(t, "photo.heic"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, ".jpg", res.Original.Ext)
+	assert.Equal(t, "image/jpeg", res.Original.ContentType)
+	assert.Positive(t, res.Original.Width)
+	assert.NotEmpty(t, res.BlurHash)
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(res.Original.Data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+}
+
+func TestProcess_TranscodesWebP(t *testing.T) {
+	res, err := Process(readFixture(t, "photo.webp"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, ".jpg", res.Original.Ext, "opaque WebP becomes JPEG")
+
+	res, err = Process(readFixture(t, "alpha.webp"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, ".png", res.Original.Ext, "transparent WebP keeps its alpha as PNG")
+}
+
+func TestProcess_IgnoresExtensionLookalikes(t *testing.T) {
+	// A PNG signature followed by junk must not get past decoding.
+	_, err := Process([]byte("\x89PNG\r\n\x1a\nnot really"), Options{})
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
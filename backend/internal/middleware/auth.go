@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"lovelion/internal/models"
 
@@ -16,7 +17,9 @@ func AuthRequired(jwtSecret string) gin.HandlerFunc {
 	return AuthRequiredWithDB(jwtSecret, nil)
 }
 
-// AuthRequiredWithDB validates JWT and optionally verifies user exists in database
+// AuthRequiredWithDB validates JWT and optionally verifies user exists in database.
+// With a database it also requires the token's session to be active, so
+// revoked sessions and tokens issued before sessions existed are rejected.
 func AuthRequiredWithDB(jwtSecret string, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -86,6 +89,38 @@ func AuthRequiredWithDB(jwtSecret string, db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
+		// Tokens name the session they were issued for in "sid"
+		sid, hasSession := claims["sid"].(string)
+		if hasSession {
+			sessionID, err := uuid.Parse(sid)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session in token"})
+				c.Abort()
+				return
+			}
+			c.Set("sessionID", sessionID)
+		}
+		if db != nil {
+			if !hasSession {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session required, please login again"})
+				c.Abort()
+				return
+			}
+			var session models.Session
+			err := db.Select("id", "revoked_at", "expires_at").
+				First(&session, "id = ? AND user_id = ?", c.MustGet("sessionID"), userID).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				c.Abort()
+				return
+			}
+			if err != nil || !session.Active(time.Now()) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				c.Abort()
+				return
+			}
+		}
+
 		// Set user ID in context
 		c.Set("userID", userID)
 		c.Next()
@@ -6,12 +6,15 @@ import (
 	"testing"
 	"time"
 
+	"lovelion/internal/models"
 	"lovelion/internal/testutil"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 const testSecret = "test-jwt-secret"
@@ -183,9 +186,28 @@ func TestAuthRequiredWithDB_UserNotFound(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "User not found")
 }
 
+func createTestSession(t *testing.T, db *gorm.DB, userID uuid.UUID) models.Session {
+	t.Helper()
+	session := models.Session{
+		ID: uuid.New(), UserID: userID, RefreshTokenHash: uuid.NewString(),
+		LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, db.Create(&session).Error)
+	return session
+}
+
+func sessionToken(userID, sessionID uuid.UUID) string {
+	return makeToken(jwt.MapClaims{
+		"user_id": userID.String(),
+		"sid":     sessionID.String(),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+}
+
 func TestAuthRequiredWithDB_UserExists(t *testing.T) {
 	db := testutil.TestDB(t)
 	user := testutil.CreateTestUser(t, db)
+	session := createTestSession(t, db, user.ID)
 
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -193,7 +215,7 @@ func TestAuthRequiredWithDB_UserExists(t *testing.T) {
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 	})
 
-	token := validUserToken(user.ID)
+	token := sessionToken(user.ID, session.ID)
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/test", nil)
 	req.Header.Set("Authorization", "Bearer "+token)
@@ -201,3 +223,48 @@ func TestAuthRequiredWithDB_UserExists(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestAuthRequiredWithDB_RevokedSession(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	session := createTestSession(t, db, user.ID)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/test", AuthRequiredWithDB(testSecret, db), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"session": c.MustGet("sessionID")})
+	})
+	token := sessionToken(user.ID, session.ID)
+	call := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call())
+	require.NoError(t, db.Model(&session).Update("revoked_at", time.Now()).Error)
+	assert.Equal(t, http.StatusUnauthorized, call())
+}
+
+func TestAuthRequiredWithDB_TokenWithoutSession(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/test", AuthRequiredWithDB(testSecret, db), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	// Issued before sessions existed: valid signature, no sid claim
+	token := validUserToken(user.ID)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Session required")
+}
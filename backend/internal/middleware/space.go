@@ -54,7 +54,6 @@ func SpaceAccess(db *gorm.DB) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		space.PopulateCoverImage()
 
 		// Store in context for handlers to use
 		c.Set("space", &space)
@@ -75,7 +74,7 @@ func SpaceOwnerOnly() gin.HandlerFunc {
 		}
 
 		member := memberVal.(*models.SpaceMember)
-		if member.Role != "owner" {
+		if member.Role != models.SpaceRoleOwner {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Only the space owner can perform this action"})
 			c.Abort()
 			return
@@ -84,3 +83,85 @@ func SpaceOwnerOnly() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SpaceRoleRequired restricts access to members whose role is at least role.
+// MUST be used after SpaceAccess middleware.
+func SpaceRoleRequired(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberVal, exists := c.Get("member")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Space context missing"})
+			c.Abort()
+			return
+		}
+
+		member := memberVal.(*models.SpaceMember)
+		if !member.HasRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your role in this space does not allow this action"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// SpaceTransactionEditor restricts changes to the transaction in :txn_id to
+// members allowed to edit it: editors and the owner always, contributors
+// only for transactions they created. A missing transaction is left to the
+// handler. MUST be used after SpaceAccess middleware.
+func SpaceTransactionEditor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberVal, exists := c.Get("member")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Space context missing"})
+			c.Abort()
+			return
+		}
+
+		member := memberVal.(*models.SpaceMember)
+		if member.HasRole(models.SpaceRoleEditor) {
+			c.Next()
+			return
+		}
+
+		var txn models.Transaction
+		err := db.Select("id", "created_by").
+			Where("id = ? AND space_id = ?", c.Param("txn_id"), member.SpaceID).
+			First(&txn).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.Abort()
+			return
+		}
+		if err == nil && !member.CanEditTransaction(txn.CreatedBy) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only change transactions you created"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// SpaceWritable rejects changes to closed and archived spaces.
+// MUST be used after SpaceAccess middleware.
+func SpaceWritable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spaceVal, exists := c.Get("space")
+		if !exists {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Space context missing"})
+			c.Abort()
+			return
+		}
+
+		space := spaceVal.(*models.Space)
+		if !space.Writable() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This space is " + space.Status + " and can no longer be changed"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
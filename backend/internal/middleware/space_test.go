@@ -192,3 +192,86 @@ func TestSpaceOwnerOnly_MissingContext(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Contains(t, w.Body.String(), "Space context missing")
 }
+
+func TestSpaceRoleRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		role, required string
+		want           int
+	}{
+		{models.SpaceRoleViewer, models.SpaceRoleContributor, http.StatusForbidden},
+		{models.SpaceRoleContributor, models.SpaceRoleContributor, http.StatusOK},
+		{models.SpaceRoleContributor, models.SpaceRoleEditor, http.StatusForbidden},
+		{models.SpaceRoleEditor, models.SpaceRoleEditor, http.StatusOK},
+		{models.SpaceRoleOwner, models.SpaceRoleEditor, http.StatusOK},
+		{"member", models.SpaceRoleViewer, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.GET("/test", func(c *gin.Context) {
+			c.Set("member", &models.SpaceMember{Role: tc.role})
+			c.Next()
+		}, SpaceRoleRequired(tc.required), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.want, w.Code, "%s on a %s route", tc.role, tc.required)
+	}
+}
+
+func TestSpaceTransactionEditor_ContributorOwnRowsOnly(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	contributor := testutil.CreateTestUser(t, db)
+
+	space := models.Space{ID: uuid.New(), UserID: owner.ID, Name: "Trip", Type: "trip", BaseCurrency: "TWD"}
+	db.Create(&space)
+	db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: models.SpaceRoleOwner})
+	db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: contributor.ID, Role: models.SpaceRoleContributor})
+	db.Create(&models.Transaction{ID: "txn_mine", SpaceID: space.ID, Title: "Mine", CreatedBy: &contributor.ID})
+	db.Create(&models.Transaction{ID: "txn_theirs", SpaceID: space.ID, Title: "Theirs", CreatedBy: &owner.ID})
+
+	gin.SetMode(gin.TestMode)
+	request := func(userID uuid.UUID, txnID string) int {
+		r := gin.New()
+		r.PUT("/spaces/:id/transactions/:txn_id", testutil.AuthContext(userID), SpaceAccess(db), SpaceTransactionEditor(db), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/spaces/"+space.ID.String()+"/transactions/"+txnID, nil)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request(contributor.ID, "txn_mine"))
+	assert.Equal(t, http.StatusForbidden, request(contributor.ID, "txn_theirs"))
+	assert.Equal(t, http.StatusOK, request(owner.ID, "txn_mine"))
+	assert.Equal(t, http.StatusOK, request(contributor.ID, "txn_missing"), "the handler reports missing transactions")
+}
+
+func TestSpaceWritable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for status, want := range map[string]int{
+		models.SpaceStatusActive:   http.StatusOK,
+		models.SpaceStatusClosed:   http.StatusForbidden,
+		models.SpaceStatusArchived: http.StatusForbidden,
+	} {
+		r := gin.New()
+		r.POST("/test", func(c *gin.Context) {
+			c.Set("space", &models.Space{Status: status})
+			c.Next()
+		}, SpaceWritable(), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/test", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Code, status)
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// AIExtractionAttempt records one extraction call for a transaction: what was
+// sent, what the model returned and the full error, which the user-facing
+// Transaction.AIError deliberately hides. Admin-only.
+type AIExtractionAttempt struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	TransactionID string         `gorm:"type:varchar(21);not null;index" json:"transaction_id"`
+	Source        string         `gorm:"type:varchar(20);not null" json:"source"` // image, text
+	Status        string         `gorm:"type:varchar(20);not null" json:"status"` // completed, retry, failed
+	PromptVersion string         `gorm:"type:varchar(50);not null;default:''" json:"prompt_version"`
+	Request       datatypes.JSON `gorm:"type:jsonb" json:"request"`
+	RawResponse   string         `gorm:"type:text;not null;default:''" json:"raw_response"`
+	Error         string         `gorm:"type:text;not null;default:''" json:"error"`
+	DurationMs    int64          `gorm:"not null;default:0" json:"duration_ms"`
+	ReparsedFrom  *uuid.UUID     `gorm:"type:uuid" json:"reparsed_from,omitempty"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (AIExtractionAttempt) TableName() string {
+	return "ai_extraction_attempts"
+}
@@ -6,14 +6,55 @@ import (
 	"github.com/google/uuid"
 )
 
+// Image is a file attached to a space, transaction or comparison store.
+// FilePath holds the storage key of the original and ThumbPath/MediumPath
+// the keys of its resized variants (empty when the original is already that
+// small). The bucket is private, so the URL fields are filled in with
+// short-lived signed links when a response is built.
 type Image struct {
 	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	EntityID   string    `gorm:"type:varchar(50);not null" json:"entity_id"`
 	EntityType string    `gorm:"type:varchar(50);not null" json:"entity_type"`
 	FilePath   string    `gorm:"type:text;not null" json:"file_path"`
+	ThumbPath  string    `gorm:"type:text" json:"thumb_path,omitempty"`
+	MediumPath string    `gorm:"type:text" json:"medium_path,omitempty"`
+	URL        string    `gorm:"-" json:"url,omitempty"`
+	ThumbURL   string    `gorm:"-" json:"thumb_url,omitempty"`
+	MediumURL  string    `gorm:"-" json:"medium_url,omitempty"`
 	BlurHash   string    `gorm:"type:varchar(100)" json:"blur_hash"`
 	SortOrder  int       `gorm:"default:0" json:"sort_order"`
 	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	// ContentHash is the SHA-256 of the upload as received and
+	// PerceptualHash a difference hash of the picture (see imaging); both
+	// are empty on images stored before hashing was added.
+	ContentHash    string `gorm:"type:varchar(64);index" json:"content_hash,omitempty"`
+	PerceptualHash string `gorm:"type:varchar(16)" json:"-"`
+
+	// Duplicates lists other transactions in the space with the same photo.
+	// Only set on upload responses.
+	Duplicates []DuplicateImage `gorm:"-" json:"duplicates,omitempty"`
+}
+
+// DuplicateImage points at an existing transaction whose image matches an
+// upload. Exact means the same file; otherwise the pictures are merely
+// perceptually close, e.g. the same receipt photographed or sent again.
+type DuplicateImage struct {
+	ImageID       uuid.UUID `json:"image_id"`
+	TransactionID string    `json:"transaction_id"`
+	Title         string    `json:"title"`
+	Date          time.Time `json:"date"`
+	Exact         bool      `json:"exact"`
+}
+
+// Keys returns the storage keys of the original and every variant.
+func (i *Image) Keys() []string {
+	keys := []string{i.FilePath}
+	for _, k := range []string{i.ThumbPath, i.MediumPath} {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
 }
 
 func (Image) TableName() string {
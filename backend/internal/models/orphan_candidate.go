@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Kinds of OrphanCandidate.
+const (
+	OrphanKindImage  = "image"  // Ref is an Image ID whose entity is gone
+	OrphanKindObject = "object" // Ref is a storage key no Image references
+)
+
+// OrphanCandidate remembers when the image GC first saw something orphaned,
+// so it is only deleted once it has stayed orphaned for the grace period.
+type OrphanCandidate struct {
+	Kind        string    `gorm:"type:varchar(16);primaryKey" json:"kind"`
+	Ref         string    `gorm:"type:text;primaryKey" json:"ref"`
+	FirstSeenAt time.Time `gorm:"not null;default:now()" json:"first_seen_at"`
+}
+
+func (OrphanCandidate) TableName() string {
+	return "orphan_candidates"
+}
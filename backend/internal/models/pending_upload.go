@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PendingUpload is an image the client is uploading straight to storage
+// through a presigned PUT. The object sits under Key until finalize checks
+// it and turns it into an Image; EntityID is empty for photos of an expense
+// that doesn't exist yet, which are attached when the expense is created.
+type PendingUpload struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	SpaceID     uuid.UUID `gorm:"type:uuid;not null" json:"space_id"`
+	EntityType  string    `gorm:"type:varchar(20);not null" json:"entity_type"`
+	EntityID    string    `gorm:"type:varchar(50);not null;default:''" json:"entity_id"`
+	Key         string    `gorm:"type:text;not null" json:"-"`
+	ContentType string    `gorm:"type:varchar(50);not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (PendingUpload) TableName() string {
+	return "pending_uploads"
+}
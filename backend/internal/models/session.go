@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one signed-in device. Access tokens name the session they were
+// issued for, so revoking it locks the device out once its current access
+// token is rejected by AuthRequiredWithDB. The refresh token is only stored
+// as a hash and is replaced on every refresh; PreviousTokenHash catches a
+// replaced token being used again.
+type Session struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"type:varchar(64);not null;default:'';index" json:"-"`
+	RotatedAt         *time.Time `gorm:"type:timestamptz" json:"-"`
+	UserAgent         string     `gorm:"type:varchar(255);not null;default:''" json:"user_agent"`
+	IP                string     `gorm:"type:varchar(45);not null;default:''" json:"ip"`
+	LastSeenAt        time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt         *time.Time `gorm:"type:timestamptz" json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	// Current marks the session making the request. Only set in listings.
+	Current bool `gorm:"-" json:"current"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// Active reports whether the session can still be used at t.
+func (s *Session) Active(t time.Time) bool {
+	return s.RevokedAt == nil && t.Before(s.ExpiresAt)
+}
@@ -22,8 +22,14 @@ type Space struct {
 	EndDate        *time.Time     `gorm:"type:date" json:"end_date"`
 	CoverImage     string         `gorm:"-" json:"cover_image"`
 	IsPinned       bool           `gorm:"default:false" json:"is_pinned"`
-	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	Status         string         `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	AILocale       string         `gorm:"type:varchar(10);not null;default:'zh-TW';column:ai_locale" json:"ai_locale"`
+	AIInstructions string         `gorm:"type:text;not null;default:'';column:ai_instructions" json:"ai_instructions"`
+	// PendingOwnerID is the member the owner has offered the space to,
+	// until they accept or decline.
+	PendingOwnerID *uuid.UUID `gorm:"type:uuid" json:"pending_owner_id"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// Associations
 	User         *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -33,10 +39,25 @@ type Space struct {
 	Images       []Image       `gorm:"polymorphic:Entity;polymorphicValue:space" json:"images,omitempty"`
 }
 
-// PopulateCoverImage sets CoverImage from the first associated image.
+// Space lifecycle states. A closed space is read-only and can only be closed
+// once every balance is settled; an archived one is also hidden from the
+// default space list.
+const (
+	SpaceStatusActive   = "active"
+	SpaceStatusClosed   = "closed"
+	SpaceStatusArchived = "archived"
+)
+
+// Writable reports whether the space's contents may still change.
+func (s *Space) Writable() bool {
+	return s.Status == "" || s.Status == SpaceStatusActive
+}
+
+// PopulateCoverImage sets CoverImage from the first associated image. Image
+// URLs must already be signed.
 func (s *Space) PopulateCoverImage() {
 	if len(s.Images) > 0 {
-		s.CoverImage = s.Images[0].FilePath
+		s.CoverImage = s.Images[0].MediumURL
 	}
 }
 
@@ -48,7 +69,7 @@ type SpaceMember struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	SpaceID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_space_user" json:"space_id"`
 	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_space_user" json:"user_id"`
-	Role      string    `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
+	Role      string    `gorm:"type:varchar(20);not null;default:'editor'" json:"role"`
 	Alias     string    `gorm:"type:varchar(50)" json:"alias"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -62,6 +83,44 @@ func (SpaceMember) TableName() string {
 	return "space_members"
 }
 
+// Space member roles, from most to least privileged. Editors can change
+// anything in the space except its settings and membership; contributors
+// can add transactions but only change the ones they created; viewers can
+// only read.
+const (
+	SpaceRoleOwner       = "owner"
+	SpaceRoleEditor      = "editor"
+	SpaceRoleContributor = "contributor"
+	SpaceRoleViewer      = "viewer"
+)
+
+var spaceRoleRank = map[string]int{
+	SpaceRoleViewer:      1,
+	SpaceRoleContributor: 2,
+	SpaceRoleEditor:      3,
+	SpaceRoleOwner:       4,
+}
+
+// IsSpaceRole reports whether role is one of the SpaceRole constants.
+func IsSpaceRole(role string) bool {
+	return spaceRoleRank[role] > 0
+}
+
+// HasRole reports whether the member's role is role or a more privileged one.
+func (m *SpaceMember) HasRole(role string) bool {
+	rank := spaceRoleRank[m.Role]
+	return rank > 0 && rank >= spaceRoleRank[role]
+}
+
+// CanEditTransaction reports whether the member may change or delete a
+// transaction created by createdBy.
+func (m *SpaceMember) CanEditTransaction(createdBy *uuid.UUID) bool {
+	if m.HasRole(SpaceRoleEditor) {
+		return true
+	}
+	return m.Role == SpaceRoleContributor && createdBy != nil && *createdBy == m.UserID
+}
+
 type SpaceInvite struct {
 	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	SpaceID   uuid.UUID  `gorm:"type:uuid;not null" json:"space_id"`
@@ -70,9 +129,13 @@ type SpaceInvite struct {
 	MaxUses   int        `gorm:"type:integer;not null;default:1" json:"max_uses"`
 	UseCount  int        `gorm:"type:integer;not null;default:0" json:"use_count"`
 	ExpiresAt *time.Time `gorm:"type:timestamptz" json:"expires_at"`
-	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
-	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	Role      string     `gorm:"type:varchar(20);not null;default:'editor'" json:"role"`
+	// RequiresApproval makes joining create a SpaceJoinRequest for the
+	// owner to approve instead of adding the member straight away.
+	RequiresApproval bool      `gorm:"not null;default:false" json:"requires_approval"`
+	CreatedBy        uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// Associations
 	Space   *Space `gorm:"foreignKey:SpaceID" json:"space,omitempty"`
@@ -82,3 +145,33 @@ type SpaceInvite struct {
 func (SpaceInvite) TableName() string {
 	return "space_invites"
 }
+
+// Join request states.
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// SpaceJoinRequest is a user waiting to join through an invite that
+// requires approval. The invite's use is only consumed on approval, and
+// the role granted is the invite's role at that time.
+type SpaceJoinRequest struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	SpaceID   uuid.UUID  `gorm:"type:uuid;not null" json:"space_id"`
+	InviteID  uuid.UUID  `gorm:"type:uuid;not null" json:"invite_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Status    string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	DecidedBy *uuid.UUID `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt *time.Time `gorm:"type:timestamptz" json:"decided_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Associations
+	User   *User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Invite *SpaceInvite `gorm:"foreignKey:InviteID" json:"invite,omitempty"`
+}
+
+func (SpaceJoinRequest) TableName() string {
+	return "space_join_requests"
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
 )
 
 type Transaction struct {
@@ -18,6 +19,10 @@ type Transaction struct {
 	Note        string          `gorm:"type:text" json:"note"`
 	AIStatus    *string         `gorm:"type:varchar(20);column:ai_status" json:"ai_status,omitempty"`
 	AIError     string          `gorm:"type:text;column:ai_error" json:"ai_error,omitempty"`
+	CreatedBy   *uuid.UUID      `gorm:"type:uuid" json:"created_by,omitempty"`
+	AISplit     datatypes.JSON  `gorm:"type:jsonb;column:ai_split_suggestion" json:"ai_split_suggestion,omitempty"`
+	AIPrompt    string          `gorm:"type:varchar(50);column:ai_prompt_version" json:"ai_prompt_version,omitempty"`
+	AICurrency  *string         `gorm:"type:varchar(3);column:ai_currency_suggestion" json:"ai_currency_suggestion,omitempty"` // receipt currency existing debts couldn't follow
 	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
 
@@ -26,12 +31,26 @@ type Transaction struct {
 	Expense *TransactionExpense `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE" json:"expense,omitempty"`
 	Debts   []TransactionDebt   `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE" json:"debts,omitempty"`
 	Images  []Image             `gorm:"polymorphic:Entity;polymorphicValue:transaction" json:"images,omitempty"`
+
+	// Duplicates warns that an attached photo matches another transaction
+	// in the space. Only set on the create response.
+	Duplicates []DuplicateImage `gorm:"-" json:"duplicates,omitempty"`
 }
 
 func (Transaction) TableName() string {
 	return "transactions"
 }
 
+// AISplitSuggestion is the JSON shape stored in Transaction.AISplit: a payer /
+// split parsed from quick text entry that couldn't be matched to split members
+// unambiguously. Names are as typed; Unmatched lists the ones that didn't map
+// to exactly one member. Cleared when the user saves the expense.
+type AISplitSuggestion struct {
+	PayerName    string   `json:"payer_name"`
+	Participants []string `json:"participants"`
+	Unmatched    []string `json:"unmatched"`
+}
+
 type TransactionExpense struct {
 	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	TransactionID string          `gorm:"type:varchar(21);not null;uniqueIndex" json:"transaction_id"`
@@ -41,6 +60,7 @@ type TransactionExpense struct {
 	HandlingFee   decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"handling_fee"`
 	PaymentMethod string          `gorm:"type:varchar(50)" json:"payment_method"`
 	LocationURL   string          `gorm:"type:varchar(500);not null;default:''" json:"location_url"`
+	InvoiceNumber string          `gorm:"type:varchar(20);not null;default:''" json:"invoice_number"`
 
 	// Associations
 	Items []TransactionExpenseItem `gorm:"foreignKey:ExpenseID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
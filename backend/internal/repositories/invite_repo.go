@@ -52,6 +52,19 @@ func (r *InviteRepo) FindByTokenForUpdate(ctx context.Context, token string) (*m
 	return &invite, nil
 }
 
+// FindByIDForUpdate locks an invite of spaceID; see FindByTokenForUpdate.
+func (r *InviteRepo) FindByIDForUpdate(ctx context.Context, id uuid.UUID, spaceID uuid.UUID) (*models.SpaceInvite, error) {
+	var invite models.SpaceInvite
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND space_id = ?", id, spaceID).
+		First(&invite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
 func (r *InviteRepo) FindActiveBySpace(ctx context.Context, spaceID uuid.UUID) ([]models.SpaceInvite, error) {
 	var invites []models.SpaceInvite
 	err := r.db.WithContext(ctx).
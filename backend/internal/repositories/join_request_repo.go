@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"lovelion/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type JoinRequestRepo struct {
+	db *gorm.DB
+}
+
+func NewJoinRequestRepo(db *gorm.DB) *JoinRequestRepo {
+	return &JoinRequestRepo{db: db}
+}
+
+func (r *JoinRequestRepo) WithTx(tx *gorm.DB) *JoinRequestRepo {
+	return &JoinRequestRepo{db: tx}
+}
+
+func (r *JoinRequestRepo) Create(ctx context.Context, req *models.SpaceJoinRequest) error {
+	return r.db.WithContext(ctx).Create(req).Error
+}
+
+// FindPending returns the user's open request for a space.
+func (r *JoinRequestRepo) FindPending(ctx context.Context, spaceID uuid.UUID, userID uuid.UUID) (*models.SpaceJoinRequest, error) {
+	var req models.SpaceJoinRequest
+	err := r.db.WithContext(ctx).
+		Where("space_id = ? AND user_id = ? AND status = ?", spaceID, userID, models.JoinRequestPending).
+		First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *JoinRequestRepo) FindPendingBySpace(ctx context.Context, spaceID uuid.UUID) ([]models.SpaceJoinRequest, error) {
+	var reqs []models.SpaceJoinRequest
+	err := r.db.WithContext(ctx).
+		Where("space_id = ? AND status = ?", spaceID, models.JoinRequestPending).
+		Preload("User").
+		Order("created_at").
+		Find(&reqs).Error
+	return reqs, err
+}
+
+// FindPendingForUpdate locks an open request so two owners' decisions on it
+// can't both go through.
+func (r *JoinRequestRepo) FindPendingForUpdate(ctx context.Context, id uuid.UUID, spaceID uuid.UUID) (*models.SpaceJoinRequest, error) {
+	var req models.SpaceJoinRequest
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND space_id = ? AND status = ?", id, spaceID, models.JoinRequestPending).
+		First(&req).Error
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *JoinRequestRepo) Decide(ctx context.Context, id uuid.UUID, status string, decidedBy uuid.UUID) error {
+	return r.db.WithContext(ctx).
+		Model(&models.SpaceJoinRequest{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"decided_by": decidedBy,
+			"decided_at": time.Now(),
+		}).Error
+}
@@ -53,9 +53,44 @@ func (r *MemberRepo) UpdateAlias(ctx context.Context, spaceID uuid.UUID, userID
 	return result.RowsAffected, result.Error
 }
 
+// UpdateRole changes a member's role. The owner's row is never matched;
+// ownership changes hands through a transfer, not a role edit. A pending
+// ownership offer to the member is withdrawn.
+func (r *MemberRepo) UpdateRole(ctx context.Context, spaceID uuid.UUID, userID uuid.UUID, role string) (int64, error) {
+	var rows int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.SpaceMember{}).
+			Where("space_id = ? AND user_id = ? AND role <> ?", spaceID, userID, models.SpaceRoleOwner).
+			Update("role", role)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return clearPendingOwner(tx, spaceID, userID)
+	})
+	return rows, err
+}
+
+// Delete removes a member, withdrawing any ownership offer made to them.
 func (r *MemberRepo) Delete(ctx context.Context, spaceID uuid.UUID, userID uuid.UUID) (int64, error) {
-	result := r.db.WithContext(ctx).
-		Where("space_id = ? AND user_id = ?", spaceID, userID).
-		Delete(&models.SpaceMember{})
-	return result.RowsAffected, result.Error
+	var rows int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("space_id = ? AND user_id = ?", spaceID, userID).
+			Delete(&models.SpaceMember{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		rows = result.RowsAffected
+		return clearPendingOwner(tx, spaceID, userID)
+	})
+	return rows, err
+}
+
+// clearPendingOwner withdraws an ownership offer made to userID. An offer
+// is for the member as they were when it was made, so one who leaves, is
+// removed or changes role has to be nominated again.
+func clearPendingOwner(tx *gorm.DB, spaceID uuid.UUID, userID uuid.UUID) error {
+	return tx.Model(&models.Space{}).
+		Where("id = ? AND pending_owner_id = ?", spaceID, userID).
+		Update("pending_owner_id", nil).Error
 }
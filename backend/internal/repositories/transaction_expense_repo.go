@@ -27,6 +27,7 @@ type ExpenseUpdateParams struct {
 	BillingAmount *decimal.Decimal
 	HandlingFee   *decimal.Decimal
 	PaymentMethod *string
+	LocationURL   *string
 }
 
 func (r *TransactionExpenseRepo) Create(ctx context.Context, expense *models.TransactionExpense) error {
@@ -51,6 +52,9 @@ func (r *TransactionExpenseRepo) Update(ctx context.Context, transactionID strin
 	if params.PaymentMethod != nil {
 		updates["payment_method"] = *params.PaymentMethod
 	}
+	if params.LocationURL != nil {
+		updates["location_url"] = *params.LocationURL
+	}
 
 	if len(updates) == 0 {
 		return nil
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Extraction attempt sources and the status used for a re-queued attempt
+// (completed / failed reuse the ai_status values).
+const (
+	aiSourceImage      = "image"
+	aiSourceText       = "text"
+	attemptStatusRetry = "retry"
+)
+
+// aiRequestMeta is what an attempt keeps about the request. Image bytes are
+// not copied; ImageKey points at the stored original.
+type aiRequestMeta struct {
+	ImageKey   string       `json:"image_key,omitempty"`
+	MimeType   string       `json:"mime_type,omitempty"`
+	ImageBytes int          `json:"image_bytes,omitempty"`
+	Text       string       `json:"text,omitempty"`
+	Hints      ExtractHints `json:"hints"`
+}
+
+// newAttempt builds the attempt row for one extractor call. The raw output
+// comes from the result on success and from an ExtractError on failure.
+func newAttempt(txnID, source string, meta aiRequestMeta, result *ReceiptData, callErr error, elapsed time.Duration) *models.AIExtractionAttempt {
+	attempt := &models.AIExtractionAttempt{
+		ID:            uuid.New(),
+		TransactionID: txnID,
+		Source:        source,
+		Status:        aiStatusCompleted,
+		DurationMs:    elapsed.Milliseconds(),
+	}
+	if raw, err := json.Marshal(meta); err == nil {
+		attempt.Request = datatypes.JSON(raw)
+	}
+	if result != nil {
+		attempt.PromptVersion = result.PromptVersion
+		attempt.RawResponse = result.Raw
+	}
+	if callErr != nil {
+		attempt.Status = aiStatusFailed
+		attempt.Error = callErr.Error()
+		var extractErr *ExtractError
+		if errors.As(callErr, &extractErr) {
+			attempt.RawResponse = extractErr.Raw
+		}
+	}
+	return attempt
+}
+
+// saveAttempt stores an attempt. Failing to keep the debugging record must
+// not fail the extraction, so errors are only logged.
+func (w *AIWorker) saveAttempt(ctx context.Context, attempt *models.AIExtractionAttempt) {
+	if err := w.db.WithContext(ctx).Create(attempt).Error; err != nil {
+		slog.Error("ai worker save attempt failed", "txn_id", attempt.TransactionID, "error", err)
+	}
+}
+
+// Reparse runs an attempt's stored model output through parsing, mapping and
+// write-back again without calling the LLM, e.g. after a fix to the parser or
+// to the space's categories. The new attempt is recorded with ReparsedFrom
+// set. Rows the worker currently owns are refused.
+func (w *AIWorker) Reparse(ctx context.Context, attemptID uuid.UUID) (*models.AIExtractionAttempt, error) {
+	var src models.AIExtractionAttempt
+	if err := w.db.WithContext(ctx).First(&src, "id = ?", attemptID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.Wrap(errorx.ErrNotFound, "Extraction attempt not found")
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load extraction attempt")
+	}
+	if strings.TrimSpace(src.RawResponse) == "" {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Attempt has no stored model output")
+	}
+	txnID := src.TransactionID
+	textPath := src.Source == aiSourceText
+
+	start := time.Now()
+	result, parseErr := ParseReceiptResponse(src.RawResponse, textPath)
+	attempt := &models.AIExtractionAttempt{
+		ID:            uuid.New(),
+		TransactionID: txnID,
+		Source:        src.Source,
+		Status:        aiStatusCompleted,
+		PromptVersion: src.PromptVersion,
+		Request:       src.Request,
+		RawResponse:   src.RawResponse,
+		ReparsedFrom:  &src.ID,
+	}
+	if parseErr != nil {
+		attempt.Status = aiStatusFailed
+		attempt.Error = parseErr.Error()
+		attempt.DurationMs = time.Since(start).Milliseconds()
+		w.saveAttempt(ctx, attempt)
+		return attempt, errorx.Wrap(errorx.ErrBadRequest, "Stored output could not be parsed: "+parseErr.Error())
+	}
+	result.PromptVersion = src.PromptVersion
+
+	// Take the row the same way the worker does so a concurrent live
+	// extraction and a re-parse can't both write items.
+	claim := w.db.WithContext(ctx).
+		Model(&models.Transaction{}).
+		Where("id = ? AND (ai_status IS NULL OR ai_status IN ?)", txnID, []string{aiStatusCompleted, aiStatusFailed}).
+		Update("ai_status", aiStatusProcessing)
+	if claim.Error != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to claim transaction")
+	}
+	if claim.RowsAffected == 0 {
+		return nil, errorx.Wrap(errorx.ErrConflict, "Transaction is missing or being processed")
+	}
+	w.publishStatus(ctx, txnID, aiStatusProcessing, "")
+
+	hints, err := w.loadSpaceHints(ctx, txnID)
+	if err != nil {
+		slog.Warn("ai reparse load space hints failed", "txn_id", txnID, "error", err)
+	}
+	if err := w.finishExtraction(ctx, txnID, result, hints, textPath); err != nil {
+		w.writeFailure(ctx, txnID, "failed to save result")
+		attempt.Status = aiStatusFailed
+		attempt.Error = err.Error()
+		attempt.DurationMs = time.Since(start).Milliseconds()
+		w.saveAttempt(ctx, attempt)
+		return attempt, errorx.Wrap(errorx.ErrInternal, "Failed to save re-parsed result")
+	}
+
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	w.saveAttempt(ctx, attempt)
+	w.publishStatus(ctx, txnID, aiStatusCompleted, "")
+	return attempt, nil
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -18,10 +19,34 @@ import (
 // ReceiptData is the parsed output of a receipt extraction call.
 type ReceiptData struct {
 	Date          *time.Time
-	Title         string // store name (image) or cleaned description (text)
-	Category      string // best-match from ExtractHints.Categories
-	PaymentMethod string // best-match from ExtractHints.PaymentMethods
+	Title         string          // store name (image) or cleaned description (text)
+	Category      string          // best-match from ExtractHints.Categories
+	PaymentMethod string          // best-match from ExtractHints.PaymentMethods
+	Currency      string          // ISO 4217 code printed on the receipt; empty if unknown
+	Tax           decimal.Decimal // tax listed separately from item prices
+	Tip           decimal.Decimal // service charge or tip
+	InvoiceNumber string          // Taiwan uniform-invoice number (e.g. AB12345678)
 	Items         []ReceiptItem
+	Split         *SplitInfo // text path only; nil when the input says nothing about splitting
+	PromptVersion string     // PromptID of the prompt used; empty for non-LLM extractors
+	Raw           string     // model output the fields were parsed from; empty for non-LLM extractors
+}
+
+// ExtractError is returned by LLM extractors once the provider has answered,
+// so the worker can keep what came back alongside the failure.
+type ExtractError struct {
+	Err error
+	Raw string // model output text if the call got that far, otherwise the HTTP response body
+}
+
+func (e *ExtractError) Error() string { return e.Err.Error() }
+func (e *ExtractError) Unwrap() error { return e.Err }
+
+// SplitInfo is who paid and who shares the cost, as named in the input text.
+// Names are unresolved: "我" refers to the user who created the transaction.
+type SplitInfo struct {
+	Payer        string
+	Participants []string
 }
 
 // ReceiptItem represents a single line item on a receipt.
@@ -32,20 +57,34 @@ type ReceiptItem struct {
 }
 
 // ExtractHints carries space-specific option lists so the LLM can pick from
-// existing values rather than inventing its own.
+// existing values rather than inventing its own, plus the space's prompt
+// settings.
 type ExtractHints struct {
-	Categories     []string
-	PaymentMethods []string
+	Categories     []string `json:"categories,omitempty"`
+	PaymentMethods []string `json:"payment_methods,omitempty"`
+	Currencies     []string `json:"currencies,omitempty"`
+	SplitMembers   []string `json:"split_members,omitempty"`
+	Locale         string   `json:"locale,omitempty"`         // prompt locale; DefaultPromptLocale when empty or unknown
+	Instructions   string   `json:"instructions,omitempty"`   // space's custom rules appended to the system prompt
+	PromptVersion  string   `json:"prompt_version,omitempty"` // pins a prompt version; CurrentPromptVersion when empty
 }
 
 // promptFragment builds the user-message addendum that lists available options.
-func (h ExtractHints) promptFragment() string {
+func (h ExtractHints) promptFragment(p promptTemplate) string {
 	var parts []string
-	if len(h.Categories) > 0 {
-		parts = append(parts, fmt.Sprintf("可用的分類清單：%s", strings.Join(h.Categories, "、")))
+	lists := []struct {
+		label  string
+		values []string
+	}{
+		{p.Categories, h.Categories},
+		{p.Payments, h.PaymentMethods},
+		{p.Currencies, h.Currencies},
+		{p.SplitMembers, h.SplitMembers},
 	}
-	if len(h.PaymentMethods) > 0 {
-		parts = append(parts, fmt.Sprintf("可用的付款方式清單：%s", strings.Join(h.PaymentMethods, "、")))
+	for _, l := range lists {
+		if len(l.values) > 0 {
+			parts = append(parts, l.label+strings.Join(l.values, p.ListSep))
+		}
 	}
 	return strings.Join(parts, "\n")
 }
@@ -69,33 +108,6 @@ const (
 	geminiCallTimeout    = 30 * time.Second
 )
 
-//nolint:lll // prompt is intentionally a single literal block
-const geminiSystemPrompt = `你是發票辨識助手。請從圖片擷取消費資訊，並以指定的 JSON Schema 回傳。
-
-規則：
-- title 為店家名稱。若發票上無法判讀店家名稱，填空字串。
-- date 取發票上的消費日期與時間，格式 YYYY-MM-DD HH:mm。若只有日期沒有時間則填 YYYY-MM-DD。若無法判讀填 null。
-- items 依發票上的順序列出。
-- unit_price 是單價（不是小計）。quantity 是數量。
-- 若有整單折扣，加一筆 name="折扣" 的品項，unit_price 為負數，quantity=1。
-- category：根據消費內容判斷最適合的分類。若使用者有提供可用分類清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判斷填空字串。
-- payment_method：若發票上有標示付款方式（如信用卡、現金、Line Pay 等），請填寫。若使用者有提供可用付款方式清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判讀填空字串。
-- 不要輸出 total，呼叫端會自行計算。`
-
-//nolint:lll // prompt is intentionally a single literal block
-const geminiTextSystemPrompt = `你是記帳輸入解析助手。使用者會給你一小段中文／英文混合的簡短文字（例如「停車費 100」、「昨天 小七 買咖啡 55」、「午餐 250 刷卡」），請從中擷取消費資訊，並以指定的 JSON Schema 回傳。
-
-規則：
-- title 為店家名稱，若文字中有提及店家（如「小七」、「全聯」），填入店家名稱；若無填空字串。
-- 將整筆消費視為「一個」品項。items 陣列只會有 1 筆。
-- item.name 為消費名稱（例如「停車費」、「午餐」、「咖啡」），去除金額、日期與店家字樣。若僅有金額沒有名稱，填「未命名」。
-- item.unit_price 為金額數字；quantity 固定為 1。
-- 若文字中指出相對日期（例如「昨天」、「前天」），請以今天的日期推算並輸出 YYYY-MM-DD HH:mm（無時間則 YYYY-MM-DD）；若無法判讀日期則填 null。
-- category：根據消費內容判斷最適合的分類。若使用者有提供可用分類清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判斷填空字串。
-- payment_method：若文字中有提及付款方式（如「刷卡」、「現金」、「Line Pay」），請填寫。若使用者有提供可用付款方式清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判讀填空字串。
-- 若完全無法解析出金額，仍請回傳 items=[] — 呼叫端會視為失敗。
-- 不要輸出 total。`
-
 // Response schema used to force structured JSON output from Gemini.
 var geminiResponseSchema = json.RawMessage(`{
   "type": "object",
@@ -104,6 +116,12 @@ var geminiResponseSchema = json.RawMessage(`{
     "date":           { "type": "string", "nullable": true },
     "category":       { "type": "string" },
     "payment_method": { "type": "string" },
+    "currency":       { "type": "string" },
+    "tax":            { "type": "number" },
+    "tip":            { "type": "number" },
+    "invoice_number": { "type": "string" },
+    "payer":          { "type": "string" },
+    "split_among":    { "type": "array", "items": { "type": "string" } },
     "items": {
       "type": "array",
       "items": {
@@ -193,10 +211,16 @@ type geminiResponse struct {
 }
 
 type receiptJSONPayload struct {
-	Title         string  `json:"title"`
-	Date          *string `json:"date"`
-	Category      string  `json:"category"`
-	PaymentMethod string  `json:"payment_method"`
+	Title         string          `json:"title"`
+	Date          *string         `json:"date"`
+	Category      string          `json:"category"`
+	PaymentMethod string          `json:"payment_method"`
+	Currency      string          `json:"currency"`
+	Tax           decimal.Decimal `json:"tax"`
+	Tip           decimal.Decimal `json:"tip"`
+	InvoiceNumber string          `json:"invoice_number"`
+	Payer         string          `json:"payer"`
+	SplitAmong    []string        `json:"split_among"`
 	Items         []struct {
 		Name      string          `json:"name"`
 		UnitPrice decimal.Decimal `json:"unit_price"`
@@ -210,14 +234,15 @@ func (g *GeminiReceiptExtractor) Extract(ctx context.Context, image []byte, mime
 		return nil, errors.New("empty image")
 	}
 
-	userText := "請辨識這張發票。"
-	if extra := hints.promptFragment(); extra != "" {
+	prompt, promptID := resolvePrompt(hints)
+	userText := prompt.ImageRequest
+	if extra := hints.promptFragment(prompt); extra != "" {
 		userText += "\n" + extra
 	}
 
 	reqBody := geminiRequest{
 		SystemInstruction: &geminiContent{
-			Parts: []geminiPart{{Text: geminiSystemPrompt}},
+			Parts: []geminiPart{{Text: prompt.system(prompt.ImageSystem, hints.Instructions)}},
 		},
 		Contents: []geminiContent{{
 			Role: "user",
@@ -236,7 +261,12 @@ func (g *GeminiReceiptExtractor) Extract(ctx context.Context, image []byte, mime
 		},
 	}
 
-	return g.callAndParse(ctx, reqBody)
+	result, err := g.callAndParse(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	result.PromptVersion = promptID
+	return result, nil
 }
 
 // ExtractText sends a short text line to Gemini and parses the same structured
@@ -247,15 +277,16 @@ func (g *GeminiReceiptExtractor) ExtractText(ctx context.Context, text string, h
 		return nil, errors.New("empty text")
 	}
 
+	prompt, promptID := resolvePrompt(hints)
 	today := time.Now().Format("2006-01-02")
-	userMsg := fmt.Sprintf("今天是 %s。請解析以下這筆記帳輸入：\n%s", today, text)
-	if extra := hints.promptFragment(); extra != "" {
+	userMsg := prompt.textRequest(today, text)
+	if extra := hints.promptFragment(prompt); extra != "" {
 		userMsg += "\n" + extra
 	}
 
 	reqBody := geminiRequest{
 		SystemInstruction: &geminiContent{
-			Parts: []geminiPart{{Text: geminiTextSystemPrompt}},
+			Parts: []geminiPart{{Text: prompt.system(prompt.TextSystem, hints.Instructions)}},
 		},
 		Contents: []geminiContent{{
 			Role: "user",
@@ -275,8 +306,9 @@ func (g *GeminiReceiptExtractor) ExtractText(ctx context.Context, text string, h
 		return nil, err
 	}
 	if len(result.Items) == 0 {
-		return nil, errors.New("parse text: no items extracted")
+		return nil, &ExtractError{Err: errNoTextItems, Raw: result.Raw}
 	}
+	result.PromptVersion = promptID
 	return result, nil
 }
 
@@ -314,25 +346,53 @@ func (g *GeminiReceiptExtractor) callAndParse(ctx context.Context, reqBody gemin
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("gemini http %d: %s", resp.StatusCode, truncate(string(body), 300))
+		return nil, &ExtractError{Err: fmt.Errorf("gemini http %d: %s", resp.StatusCode, truncate(string(body), 300)), Raw: string(body)}
 	}
 
 	var parsed geminiResponse
 	if err := json.Unmarshal(body, &parsed); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+		return nil, &ExtractError{Err: fmt.Errorf("decode response: %w", err), Raw: string(body)}
 	}
 	if parsed.Error != nil {
-		return nil, fmt.Errorf("gemini api error: %s", parsed.Error.Message)
+		return nil, &ExtractError{Err: fmt.Errorf("gemini api error: %s", parsed.Error.Message), Raw: string(body)}
 	}
 	if len(parsed.Candidates) == 0 || len(parsed.Candidates[0].Content.Parts) == 0 {
-		return nil, errors.New("gemini returned no candidates")
+		return nil, &ExtractError{Err: errors.New("gemini returned no candidates"), Raw: string(body)}
 	}
 
 	text := parsed.Candidates[0].Content.Parts[0].Text
 	if text == "" {
-		return nil, errors.New("gemini returned empty text")
+		return nil, &ExtractError{Err: errors.New("gemini returned empty text"), Raw: string(body)}
+	}
+
+	out, err := parseReceiptJSON(text)
+	if err != nil {
+		return nil, &ExtractError{Err: err, Raw: text}
+	}
+	return out, nil
+}
+
+// errNoTextItems is returned when a text entry yields no amount.
+var errNoTextItems = errors.New("parse text: no items extracted")
+
+// ParseReceiptResponse parses model output saved from an earlier call the
+// same way the live call would, with no network call. text selects the text
+// path, which also fails when no item was extracted.
+func ParseReceiptResponse(raw string, text bool) (*ReceiptData, error) {
+	result, err := parseReceiptJSON(raw)
+	if err == nil && text && len(result.Items) == 0 {
+		err = errNoTextItems
 	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
+// parseReceiptJSON turns the model's structured output into ReceiptData. It
+// makes no network call, so stored output can be parsed again after a fix to
+// the mapping logic.
+func parseReceiptJSON(text string) (*ReceiptData, error) {
 	var receipt receiptJSONPayload
 	if err := json.Unmarshal([]byte(text), &receipt); err != nil {
 		return nil, fmt.Errorf("parse receipt json: %w (raw=%s)", err, truncate(text, 200))
@@ -342,7 +402,20 @@ func (g *GeminiReceiptExtractor) callAndParse(ctx context.Context, reqBody gemin
 		Title:         strings.TrimSpace(receipt.Title),
 		Category:      strings.TrimSpace(receipt.Category),
 		PaymentMethod: strings.TrimSpace(receipt.PaymentMethod),
+		Currency:      strings.ToUpper(strings.TrimSpace(receipt.Currency)),
+		InvoiceNumber: normalizeInvoiceNumber(receipt.InvoiceNumber),
 		Items:         make([]ReceiptItem, 0, len(receipt.Items)),
+		Raw:           text,
+	}
+	if receipt.Tax.IsPositive() {
+		out.Tax = receipt.Tax
+	}
+	if receipt.Tip.IsPositive() {
+		out.Tip = receipt.Tip
+	}
+
+	if split := parseSplitInfo(receipt.Payer, receipt.SplitAmong); split != nil {
+		out.Split = split
 	}
 
 	if receipt.Date != nil && *receipt.Date != "" {
@@ -373,6 +446,44 @@ func (g *GeminiReceiptExtractor) callAndParse(ctx context.Context, reqBody gemin
 	return out, nil
 }
 
+// invoiceNumberPattern matches a Taiwan uniform-invoice number once separators
+// have been stripped: two upper-case letters followed by eight digits.
+var invoiceNumberPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{8}$`)
+
+// normalizeInvoiceNumber strips separators from an LLM-provided invoice number
+// and returns it only if it looks like a real uniform-invoice number.
+func normalizeInvoiceNumber(raw string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(raw)))
+	if !invoiceNumberPattern.MatchString(cleaned) {
+		return ""
+	}
+	return cleaned
+}
+
+// parseSplitInfo trims and de-duplicates the payer / participant names the
+// LLM returned. Returns nil when the input mentioned neither.
+func parseSplitInfo(payer string, among []string) *SplitInfo {
+	info := &SplitInfo{Payer: strings.TrimSpace(payer)}
+	seen := map[string]bool{}
+	for _, name := range among {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		info.Participants = append(info.Participants, name)
+	}
+	if info.Payer == "" && len(info.Participants) == 0 {
+		return nil
+	}
+	return info
+}
+
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -151,6 +151,27 @@ func TestGeminiExtract_MalformedJSON(t *testing.T) {
 	_, err := ext.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "parse receipt json")
+
+	var extractErr *ExtractError
+	require.ErrorAs(t, err, &extractErr)
+	assert.Equal(t, "not-json", extractErr.Raw)
+}
+
+func TestGeminiExtract_KeepsRawOutput(t *testing.T) {
+	inner := `{"title":"全聯","items":[{"name":"牛奶","unit_price":55,"quantity":2}]}`
+	fake := newFakeGemini(t, 200, wrapCandidate(t, inner))
+	ext := NewGeminiReceiptExtractor("k", "", fake.server.URL)
+	data, err := ext.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{})
+	require.NoError(t, err)
+	assert.Equal(t, inner, data.Raw)
+
+	fake = newFakeGemini(t, 503, `{"error":{"message":"overloaded"}}`)
+	ext = NewGeminiReceiptExtractor("k", "", fake.server.URL)
+	_, err = ext.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{})
+	var extractErr *ExtractError
+	require.ErrorAs(t, err, &extractErr)
+	assert.Equal(t, `{"error":{"message":"overloaded"}}`, extractErr.Raw)
+	assert.True(t, isRetryableError(err))
 }
 
 func TestGeminiExtract_MissingAPIKey(t *testing.T) {
@@ -184,3 +205,97 @@ func TestGeminiExtract_ContextCancelled(t *testing.T) {
 	// Error should mention deadline or context
 	assert.True(t, strings.Contains(err.Error(), "context") || strings.Contains(err.Error(), "deadline"))
 }
+
+func TestGeminiExtract_CurrencyTaxTipInvoice(t *testing.T) {
+	canned := wrapCandidate(t, `{
+        "currency": "usd",
+        "tax": 1.25,
+        "tip": 3,
+        "invoice_number": "ab-12345678",
+        "items": [{"name":"Burger","unit_price":12.5,"quantity":1}]
+    }`)
+	fake := newFakeGemini(t, 200, canned)
+	ext := NewGeminiReceiptExtractor("k", "", fake.server.URL)
+	data, err := ext.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{Currencies: []string{"TWD", "USD"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "USD", data.Currency)
+	assert.True(t, decimal.RequireFromString("1.25").Equal(data.Tax))
+	assert.True(t, decimal.NewFromInt(3).Equal(data.Tip))
+	assert.Equal(t, "AB12345678", data.InvoiceNumber)
+
+	// Currency hints are forwarded to the model.
+	assert.Contains(t, fake.lastRequest.Contents[0].Parts[0].Text, "可用的幣別清單：TWD、USD")
+}
+
+func TestNormalizeInvoiceNumber(t *testing.T) {
+	assert.Equal(t, "AB12345678", normalizeInvoiceNumber("AB-12345678"))
+	assert.Equal(t, "AB12345678", normalizeInvoiceNumber(" ab 1234 5678 "))
+	assert.Equal(t, "", normalizeInvoiceNumber("12345678"))
+	assert.Equal(t, "", normalizeInvoiceNumber("ABC-1234"))
+	assert.Equal(t, "", normalizeInvoiceNumber(""))
+}
+
+func TestGeminiExtractText_PayerAndSplit(t *testing.T) {
+	canned := wrapCandidate(t, `{
+        "payer": "我",
+        "split_among": ["小明", " 小華 ", "小明", ""],
+        "items": [{"name":"晚餐","unit_price":1200,"quantity":1}]
+    }`)
+	fake := newFakeGemini(t, 200, canned)
+	ext := NewGeminiReceiptExtractor("k", "", fake.server.URL)
+	data, err := ext.ExtractText(context.Background(), "晚餐 1200 我付 小明小華平分", ExtractHints{SplitMembers: []string{"我自己", "小明", "小華"}})
+	require.NoError(t, err)
+
+	require.NotNil(t, data.Split)
+	assert.Equal(t, "我", data.Split.Payer)
+	assert.Equal(t, []string{"小明", "小華"}, data.Split.Participants)
+	assert.Contains(t, fake.lastRequest.Contents[0].Parts[0].Text, "分帳成員清單：我自己、小明、小華")
+}
+
+func TestGeminiExtractText_NoSplitMentioned(t *testing.T) {
+	canned := wrapCandidate(t, `{"payer": "", "split_among": [], "items": [{"name":"午餐","unit_price":250,"quantity":1}]}`)
+	fake := newFakeGemini(t, 200, canned)
+	ext := NewGeminiReceiptExtractor("k", "", fake.server.URL)
+	data, err := ext.ExtractText(context.Background(), "午餐 250", ExtractHints{})
+	require.NoError(t, err)
+	assert.Nil(t, data.Split)
+}
+
+func TestGeminiExtractText_LocaleAndSpaceInstructions(t *testing.T) {
+	canned := wrapCandidate(t, `{"items": [{"name":"Parking","unit_price":100,"quantity":1}]}`)
+	fake := newFakeGemini(t, 200, canned)
+	ext := NewGeminiReceiptExtractor("k", "", fake.server.URL)
+	hints := ExtractHints{
+		Categories:   []string{"Transport", "Food"},
+		Locale:       PromptLocaleEn,
+		Instructions: "Always treat 7-11 as Convenience store.",
+	}
+	data, err := ext.ExtractText(context.Background(), "parking 100", hints)
+	require.NoError(t, err)
+
+	system := fake.lastRequest.SystemInstruction.Parts[0].Text
+	assert.True(t, strings.HasPrefix(system, "You are an expense entry parser."))
+	assert.True(t, strings.HasSuffix(system, "\nAlways treat 7-11 as Convenience store."))
+	user := fake.lastRequest.Contents[0].Parts[0].Text
+	assert.Contains(t, user, "Parse this expense entry:\nparking 100")
+	assert.Contains(t, user, "Available categories: Transport, Food")
+
+	assert.Regexp(t, `^v1/en\+[0-9a-f]{8}$`, data.PromptVersion)
+}
+
+func TestResolvePrompt_FallsBack(t *testing.T) {
+	tpl, id := resolvePrompt(ExtractHints{Locale: "fr", PromptVersion: "v0"})
+	assert.Equal(t, CurrentPromptVersion+"/"+DefaultPromptLocale, id)
+	assert.Equal(t, promptTemplates[CurrentPromptVersion][DefaultPromptLocale].ImageSystem, tpl.ImageSystem)
+
+	for _, locale := range SupportedPromptLocales() {
+		for version, locales := range promptTemplates {
+			tpl, ok := locales[locale]
+			require.True(t, ok, "%s is missing locale %s", version, locale)
+			assert.NotEmpty(t, tpl.ImageSystem)
+			assert.NotEmpty(t, tpl.TextSystem)
+			assert.Contains(t, tpl.TextRequest, "%s")
+		}
+	}
+}
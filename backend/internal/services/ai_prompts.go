@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"lovelion/internal/utils/errorx"
+)
+
+// Extraction prompts are versioned templates with one variant per locale.
+// A released version is never edited: change the wording by adding a new
+// version and pointing CurrentPromptVersion at it, so transactions keep
+// pointing at the exact text that produced them (see PromptID) and ai-eval
+// can compare versions on the same dataset.
+
+// Supported prompt locales. DefaultPromptLocale is used for spaces that
+// haven't picked one and for unknown values.
+const (
+	PromptLocaleZhTW    = "zh-TW"
+	PromptLocaleEn      = "en"
+	PromptLocaleJa      = "ja"
+	DefaultPromptLocale = PromptLocaleZhTW
+)
+
+// CurrentPromptVersion is the version used when ExtractHints doesn't pin one.
+const CurrentPromptVersion = "v1"
+
+// MaxPromptInstructionsLength caps a space's custom instructions (in runes)
+// so one space can't crowd the rules out of the context window.
+const MaxPromptInstructionsLength = 1000
+
+// promptTemplate is every piece of text the Gemini extractor sends for one
+// version and locale.
+type promptTemplate struct {
+	ImageSystem  string // system instruction for receipt photos
+	TextSystem   string // system instruction for quick text entry
+	ImageRequest string // user message accompanying the photo
+	TextRequest  string // user message; formatted with today's date and the input
+	Categories   string // option-list labels used by promptFragment
+	Payments     string
+	Currencies   string
+	SplitMembers string
+	ListSep      string // joins option values
+	Instructions string // heading placed above a space's custom instructions
+}
+
+// promptTemplates maps version → locale → template.
+var promptTemplates = map[string]map[string]promptTemplate{
+	"v1": {
+		PromptLocaleZhTW: {
+			ImageSystem:  promptV1ImageZhTW,
+			TextSystem:   promptV1TextZhTW,
+			ImageRequest: "請辨識這張發票。",
+			TextRequest:  "今天是 %s。請解析以下這筆記帳輸入：\n%s",
+			Categories:   "可用的分類清單：",
+			Payments:     "可用的付款方式清單：",
+			Currencies:   "可用的幣別清單：",
+			SplitMembers: "分帳成員清單：",
+			ListSep:      "、",
+			Instructions: "此空間的額外規則（優先於上述規則）：",
+		},
+		PromptLocaleEn: {
+			ImageSystem:  promptV1ImageEn,
+			TextSystem:   promptV1TextEn,
+			ImageRequest: "Please read this receipt.",
+			TextRequest:  "Today is %s. Parse this expense entry:\n%s",
+			Categories:   "Available categories: ",
+			Payments:     "Available payment methods: ",
+			Currencies:   "Available currencies: ",
+			SplitMembers: "Split members: ",
+			ListSep:      ", ",
+			Instructions: "Additional rules for this space (these override the rules above):",
+		},
+		PromptLocaleJa: {
+			ImageSystem:  promptV1ImageJa,
+			TextSystem:   promptV1TextJa,
+			ImageRequest: "このレシートを読み取ってください。",
+			TextRequest:  "今日は %s です。次の記帳入力を解析してください：\n%s",
+			Categories:   "利用可能なカテゴリ：",
+			Payments:     "利用可能な支払方法：",
+			Currencies:   "利用可能な通貨：",
+			SplitMembers: "割り勘メンバー：",
+			ListSep:      "、",
+			Instructions: "このスペースの追加ルール（上記のルールより優先）：",
+		},
+	},
+}
+
+// SupportedPromptLocales lists the locales every prompt version provides.
+func SupportedPromptLocales() []string {
+	return []string{PromptLocaleZhTW, PromptLocaleEn, PromptLocaleJa}
+}
+
+// IsSupportedPromptLocale reports whether locale has a prompt variant.
+func IsSupportedPromptLocale(locale string) bool {
+	_, ok := promptTemplates[CurrentPromptVersion][locale]
+	return ok
+}
+
+// ValidatePromptSettings checks a space's prompt locale and custom
+// instructions. An empty locale leaves the current one in place.
+func ValidatePromptSettings(locale, instructions string) error {
+	if locale != "" && !IsSupportedPromptLocale(locale) {
+		return errorx.Wrap(errorx.ErrBadRequest, fmt.Sprintf("AI locale must be one of %s", strings.Join(SupportedPromptLocales(), ", ")))
+	}
+	if utf8.RuneCountInString(instructions) > MaxPromptInstructionsLength {
+		return errorx.Wrap(errorx.ErrBadRequest, fmt.Sprintf("AI instructions must be at most %d characters", MaxPromptInstructionsLength))
+	}
+	return nil
+}
+
+// resolvePrompt picks the template for the hints' version and locale, falling
+// back to the current version and the default locale, and returns it with the
+// PromptID to record on the transaction.
+func resolvePrompt(h ExtractHints) (promptTemplate, string) {
+	version := h.PromptVersion
+	locales, ok := promptTemplates[version]
+	if !ok {
+		version = CurrentPromptVersion
+		locales = promptTemplates[version]
+	}
+	locale := h.Locale
+	tpl, ok := locales[locale]
+	if !ok {
+		locale = DefaultPromptLocale
+		tpl = locales[locale]
+	}
+	return tpl, PromptID(version, locale, h.Instructions)
+}
+
+// PromptID identifies the exact prompt text sent for an extraction:
+// "<version>/<locale>", plus "+<hash>" of the space's custom instructions
+// when it has any, so later edits to those instructions stay traceable.
+func PromptID(version, locale, instructions string) string {
+	id := version + "/" + locale
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		sum := sha256.Sum256([]byte(instructions))
+		id += "+" + hex.EncodeToString(sum[:4])
+	}
+	return id
+}
+
+// system returns the system instruction with the space's custom instructions
+// appended.
+func (p promptTemplate) system(base, instructions string) string {
+	instructions = strings.TrimSpace(instructions)
+	if instructions == "" {
+		return base
+	}
+	return base + "\n\n" + p.Instructions + "\n" + instructions
+}
+
+// textRequest formats the quick-entry user message.
+func (p promptTemplate) textRequest(today, text string) string {
+	return fmt.Sprintf(p.TextRequest, today, text)
+}
+
+//nolint:lll // prompt is intentionally a single literal block
+const promptV1ImageZhTW = `你是發票辨識助手。請從圖片擷取消費資訊，並以指定的 JSON Schema 回傳。
+
+規則：
+- title 為店家名稱。若發票上無法判讀店家名稱，填空字串。
+- date 取發票上的消費日期與時間，格式 YYYY-MM-DD HH:mm。若只有日期沒有時間則填 YYYY-MM-DD。若無法判讀填 null。
+- items 依發票上的順序列出。
+- unit_price 是單價（不是小計）。quantity 是數量。
+- 若有整單折扣，加一筆 name="折扣" 的品項，unit_price 為負數，quantity=1。
+- currency：發票上的幣別，使用 ISO 4217 三碼大寫（如 TWD、JPY、USD）。若使用者有提供可用幣別清單，優先從清單中選擇。若無法判讀填空字串。
+- tax：只有在稅金「另外列出、未含在品項單價內」時才填寫（例如美國的 sales tax）；台灣、日本等含稅價格填 0。
+- tip：服務費或小費金額（例如 10% 服務費）。若沒有填 0。稅金與服務費不要重複列在 items 中。
+- invoice_number：台灣統一發票號碼（兩碼英文字母 + 8 碼數字，如 AB-12345678）。若不是統一發票或無法判讀填空字串。
+- category：根據消費內容判斷最適合的分類。若使用者有提供可用分類清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判斷填空字串。
+- payment_method：若發票上有標示付款方式（如信用卡、現金、Line Pay 等），請填寫。若使用者有提供可用付款方式清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判讀填空字串。
+- 不要輸出 total，呼叫端會自行計算。`
+
+//nolint:lll // prompt is intentionally a single literal block
+const promptV1TextZhTW = `你是記帳輸入解析助手。使用者會給你一小段中文／英文混合的簡短文字（例如「停車費 100」、「昨天 小七 買咖啡 55」、「午餐 250 刷卡」），請從中擷取消費資訊，並以指定的 JSON Schema 回傳。
+
+規則：
+- title 為店家名稱，若文字中有提及店家（如「小七」、「全聯」），填入店家名稱；若無填空字串。
+- 將整筆消費視為「一個」品項。items 陣列只會有 1 筆。
+- item.name 為消費名稱（例如「停車費」、「午餐」、「咖啡」），去除金額、日期與店家字樣。若僅有金額沒有名稱，填「未命名」。
+- item.unit_price 為金額數字；quantity 固定為 1。
+- 若文字中指出相對日期（例如「昨天」、「前天」），請以今天的日期推算並輸出 YYYY-MM-DD HH:mm（無時間則 YYYY-MM-DD）；若無法判讀日期則填 null。
+- category：根據消費內容判斷最適合的分類。若使用者有提供可用分類清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判斷填空字串。
+- payment_method：若文字中有提及付款方式（如「刷卡」、「現金」、「Line Pay」），請填寫。若使用者有提供可用付款方式清單，優先從清單中選擇；若都不符合，可自行填寫。若無法判讀填空字串。
+- payer：若文字中有提及誰付錢（如「我付」、「小明付的」），填入付款人名稱；使用者本人一律填「我」。若無提及填空字串。
+- split_among：若文字中有提及分帳（如「小明小華平分」、「大家分」），列出分攤這筆費用的每個人名；若使用者有提供分帳成員清單，請拆成清單中的名稱（例如「小明小華」拆成「小明」、「小華」），「大家」代表清單中全部成員，使用者本人填「我」。付款人只有在文字表示自己也分攤時才列入。若無提及填空陣列。
+- 若完全無法解析出金額，仍請回傳 items=[] — 呼叫端會視為失敗。
+- 不要輸出 total。`
+
+//nolint:lll // prompt is intentionally a single literal block
+const promptV1ImageEn = `You are a receipt reading assistant. Extract the purchase details from the image and reply using the given JSON Schema.
+
+Rules:
+- title is the store name. Use an empty string if it can't be read.
+- date is the purchase date and time on the receipt, formatted YYYY-MM-DD HH:mm, or YYYY-MM-DD when there is no time. Use null if it can't be read.
+- List items in the order they appear on the receipt.
+- unit_price is the price per unit (not the line subtotal). quantity is the count.
+- For a discount on the whole receipt, add an item named "Discount" with a negative unit_price and quantity=1.
+- currency: the receipt's currency as an upper-case ISO 4217 code (e.g. TWD, JPY, USD). Prefer the user's currency list when given. Use an empty string if unknown.
+- tax: fill only when tax is listed separately and not included in item prices (e.g. US sales tax); use 0 for tax-inclusive prices such as in Taiwan or Japan.
+- tip: service charge or tip amount (e.g. a 10% service charge), otherwise 0. Don't repeat tax or service charge in items.
+- invoice_number: the Taiwan uniform-invoice number (two letters + 8 digits, e.g. AB-12345678). Use an empty string if this isn't a uniform invoice or it can't be read.
+- category: the best-fitting category for the purchase. Prefer the user's category list when given; otherwise write your own. Use an empty string if unsure.
+- payment_method: fill when the receipt shows how it was paid (credit card, cash, Line Pay, ...). Prefer the user's payment method list when given; otherwise write your own. Use an empty string if unknown.
+- Don't output a total; the caller computes it.`
+
+//nolint:lll // prompt is intentionally a single literal block
+const promptV1TextEn = `You are an expense entry parser. The user gives you a short line of text (e.g. "parking 100", "yesterday 7-11 coffee 55", "lunch 250 card"). Extract the expense and reply using the given JSON Schema.
+
+Rules:
+- title is the store name when the text mentions one (e.g. "7-11", "Costco"), otherwise an empty string.
+- Treat the whole entry as exactly one item; items has a single element.
+- item.name is what was bought (e.g. "Parking", "Lunch", "Coffee") without the amount, date or store. Use "Untitled" if there is only an amount.
+- item.unit_price is the amount; quantity is always 1.
+- Resolve relative dates ("yesterday", "2 days ago") from today's date and output YYYY-MM-DD HH:mm (YYYY-MM-DD without a time); use null if there is no date.
+- category: the best-fitting category for the purchase. Prefer the user's category list when given; otherwise write your own. Use an empty string if unsure.
+- payment_method: fill when the text mentions how it was paid ("card", "cash", "Line Pay"). Prefer the user's payment method list when given; otherwise write your own. Use an empty string if not mentioned.
+- payer: who paid, when mentioned ("I paid", "Tom paid"). Always write "me" for the user themselves. Use an empty string if not mentioned.
+- split_among: when the text mentions splitting ("split with Tom and Ann", "split between everyone"), list every person sharing the cost. When the user provides split members, use names from that list; "everyone" means all members; write "me" for the user. Include the payer only when the text says they share the cost too. Use an empty array if not mentioned.
+- If no amount can be found, still reply with items=[]; the caller treats it as a failure.
+- Don't output a total.`
+
+//nolint:lll // prompt is intentionally a single literal block
+const promptV1ImageJa = `あなたはレシート読み取りアシスタントです。画像から購入情報を抽出し、指定された JSON Schema で返してください。
+
+ルール：
+- title は店名です。読み取れない場合は空文字にしてください。
+- date はレシートの購入日時で、形式は YYYY-MM-DD HH:mm です。時刻がない場合は YYYY-MM-DD、読み取れない場合は null にしてください。
+- items はレシートの記載順に並べてください。
+- unit_price は単価（小計ではありません）、quantity は数量です。
+- 会計全体の値引きがある場合は、name="値引き"、unit_price を負の値、quantity=1 とした品目を追加してください。
+- currency：レシートの通貨を ISO 4217 の大文字3文字（TWD、JPY、USD など）で。通貨リストが提供されていればその中から優先して選んでください。不明な場合は空文字。
+- tax：税金が品目価格と別に記載されている場合のみ記入してください（米国の sales tax など）。台湾や日本のような税込価格の場合は 0。
+- tip：サービス料やチップの金額（10% サービス料など）。なければ 0。税金とサービス料を items に重複して入れないでください。
+- invoice_number：台湾の統一発票番号（英字2文字＋数字8桁、例 AB-12345678）。統一発票でない場合や読み取れない場合は空文字。
+- category：購入内容に最も合うカテゴリ。カテゴリリストが提供されていればその中から優先して選び、合うものがなければ自由に記入してください。判断できない場合は空文字。
+- payment_method：レシートに支払方法（クレジットカード、現金、Line Pay など）が記載されていれば記入してください。支払方法リストが提供されていればその中から優先して選び、合うものがなければ自由に記入してください。不明な場合は空文字。
+- total は出力しないでください。呼び出し側で計算します。`
+
+//nolint:lll // prompt is intentionally a single literal block
+const promptV1TextJa = `あなたは記帳入力の解析アシスタントです。ユーザーは短いテキスト（例「駐車場 100」「昨日 セブン コーヒー 55」「ランチ 250 カード」）を送ります。購入情報を抽出し、指定された JSON Schema で返してください。
+
+ルール：
+- title は店名です。テキストに店名（「セブン」「ローソン」など）があれば記入し、なければ空文字にしてください。
+- 入力全体を「1つ」の品目として扱い、items は1件だけにしてください。
+- item.name は購入したもの（「駐車場」「ランチ」「コーヒー」など）で、金額・日付・店名は含めないでください。金額しかない場合は「無題」。
+- item.unit_price は金額、quantity は常に 1 です。
+- 相対的な日付（「昨日」「おととい」）は今日の日付から計算し、YYYY-MM-DD HH:mm（時刻がなければ YYYY-MM-DD）で出力してください。日付がなければ null。
+- category：購入内容に最も合うカテゴリ。カテゴリリストが提供されていればその中から優先して選び、合うものがなければ自由に記入してください。判断できない場合は空文字。
+- payment_method：テキストに支払方法（「カード」「現金」「Line Pay」）があれば記入してください。支払方法リストが提供されていればその中から優先して選び、合うものがなければ自由に記入してください。なければ空文字。
+- payer：誰が支払ったか（「私が払った」「太郎が払った」）が書かれていれば記入してください。ユーザー本人は必ず「私」と書いてください。なければ空文字。
+- split_among：割り勘の記述（「太郎と花子で割り勘」「みんなで割る」）があれば、費用を分担する全員の名前を並べてください。割り勘メンバーが提供されていればその名前を使い、「みんな」は全メンバー、ユーザー本人は「私」としてください。支払者は自分も分担すると書かれている場合のみ含めてください。なければ空の配列。
+- 金額がまったく読み取れない場合でも items=[] で返してください。呼び出し側で失敗として扱います。
+- total は出力しないでください。`
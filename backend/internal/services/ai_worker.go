@@ -4,14 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"lovelion/internal/events"
+	"lovelion/internal/imaging"
 	"lovelion/internal/models"
 
 	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -20,12 +24,15 @@ const (
 	aiStatusProcessing = "processing"
 	aiStatusCompleted  = "completed"
 	aiStatusFailed     = "failed"
+	// aiStatusSkipped means extraction was deliberately not run, e.g. for a
+	// photo already extracted elsewhere; ai_error then says why.
+	aiStatusSkipped = "skipped"
 )
 
 // ImageDownloader is the minimum the worker needs from the storage layer.
 // This allows tests to inject a fake without pulling in the S3 client.
 type ImageDownloader interface {
-	DownloadByURL(ctx context.Context, fullURL string) ([]byte, string, error)
+	Download(ctx context.Context, key string) ([]byte, string, error)
 }
 
 // AIWorkerConfig controls polling cadence. Zero values get sensible defaults.
@@ -42,6 +49,8 @@ type AIWorker struct {
 	extractor     ReceiptExtractor
 	textExtractor TextExtractor // optional; required only for no-image rows
 	storage       ImageDownloader
+	suggestions   *SuggestionService
+	publisher     EventPublisher // optional; pushes ai_status changes to SSE clients
 	cfg           AIWorkerConfig
 	// In-memory retry state per transaction ID. Resets on restart, which is
 	// fine — startup recovery re-queues processing→pending anyway.
@@ -64,12 +73,13 @@ func NewAIWorker(db *gorm.DB, extractor ReceiptExtractor, storage ImageDownloade
 		cfg.MaxRetries = 3
 	}
 	return &AIWorker{
-		db:        db,
-		extractor: extractor,
-		storage:   storage,
-		cfg:       cfg,
-		retries:   make(map[string]int),
-		retryAt:   make(map[string]time.Time),
+		db:          db,
+		extractor:   extractor,
+		storage:     storage,
+		suggestions: NewSuggestionService(db),
+		cfg:         cfg,
+		retries:     make(map[string]int),
+		retryAt:     make(map[string]time.Time),
 	}
 }
 
@@ -80,6 +90,32 @@ func (w *AIWorker) WithTextExtractor(te TextExtractor) *AIWorker {
 	return w
 }
 
+// WithEvents publishes every ai_status transition the worker makes.
+func (w *AIWorker) WithEvents(p EventPublisher) *AIWorker {
+	w.publisher = p
+	return w
+}
+
+// publishStatus notifies subscribers of the transaction's space. The space is
+// looked up here so the hot path stays unchanged when events are disabled.
+func (w *AIWorker) publishStatus(ctx context.Context, txnID, status, aiError string) {
+	if w.publisher == nil {
+		return
+	}
+	var txn models.Transaction
+	if err := w.db.WithContext(ctx).Select("space_id").Where("id = ?", txnID).First(&txn).Error; err != nil {
+		slog.Warn("ai worker publish lookup failed", "txn_id", txnID, "error", err)
+		return
+	}
+	w.publisher.Publish(events.Event{
+		Type:          events.TypeAIStatus,
+		SpaceID:       txn.SpaceID,
+		TransactionID: txnID,
+		AIStatus:      status,
+		AIError:       aiError,
+	})
+}
+
 // Run executes the worker loop until ctx is cancelled. It performs a startup
 // recovery pass (processing→pending) so rows orphaned by a previous shutdown
 // get re-queued.
@@ -172,6 +208,7 @@ func (w *AIWorker) processOne(ctx context.Context, txnID, title string) {
 		log.Debug("ai worker row no longer pending, skipping")
 		return
 	}
+	w.publishStatus(ctx, txnID, aiStatusProcessing, "")
 
 	// Stage 2: load space config for extraction hints (categories, payment methods).
 	hints, err := w.loadSpaceHints(ctx, txnID)
@@ -188,13 +225,30 @@ func (w *AIWorker) processOne(ctx context.Context, txnID, title string) {
 	}
 
 	var result *ReceiptData
+	source := aiSourceText
+	meta := aiRequestMeta{Hints: hints}
+	callStart := time.Now()
 	if hasImage {
-		imgBytes, mimeType, loadErr := w.loadFirstImage(ctx, txnID)
+		// The same photo uploaded again would cost another call only to
+		// produce the same numbers.
+		dup, dupErr := w.exactDuplicateOf(ctx, txnID)
+		if dupErr != nil {
+			log.Warn("ai worker duplicate check failed", "error", dupErr)
+		} else if dup != nil {
+			log.Info("ai worker skipping duplicate receipt", "duplicate_of", dup.ID)
+			w.writeOutcome(ctx, txnID, aiStatusSkipped, fmt.Sprintf("收據與「%s」(%s) 相同，已略過辨識", dup.Title, dup.ID))
+			return
+		}
+
+		source = aiSourceImage
+		imgBytes, mimeType, imageKey, loadErr := w.loadFirstImage(ctx, txnID)
 		if loadErr != nil {
 			log.Warn("ai worker load image failed", "error", loadErr)
 			w.writeFailure(ctx, txnID, friendlyExtractError(loadErr))
 			return
 		}
+		meta.ImageKey, meta.MimeType, meta.ImageBytes = imageKey, mimeType, len(imgBytes)
+		callStart = time.Now()
 		result, err = w.extractor.Extract(ctx, imgBytes, mimeType, hints)
 	} else {
 		if w.textExtractor == nil {
@@ -206,6 +260,7 @@ func (w *AIWorker) processOne(ctx context.Context, txnID, title string) {
 			w.writeFailure(ctx, txnID, "無文字可辨識")
 			return
 		}
+		meta.Text = title
 		result, err = w.textExtractor.ExtractText(ctx, title, hints)
 	}
 
@@ -217,33 +272,35 @@ func (w *AIWorker) processOne(ctx context.Context, txnID, title string) {
 			return
 		}
 
+		attempt := newAttempt(txnID, source, meta, nil, err, time.Since(callStart))
 		if isRetryableError(err) {
 			w.retries[txnID]++
-			attempt := w.retries[txnID]
-			if attempt <= w.cfg.MaxRetries {
+			n := w.retries[txnID]
+			if n <= w.cfg.MaxRetries {
 				// Exponential backoff: 30s, 60s, 120s.
-				backoff := time.Duration(30<<(attempt-1)) * time.Second
+				backoff := time.Duration(30<<(n-1)) * time.Second
 				w.retryAt[txnID] = time.Now().Add(backoff)
 				log.Warn("ai worker retryable error, re-queuing",
-					"error", err, "attempt", attempt, "max", w.cfg.MaxRetries,
+					"error", err, "attempt", n, "max", w.cfg.MaxRetries,
 					"backoff", backoff)
+				attempt.Status = attemptStatusRetry
+				w.saveAttempt(ctx, attempt)
 				w.writeRetry(ctx, txnID)
 				return
 			}
-			log.Warn("ai worker max retries reached", "error", err, "attempts", attempt)
+			log.Warn("ai worker max retries reached", "error", err, "attempts", n)
 		}
 
 		log.Warn("ai worker extract failed", "error", err)
+		w.saveAttempt(ctx, attempt)
 		w.writeFailure(ctx, txnID, friendlyExtractError(err))
 		delete(w.retries, txnID)
 		delete(w.retryAt, txnID)
 		return
 	}
+	w.saveAttempt(ctx, newAttempt(txnID, source, meta, result, nil, time.Since(callStart)))
 
-	// Stage 3: write success back inside a short db.Transaction.
-	// For text-extraction rows we also overwrite the original raw input title
-	// with the cleaned item name so the ledger reads naturally.
-	if err := w.writeSuccess(ctx, txnID, result, !hasImage); err != nil {
+	if err := w.finishExtraction(ctx, txnID, result, hints, !hasImage); err != nil {
 		log.Error("ai worker write-back failed", "error", err)
 		w.writeFailure(ctx, txnID, "failed to save result")
 		delete(w.retries, txnID)
@@ -253,9 +310,42 @@ func (w *AIWorker) processOne(ctx context.Context, txnID, title string) {
 
 	delete(w.retries, txnID)
 	delete(w.retryAt, txnID)
+	w.publishStatus(ctx, txnID, aiStatusCompleted, "")
 	log.Info("ai worker completed", "items", len(result.Items), "elapsed", time.Since(start))
 }
 
+// finishExtraction applies the space-specific mapping to a parsed result and
+// writes it back. Shared by live extraction and Reparse; the row must be in
+// processing.
+func (w *AIWorker) finishExtraction(ctx context.Context, txnID string, result *ReceiptData, hints ExtractHints, textPath bool) error {
+	log := slog.With("txn_id", txnID)
+
+	// Only keep a currency the space actually uses; anything else would leave
+	// the row in a currency the UI can't convert.
+	result.Currency = matchCurrency(result.Currency, hints.Currencies)
+
+	// Mappings learned from the space's history beat the LLM's guess, so a
+	// category the user keeps correcting sticks after the first fix.
+	if err := w.applyLearnedMappings(ctx, txnID, result, textPath); err != nil {
+		log.Warn("ai worker learned mappings failed", "error", err)
+	}
+
+	// Quick text entry may also say who paid and who shares ("我付 小明小華平分").
+	var split splitPlan
+	if textPath && result.Split != nil {
+		self, selfErr := w.loadCreatorMemberName(ctx, txnID, hints.SplitMembers)
+		if selfErr != nil {
+			log.Warn("ai worker load creator name failed", "error", selfErr)
+		}
+		split = resolveSplit(result.Split, hints.SplitMembers, self)
+	}
+
+	// Write success back inside a short db.Transaction. For text-extraction
+	// rows we also overwrite the original raw input title with the cleaned
+	// item name so the ledger reads naturally.
+	return w.writeSuccess(ctx, txnID, result, textPath, split)
+}
+
 // hasTransactionImage reports whether any image is attached to the given
 // transaction. Used to pick the image- vs text-extraction path.
 func (w *AIWorker) hasTransactionImage(ctx context.Context, txnID string) (bool, error) {
@@ -270,57 +360,157 @@ func (w *AIWorker) hasTransactionImage(ctx context.Context, txnID string) (bool,
 	return count > 0, nil
 }
 
+// exactDuplicateOf returns the transaction in the same space that already
+// had the byte-identical first image of txnID attached before it, or nil.
+// Images without a content hash never match.
+func (w *AIWorker) exactDuplicateOf(ctx context.Context, txnID string) (*models.Transaction, error) {
+	var first models.Image
+	err := w.db.WithContext(ctx).
+		Where("entity_type = ? AND entity_id = ?", ImageEntityTransaction, txnID).
+		Order("sort_order ASC, created_at ASC").
+		First(&first).Error
+	if err != nil || first.ContentHash == "" {
+		return nil, err
+	}
+	var dup models.Transaction
+	err = w.db.WithContext(ctx).
+		Select("transactions.*").
+		Joins("JOIN images ON images.entity_type = ? AND images.entity_id = transactions.id", ImageEntityTransaction).
+		Where("images.content_hash = ? AND images.created_at < ? AND transactions.id <> ?", first.ContentHash, first.CreatedAt, txnID).
+		Where("transactions.space_id = (SELECT space_id FROM transactions WHERE id = ?)", txnID).
+		Order("images.created_at ASC").
+		First(&dup).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dup, nil
+}
+
 // loadFirstImage fetches the earliest (sort_order ASC) image for a transaction
 // and downloads its bytes from storage.
-func (w *AIWorker) loadFirstImage(ctx context.Context, txnID string) ([]byte, string, error) {
+func (w *AIWorker) loadFirstImage(ctx context.Context, txnID string) (data []byte, mimeType, key string, err error) {
 	var img models.Image
-	err := w.db.WithContext(ctx).
+	err = w.db.WithContext(ctx).
 		Where("entity_type = ? AND entity_id = ?", "transaction", txnID).
 		Order("sort_order ASC, created_at ASC").
 		First(&img).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, "", errors.New("no image attached")
+			return nil, "", "", errors.New("no image attached")
 		}
-		return nil, "", err
+		return nil, "", "", err
 	}
 
-	data, ct, err := w.storage.DownloadByURL(ctx, img.FilePath)
+	data, ct, err := w.storage.Download(ctx, img.FilePath)
 	if err != nil {
-		return nil, "", err
+		return nil, "", "", err
 	}
-	if ct == "" {
+	// Trust the bytes over the stored content type: the local backend
+	// guesses from the extension and knows nothing about HEIC.
+	if sniffed := imaging.MimeType(data); sniffed != "" {
+		ct = sniffed
+	} else if ct == "" || ct == "application/octet-stream" {
 		ct = guessMimeFromURL(img.FilePath)
 	}
-	return data, ct, nil
+	return data, ct, img.FilePath, nil
 }
 
-// loadSpaceHints reads the space's categories and payment_methods for a given
-// transaction so the LLM can prefer existing values.
+// loadSpaceHints reads the space's option lists and prompt settings for a
+// given transaction so the LLM can prefer existing values.
 func (w *AIWorker) loadSpaceHints(ctx context.Context, txnID string) (ExtractHints, error) {
 	var space models.Space
 	err := w.db.WithContext(ctx).
-		Select("categories", "payment_methods").
+		Select("categories", "payment_methods", "currencies", "split_members", "ai_locale", "ai_instructions").
 		Joins("JOIN transactions ON transactions.space_id = spaces.id").
 		Where("transactions.id = ?", txnID).
 		First(&space).Error
 	if err != nil {
 		return ExtractHints{}, err
 	}
-	var hints ExtractHints
+	hints := ExtractHints{Locale: space.AILocale, Instructions: space.AIInstructions}
 	if len(space.Categories) > 0 {
 		_ = json.Unmarshal(space.Categories, &hints.Categories)
 	}
 	if len(space.PaymentMethods) > 0 {
 		_ = json.Unmarshal(space.PaymentMethods, &hints.PaymentMethods)
 	}
+	if len(space.Currencies) > 0 {
+		_ = json.Unmarshal(space.Currencies, &hints.Currencies)
+	}
+	if len(space.SplitMembers) > 0 {
+		_ = json.Unmarshal(space.SplitMembers, &hints.SplitMembers)
+	}
 	return hints, nil
 }
 
+// applyLearnedMappings overrides the extracted category and payment method
+// with the ones this space has used before for the same merchant or items.
+// textPath mirrors writeSuccess: the row will be titled after its first item.
+func (w *AIWorker) applyLearnedMappings(ctx context.Context, txnID string, data *ReceiptData, textPath bool) error {
+	var txn models.Transaction
+	if err := w.db.WithContext(ctx).Select("space_id").Where("id = ?", txnID).First(&txn).Error; err != nil {
+		return err
+	}
+
+	q := SuggestQuery{Title: data.Title, ExcludeTxnID: txnID}
+	for _, it := range data.Items {
+		q.ItemNames = append(q.ItemNames, it.Name)
+	}
+	if textPath && len(data.Items) > 0 {
+		q.Title = data.Items[0].Name
+	}
+
+	learned, err := w.suggestions.Suggest(ctx, txn.SpaceID, q)
+	if err != nil {
+		return err
+	}
+	if learned.Category != "" {
+		data.Category = learned.Category
+	}
+	if learned.PaymentMethod != "" {
+		data.PaymentMethod = learned.PaymentMethod
+	}
+	return nil
+}
+
+// loadCreatorMemberName finds which split member the transaction's creator is,
+// so "我" in quick text entry can be resolved. The member alias wins over the
+// display name; "" means the creator isn't (recognisably) a split member.
+func (w *AIWorker) loadCreatorMemberName(ctx context.Context, txnID string, members []string) (string, error) {
+	var row struct {
+		Alias       string
+		DisplayName string
+	}
+	err := w.db.WithContext(ctx).
+		Table("transactions").
+		Select("space_members.alias, users.display_name").
+		Joins("JOIN users ON users.id = transactions.created_by").
+		Joins("LEFT JOIN space_members ON space_members.space_id = transactions.space_id AND space_members.user_id = transactions.created_by").
+		Where("transactions.id = ?", txnID).
+		Take(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, name := range []string{row.Alias, row.DisplayName} {
+		if m := matchMember(name, members); m != "" {
+			return m, nil
+		}
+	}
+	return "", nil
+}
+
 // writeSuccess updates the transaction + replaces expense items in one tx.
 // The WHERE ai_status='processing' guard lets a concurrent cancel cause the
-// whole write to be a no-op.
-func (w *AIWorker) writeSuccess(ctx context.Context, txnID string, data *ReceiptData, overwriteTitle bool) error {
+// whole write to be a no-op. A resolved split becomes debts; an ambiguous one
+// is stored as a suggestion for the user to confirm, as is a currency that
+// existing debts can't follow.
+func (w *AIWorker) writeSuccess(ctx context.Context, txnID string, data *ReceiptData, overwriteTitle bool, split splitPlan) error {
 	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		updates := map[string]interface{}{
 			"ai_status": aiStatusCompleted,
@@ -349,6 +539,16 @@ func (w *AIWorker) writeSuccess(ctx context.Context, txnID string, data *Receipt
 			// Image path: use store name from receipt.
 			updates["title"] = data.Title
 		}
+		if data.PromptVersion != "" {
+			updates["ai_prompt_version"] = data.PromptVersion
+		}
+		if split.Suggestion != nil {
+			raw, err := json.Marshal(split.Suggestion)
+			if err != nil {
+				return err
+			}
+			updates["ai_split_suggestion"] = datatypes.JSON(raw)
+		}
 
 		result := tx.Model(&models.Transaction{}).
 			Where("id = ? AND ai_status = ?", txnID, aiStatusProcessing).
@@ -377,6 +577,9 @@ func (w *AIWorker) writeSuccess(ctx context.Context, txnID string, data *Receipt
 		if data.PaymentMethod != "" {
 			expenseUpdates["payment_method"] = data.PaymentMethod
 		}
+		if data.InvoiceNumber != "" {
+			expenseUpdates["invoice_number"] = data.InvoiceNumber
+		}
 		if len(expenseUpdates) > 0 {
 			if err := tx.Model(&expense).Updates(expenseUpdates).Error; err != nil {
 				return err
@@ -388,7 +591,7 @@ func (w *AIWorker) writeSuccess(ctx context.Context, txnID string, data *Receipt
 			return err
 		}
 
-		items, totalAmount := buildExpenseItems(expense.ID, convertReceiptItems(data.Items))
+		items, totalAmount := buildExpenseItems(expense.ID, convertReceiptItems(withSurchargeItems(data)))
 		if len(items) > 0 {
 			if err := tx.Create(&items).Error; err != nil {
 				return err
@@ -396,36 +599,143 @@ func (w *AIWorker) writeSuccess(ctx context.Context, txnID string, data *Receipt
 		}
 
 		// Refresh total_amount to match the new items.
-		return tx.Model(&models.Transaction{}).
+		if err := tx.Model(&models.Transaction{}).
 			Where("id = ?", txnID).
-			Update("total_amount", totalAmount).Error
+			Update("total_amount", totalAmount).Error; err != nil {
+			return err
+		}
+
+		if err := applyReceiptCurrency(tx, txnID, data.Currency, totalAmount, &expense); err != nil {
+			return err
+		}
+		return writeSplitDebts(tx, txnID, split, totalAmount, expense)
 	})
 }
 
+// applyReceiptCurrency switches the transaction to the receipt's currency.
+// The billing amount and exchange rate belonged to the old currency, so they
+// are reset and the settled amounts of existing debts recomputed from them.
+// Debts that don't add up to the new total were entered in the old currency
+// and can't be carried over; then the currency is left alone and stored as
+// ai_currency_suggestion for the user to confirm.
+func applyReceiptCurrency(tx *gorm.DB, txnID, currency string, totalAmount decimal.Decimal, expense *models.TransactionExpense) error {
+	if currency == "" {
+		return nil
+	}
+	var txn models.Transaction
+	if err := tx.Select("currency").Where("id = ?", txnID).First(&txn).Error; err != nil {
+		return err
+	}
+	if txn.Currency == currency {
+		return nil
+	}
+	var debts []models.TransactionDebt
+	if err := tx.Where("transaction_id = ?", txnID).Find(&debts).Error; err != nil {
+		return err
+	}
+	sum := decimal.Zero
+	for _, d := range debts {
+		sum = sum.Add(d.Amount)
+	}
+	if len(debts) > 0 && !sum.Equal(totalAmount) {
+		return tx.Model(&models.Transaction{}).Where("id = ?", txnID).
+			Update("ai_currency_suggestion", currency).Error
+	}
+
+	if err := tx.Model(&models.Transaction{}).Where("id = ?", txnID).
+		Updates(map[string]interface{}{"currency": currency, "ai_currency_suggestion": nil}).Error; err != nil {
+		return err
+	}
+	expense.ExchangeRate = decimal.NewFromInt(1)
+	expense.BillingAmount = decimal.Zero
+	if err := tx.Model(expense).Updates(map[string]interface{}{
+		"exchange_rate":  expense.ExchangeRate,
+		"billing_amount": expense.BillingAmount,
+	}).Error; err != nil {
+		return err
+	}
+	expenseInput := ExpenseInput{ExchangeRate: expense.ExchangeRate, HandlingFee: expense.HandlingFee}
+	for _, d := range debts {
+		settled := calcSettledAmount(DebtInput{Amount: d.Amount, IsSpotPaid: d.IsSpotPaid}, totalAmount, expenseInput, currency)
+		if err := tx.Model(&models.TransactionDebt{}).Where("id = ?", d.ID).
+			Update("settled_amount", settled).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSplitDebts creates equal-share debts for a resolved split through the
+// same buildDebts path the service uses. Debts the user already entered are
+// left alone.
+func writeSplitDebts(tx *gorm.DB, txnID string, split splitPlan, totalAmount decimal.Decimal, expense models.TransactionExpense) error {
+	if split.Payer == "" || len(split.Participants) == 0 || !totalAmount.IsPositive() {
+		return nil
+	}
+	var existing int64
+	if err := tx.Model(&models.TransactionDebt{}).Where("transaction_id = ?", txnID).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+	var txn models.Transaction
+	if err := tx.Select("currency").Where("id = ?", txnID).First(&txn).Error; err != nil {
+		return err
+	}
+
+	inputs := equalSplitDebts(split.Payer, split.Participants, totalAmount)
+	if len(inputs) == 0 {
+		return nil
+	}
+	expenseInput := ExpenseInput{
+		ExchangeRate:  expense.ExchangeRate,
+		BillingAmount: expense.BillingAmount,
+		HandlingFee:   expense.HandlingFee,
+	}
+	debts := buildDebts(txnID, inputs, totalAmount, &expenseInput, txn.Currency)
+	return tx.Create(&debts).Error
+}
+
 // writeRetry sets the row back to pending so the next poll picks it up.
 // Uses the same conditional WHERE so a racing cancel wins.
 func (w *AIWorker) writeRetry(ctx context.Context, txnID string) {
-	err := w.db.WithContext(ctx).
+	result := w.db.WithContext(ctx).
 		Model(&models.Transaction{}).
 		Where("id = ? AND ai_status = ?", txnID, aiStatusProcessing).
-		Update("ai_status", aiStatusPending).Error
-	if err != nil {
-		slog.Error("ai worker retry re-queue failed", "txn_id", txnID, "error", err)
+		Update("ai_status", aiStatusPending)
+	if result.Error != nil {
+		slog.Error("ai worker retry re-queue failed", "txn_id", txnID, "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		w.publishStatus(ctx, txnID, aiStatusPending, "")
 	}
 }
 
-// writeFailure flips the row to failed with an error message. Uses the same
-// conditional WHERE so a racing cancel wins.
+// writeFailure flips the row to failed with an error message.
 func (w *AIWorker) writeFailure(ctx context.Context, txnID, message string) {
-	err := w.db.WithContext(ctx).
+	w.writeOutcome(ctx, txnID, aiStatusFailed, message)
+}
+
+// writeOutcome ends a run that wrote no extraction, as failed or skipped,
+// with a message for the user. Uses the same conditional WHERE so a racing
+// cancel wins.
+func (w *AIWorker) writeOutcome(ctx context.Context, txnID, status, message string) {
+	message = truncateError(message, 500)
+	result := w.db.WithContext(ctx).
 		Model(&models.Transaction{}).
 		Where("id = ? AND ai_status = ?", txnID, aiStatusProcessing).
 		Updates(map[string]interface{}{
-			"ai_status": aiStatusFailed,
-			"ai_error":  truncateError(message, 500),
-		}).Error
-	if err != nil {
-		slog.Error("ai worker mark failed", "txn_id", txnID, "error", err)
+			"ai_status": status,
+			"ai_error":  message,
+		})
+	if result.Error != nil {
+		slog.Error("ai worker mark "+status, "txn_id", txnID, "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		w.publishStatus(ctx, txnID, status, message)
 	}
 }
 
@@ -447,6 +757,129 @@ func convertReceiptItems(in []ReceiptItem) []ExpenseItemInput {
 	return out
 }
 
+// withSurchargeItems appends tax and service charge / tip as their own line
+// items so they are counted in total_amount and split like any other item.
+func withSurchargeItems(data *ReceiptData) []ReceiptItem {
+	items := append([]ReceiptItem(nil), data.Items...)
+	if data.Tax.IsPositive() {
+		items = append(items, ReceiptItem{Name: "稅金", UnitPrice: data.Tax, Quantity: decimal.NewFromInt(1)})
+	}
+	if data.Tip.IsPositive() {
+		items = append(items, ReceiptItem{Name: "服務費", UnitPrice: data.Tip, Quantity: decimal.NewFromInt(1)})
+	}
+	return items
+}
+
+// splitPlan is the worker's reading of SplitInfo against the space's split
+// members. Either Payer/Participants are set (write debts) or Suggestion is
+// (ask the user); the zero value means nothing to do.
+type splitPlan struct {
+	Payer        string
+	Participants []string
+	Suggestion   *models.AISplitSuggestion
+}
+
+// selfReferences are the words the text prompt uses for the creator.
+var selfReferences = []string{"我", "me", "i", "私"}
+
+// resolveSplit maps the names in info to split members. self is the creator's
+// member name ("" if unknown). Any unknown name, a missing payer or a missing
+// participant list makes the whole split a suggestion instead.
+func resolveSplit(info *SplitInfo, members []string, self string) splitPlan {
+	if info == nil {
+		return splitPlan{}
+	}
+	var unmatched []string
+	resolve := func(name string) string {
+		for _, ref := range selfReferences {
+			if strings.EqualFold(name, ref) {
+				if self == "" {
+					unmatched = append(unmatched, name)
+				}
+				return self
+			}
+		}
+		m := matchMember(name, members)
+		if m == "" {
+			unmatched = append(unmatched, name)
+		}
+		return m
+	}
+
+	payer := ""
+	if info.Payer != "" {
+		payer = resolve(info.Payer)
+	}
+	var participants []string
+	seen := map[string]bool{}
+	for _, name := range info.Participants {
+		if m := resolve(name); m != "" && !seen[m] {
+			seen[m] = true
+			participants = append(participants, m)
+		}
+	}
+
+	if len(unmatched) == 0 && payer != "" && len(participants) > 0 {
+		return splitPlan{Payer: payer, Participants: participants}
+	}
+	return splitPlan{Suggestion: &models.AISplitSuggestion{
+		PayerName:    info.Payer,
+		Participants: info.Participants,
+		Unmatched:    unmatched,
+	}}
+}
+
+// matchMember returns the split member equal to name (case-insensitive), or "".
+func matchMember(name string, members []string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	for _, m := range members {
+		if strings.EqualFold(m, name) {
+			return m
+		}
+	}
+	return ""
+}
+
+// equalSplitDebts splits total evenly across participants the way the expense
+// form's "平分" button does: whole-unit shares with the remainder on the first
+// participant. The payer's own share needs no debt row.
+func equalSplitDebts(payer string, participants []string, total decimal.Decimal) []DebtInput {
+	n := decimal.NewFromInt(int64(len(participants)))
+	each := total.Div(n).Floor()
+	remainder := total.Sub(each.Mul(n))
+
+	var out []DebtInput
+	for i, name := range participants {
+		amount := each
+		if i == 0 {
+			amount = amount.Add(remainder)
+		}
+		if name == payer || !amount.IsPositive() {
+			continue
+		}
+		out = append(out, DebtInput{PayerName: name, PayeeName: payer, Amount: amount})
+	}
+	return out
+}
+
+// matchCurrency returns the space currency equal to code (case-insensitive),
+// or "" when the space doesn't list it.
+func matchCurrency(code string, allowed []string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return ""
+	}
+	for _, c := range allowed {
+		if strings.EqualFold(c, code) {
+			return c
+		}
+	}
+	return ""
+}
+
 // friendlyExtractError converts raw extractor errors into short user-facing strings.
 // The full error is still in server logs via slog.Warn above.
 func friendlyExtractError(err error) string {
@@ -500,6 +933,8 @@ func guessMimeFromURL(url string) string {
 		return "image/png"
 	case ".webp":
 		return "image/webp"
+	case ".heic", ".heif":
+		return "image/heic"
 	default:
 		return "image/jpeg"
 	}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
@@ -10,11 +11,13 @@ import (
 
 	"lovelion/internal/models"
 	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -27,13 +30,15 @@ type fakeExtractor struct {
 	results []*ReceiptData
 	errs    []error
 	// sleep is applied before returning; lets tests drive cancellation.
-	sleep time.Duration
+	sleep     time.Duration
+	lastHints ExtractHints
 }
 
-func (f *fakeExtractor) Extract(ctx context.Context, image []byte, mimeType string, _ ExtractHints) (*ReceiptData, error) {
+func (f *fakeExtractor) Extract(ctx context.Context, image []byte, mimeType string, hints ExtractHints) (*ReceiptData, error) {
 	f.mu.Lock()
 	idx := f.calls
 	f.calls++
+	f.lastHints = hints
 	f.mu.Unlock()
 
 	if f.sleep > 0 {
@@ -59,8 +64,8 @@ type fakeStorage struct {
 	downloaded  []string
 }
 
-func (f *fakeStorage) DownloadByURL(ctx context.Context, fullURL string) ([]byte, string, error) {
-	f.downloaded = append(f.downloaded, fullURL)
+func (f *fakeStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
+	f.downloaded = append(f.downloaded, key)
 	if f.err != nil {
 		return nil, "", f.err
 	}
@@ -82,7 +87,7 @@ func createTestSpace(t *testing.T, db *gorm.DB, ownerID uuid.UUID) *models.Space
 
 // createPendingExpense creates a transaction+expense row with ai_status=pending
 // and an attached image record. Returns the transaction ID.
-func createPendingExpense(t *testing.T, db *gorm.DB, spaceID uuid.UUID, imageURL string) string {
+func createPendingExpense(t *testing.T, db *gorm.DB, spaceID uuid.UUID, imageKey string) string {
 	t.Helper()
 	txnID := "txn_" + uuid.NewString()[:8]
 	pending := aiStatusPending
@@ -110,7 +115,7 @@ func createPendingExpense(t *testing.T, db *gorm.DB, spaceID uuid.UUID, imageURL
 		ID:         uuid.New(),
 		EntityID:   txnID,
 		EntityType: "transaction",
-		FilePath:   imageURL,
+		FilePath:   imageKey,
 		SortOrder:  0,
 	}
 	require.NoError(t, db.Create(img).Error)
@@ -151,7 +156,7 @@ func TestAIWorker_ProcessOne_Success(t *testing.T) {
 	}
 	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
 
-	txnID := createPendingExpense(t, db, space.ID, "https://cdn/test/transaction/a.jpg")
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
 
 	worker := newTestWorker(db, ext, store)
 	worker.processOne(context.Background(), txnID, "AI receipt")
@@ -169,7 +174,7 @@ func TestAIWorker_ProcessOne_Success(t *testing.T) {
 	assert.True(t, decimal.NewFromInt(280).Equal(txn.TotalAmount), "total_amount=%s", txn.TotalAmount)
 
 	assert.Equal(t, 1, ext.calls)
-	assert.Equal(t, []string{"https://cdn/test/transaction/a.jpg"}, store.downloaded)
+	assert.Equal(t, []string{"transaction/a.jpg"}, store.downloaded)
 }
 
 func TestAIWorker_ProcessOne_LLMError_MarksFailed(t *testing.T) {
@@ -180,7 +185,7 @@ func TestAIWorker_ProcessOne_LLMError_MarksFailed(t *testing.T) {
 	ext := &fakeExtractor{errs: []error{errors.New("gemini http 500: boom")}}
 	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
 
-	txnID := createPendingExpense(t, db, space.ID, "https://cdn/x.jpg")
+	txnID := createPendingExpense(t, db, space.ID, "transaction/x.jpg")
 
 	worker := newTestWorker(db, ext, store)
 	worker.processOne(context.Background(), txnID, "AI receipt")
@@ -281,7 +286,7 @@ func TestAIWorker_ProcessOne_ClaimRaceLosesSilently(t *testing.T) {
 	user := testutil.CreateTestUser(t, db)
 	space := createTestSpace(t, db, user.ID)
 
-	txnID := createPendingExpense(t, db, space.ID, "https://cdn/x.jpg")
+	txnID := createPendingExpense(t, db, space.ID, "transaction/x.jpg")
 
 	// Simulate another actor flipping status before worker claims.
 	require.NoError(t, db.Model(&models.Transaction{}).
@@ -306,7 +311,7 @@ func TestAIWorker_ProcessOne_CancelMidCall_NoWriteBack(t *testing.T) {
 
 	// Simulate cancel by flipping the row to NULL AFTER claim but BEFORE write-back:
 	// the fake extractor sleeps, and in the meantime we manually clear ai_status.
-	txnID := createPendingExpense(t, db, space.ID, "https://cdn/x.jpg")
+	txnID := createPendingExpense(t, db, space.ID, "transaction/x.jpg")
 
 	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
 	ext := &fakeExtractor{
@@ -374,7 +379,7 @@ func TestAIWorker_Run_ProcessesPendingThenShutsDown(t *testing.T) {
 	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
 	worker := newTestWorker(db, ext, store)
 
-	txnID := createPendingExpense(t, db, space.ID, "https://cdn/x.jpg")
+	txnID := createPendingExpense(t, db, space.ID, "transaction/x.jpg")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan struct{})
@@ -417,3 +422,363 @@ func TestAIWorker_FriendlyErrorMessages(t *testing.T) {
 		})
 	}
 }
+
+func TestAIWorker_ProcessOne_CurrencyInvoiceAndSurcharges(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	require.NoError(t, db.Model(space).Update("currencies", `["TWD","JPY"]`).Error)
+
+	ext := &fakeExtractor{
+		results: []*ReceiptData{{
+			Currency:      "jpy",
+			Tax:           decimal.NewFromInt(80),
+			Tip:           decimal.NewFromInt(100),
+			InvoiceNumber: "AB12345678",
+			Items: []ReceiptItem{
+				{Name: "Ramen", UnitPrice: decimal.NewFromInt(1000), Quantity: decimal.NewFromInt(1)},
+			},
+		}},
+	}
+	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+
+	worker := newTestWorker(db, ext, store)
+	worker.processOne(context.Background(), txnID, "AI receipt")
+
+	txn := loadTxn(t, db, txnID)
+	assert.Equal(t, "JPY", txn.Currency)
+	require.NotNil(t, txn.Expense)
+	assert.Equal(t, "AB12345678", txn.Expense.InvoiceNumber)
+	require.Len(t, txn.Expense.Items, 3)
+	// total = 1000 + 80 tax + 100 service charge
+	assert.True(t, decimal.NewFromInt(1180).Equal(txn.TotalAmount), "total_amount=%s", txn.TotalAmount)
+}
+
+func TestAIWorker_ProcessOne_UnknownCurrencyIgnored(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+
+	ext := &fakeExtractor{
+		results: []*ReceiptData{{
+			Currency: "USD",
+			Items:    []ReceiptItem{{Name: "A", UnitPrice: decimal.NewFromInt(10), Quantity: decimal.NewFromInt(1)}},
+		}},
+	}
+	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+
+	worker := newTestWorker(db, ext, store)
+	worker.processOne(context.Background(), txnID, "AI receipt")
+
+	// Space only allows TWD (the column default), so the transaction keeps it.
+	txn := loadTxn(t, db, txnID)
+	assert.Equal(t, "TWD", txn.Currency)
+}
+
+func TestAIWorker_ProcessOne_CurrencyChangeRecomputesDebts(t *testing.T) {
+	cases := []struct {
+		name         string
+		debtAmount   int64 // each of two debts
+		wantCurrency string
+		wantSettled  int64
+	}{
+		// The debts add up to the receipt total, so they follow the currency.
+		{"debts match the receipt", 500, "JPY", 500},
+		// Entered against another total: keep TWD and only suggest JPY.
+		{"debts entered in the old currency", 150, "TWD", 30},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := testutil.TestDB(t)
+			user := testutil.CreateTestUser(t, db)
+			space := createTestSpace(t, db, user.ID)
+			require.NoError(t, db.Model(space).Update("currencies", `["TWD","JPY"]`).Error)
+			txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+			require.NoError(t, db.Model(&models.TransactionExpense{}).Where("transaction_id = ?", txnID).
+				Updates(map[string]interface{}{"billing_amount": 60, "exchange_rate": 0.2}).Error)
+			for _, payer := range []string{"Amy", "Bob"} {
+				require.NoError(t, db.Create(&models.TransactionDebt{
+					ID: uuid.New(), TransactionID: txnID, PayerName: payer, PayeeName: "Amy",
+					Amount: decimal.NewFromInt(c.debtAmount), SettledAmount: decimal.NewFromInt(30),
+				}).Error)
+			}
+
+			ext := &fakeExtractor{results: []*ReceiptData{{
+				Currency: "JPY",
+				Items:    []ReceiptItem{{Name: "Ramen", UnitPrice: decimal.NewFromInt(1000), Quantity: decimal.NewFromInt(1)}},
+			}}}
+			worker := newTestWorker(db, ext, &fakeStorage{data: []byte("img"), contentType: "image/jpeg"})
+			worker.processOne(context.Background(), txnID, "AI receipt")
+
+			txn := loadTxn(t, db, txnID)
+			assert.Equal(t, c.wantCurrency, txn.Currency)
+			if c.wantCurrency == "JPY" {
+				assert.Nil(t, txn.AICurrency)
+				assert.True(t, txn.Expense.BillingAmount.IsZero(), "the TWD billing amount no longer applies")
+				assert.True(t, txn.Expense.ExchangeRate.Equal(decimal.NewFromInt(1)))
+			} else {
+				require.NotNil(t, txn.AICurrency)
+				assert.Equal(t, "JPY", *txn.AICurrency)
+				assert.True(t, txn.Expense.BillingAmount.Equal(decimal.NewFromInt(60)))
+			}
+			var debts []models.TransactionDebt
+			require.NoError(t, db.Where("transaction_id = ?", txnID).Find(&debts).Error)
+			require.Len(t, debts, 2)
+			for _, d := range debts {
+				assert.True(t, d.SettledAmount.Equal(decimal.NewFromInt(c.wantSettled)), "settled_amount=%s", d.SettledAmount)
+			}
+		})
+	}
+}
+
+// createPendingTextExpense creates a pending expense with no image, created by
+// userID, in a space whose split members are members.
+func createPendingTextExpense(t *testing.T, db *gorm.DB, userID uuid.UUID, members []string, title string) string {
+	t.Helper()
+	raw, err := json.Marshal(members)
+	require.NoError(t, err)
+	space := createTestSpace(t, db, userID)
+	require.NoError(t, db.Model(space).Update("split_members", datatypes.JSON(raw)).Error)
+
+	txnID := "txn_" + uuid.NewString()[:8]
+	pending := aiStatusPending
+	require.NoError(t, db.Create(&models.Transaction{
+		ID: txnID, SpaceID: space.ID, Type: "expense", Title: title,
+		Date: time.Now(), Currency: "TWD", TotalAmount: decimal.Zero, AIStatus: &pending,
+		CreatedBy: &userID,
+	}).Error)
+	require.NoError(t, db.Create(&models.TransactionExpense{
+		ID: uuid.New(), TransactionID: txnID, ExchangeRate: decimal.NewFromInt(1),
+	}).Error)
+	return txnID
+}
+
+func TestAIWorker_ProcessOne_TextSplitWritesDebts(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	txnID := createPendingTextExpense(t, db, user.ID, []string{user.DisplayName, "小明", "小華"}, "晚餐 1200 我付 小明小華平分")
+
+	text := &fakeTextExtractor{result: &ReceiptData{
+		Items: []ReceiptItem{{Name: "晚餐", UnitPrice: decimal.NewFromInt(1200), Quantity: decimal.NewFromInt(1)}},
+		Split: &SplitInfo{Payer: "我", Participants: []string{"小明", "小華"}},
+	}}
+	worker := newTestWorker(db, &fakeExtractor{}, &fakeStorage{}).WithTextExtractor(text)
+	worker.processOne(context.Background(), txnID, "晚餐 1200 我付 小明小華平分")
+
+	var debts []models.TransactionDebt
+	require.NoError(t, db.Where("transaction_id = ?", txnID).Order("payer_name").Find(&debts).Error)
+	require.Len(t, debts, 2)
+	for _, d := range debts {
+		assert.Equal(t, user.DisplayName, d.PayeeName)
+		assert.True(t, decimal.NewFromInt(600).Equal(d.Amount))
+		assert.True(t, decimal.NewFromInt(600).Equal(d.SettledAmount))
+	}
+	assert.Empty(t, loadTxn(t, db, txnID).AISplit)
+}
+
+func TestAIWorker_ProcessOne_AmbiguousSplitKeptAsSuggestion(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	txnID := createPendingTextExpense(t, db, user.ID, []string{user.DisplayName, "小明"}, "晚餐 1200 我付 小明阿華平分")
+
+	text := &fakeTextExtractor{result: &ReceiptData{
+		Items: []ReceiptItem{{Name: "晚餐", UnitPrice: decimal.NewFromInt(1200), Quantity: decimal.NewFromInt(1)}},
+		Split: &SplitInfo{Payer: "我", Participants: []string{"小明", "阿華"}},
+	}}
+	worker := newTestWorker(db, &fakeExtractor{}, &fakeStorage{}).WithTextExtractor(text)
+	worker.processOne(context.Background(), txnID, "晚餐 1200 我付 小明阿華平分")
+
+	var count int64
+	require.NoError(t, db.Model(&models.TransactionDebt{}).Where("transaction_id = ?", txnID).Count(&count).Error)
+	assert.Zero(t, count, "ambiguous split must not write debts")
+
+	got := loadTxn(t, db, txnID)
+	require.NotNil(t, got.AIStatus)
+	assert.Equal(t, aiStatusCompleted, *got.AIStatus)
+	var suggestion models.AISplitSuggestion
+	require.NoError(t, json.Unmarshal(got.AISplit, &suggestion))
+	assert.Equal(t, "我", suggestion.PayerName)
+	assert.Equal(t, []string{"阿華"}, suggestion.Unmatched)
+}
+
+func TestResolveSplit(t *testing.T) {
+	members := []string{"Alice", "小明", "小華"}
+
+	plan := resolveSplit(&SplitInfo{Payer: "我", Participants: []string{"我", "小明", "alice"}}, members, "小華")
+	assert.Nil(t, plan.Suggestion)
+	assert.Equal(t, "小華", plan.Payer)
+	assert.Equal(t, []string{"小華", "小明", "Alice"}, plan.Participants)
+
+	// Unknown creator: "我" can't be resolved.
+	plan = resolveSplit(&SplitInfo{Payer: "我", Participants: []string{"小明"}}, members, "")
+	require.NotNil(t, plan.Suggestion)
+	assert.Equal(t, []string{"我"}, plan.Suggestion.Unmatched)
+
+	// No participants mentioned: who shares is unknown.
+	plan = resolveSplit(&SplitInfo{Payer: "小明"}, members, "小華")
+	require.NotNil(t, plan.Suggestion)
+	assert.Empty(t, plan.Suggestion.Unmatched)
+
+	assert.Equal(t, splitPlan{}, resolveSplit(nil, members, ""))
+}
+
+func TestEqualSplitDebts(t *testing.T) {
+	debts := equalSplitDebts("小華", []string{"小明", "小華", "Alice"}, decimal.NewFromInt(1000))
+	require.Len(t, debts, 2)
+	assert.Equal(t, "小明", debts[0].PayerName)
+	assert.Equal(t, "小華", debts[0].PayeeName)
+	assert.True(t, decimal.NewFromInt(334).Equal(debts[0].Amount), "remainder goes to the first participant")
+	assert.Equal(t, "Alice", debts[1].PayerName)
+	assert.True(t, decimal.NewFromInt(333).Equal(debts[1].Amount))
+}
+
+func TestAIWorker_ProcessOne_LearnedCategoryOverridesLLM(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	createHistoryExpense(t, db, space.ID, "全聯", "生活", "悠遊卡", "衛生紙", time.Now().AddDate(0, 0, -1))
+
+	txnID := createPendingExpense(t, db, space.ID, "transaction/r.jpg")
+	ext := &fakeExtractor{results: []*ReceiptData{{
+		Title:    "全聯",
+		Category: "購物",
+		Items:    []ReceiptItem{{Name: "衛生紙", UnitPrice: decimal.NewFromInt(199), Quantity: decimal.NewFromInt(1)}},
+	}}}
+	worker := newTestWorker(db, ext, &fakeStorage{data: []byte("img"), contentType: "image/jpeg"})
+	worker.processOne(context.Background(), txnID, "AI receipt")
+
+	got := loadTxn(t, db, txnID)
+	require.NotNil(t, got.Expense)
+	assert.Equal(t, "生活", got.Expense.Category)
+	assert.Equal(t, "悠遊卡", got.Expense.PaymentMethod)
+}
+
+func TestAIWorker_ProcessOne_PromptSettingsAndVersion(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	require.NoError(t, db.Model(space).Updates(map[string]interface{}{
+		"ai_locale":       "ja",
+		"ai_instructions": "7-11 は常に 便利商店",
+	}).Error)
+
+	promptID := PromptID(CurrentPromptVersion, "ja", "7-11 は常に 便利商店")
+	ext := &fakeExtractor{results: []*ReceiptData{{
+		PromptVersion: promptID,
+		Items:         []ReceiptItem{{Name: "おにぎり", UnitPrice: decimal.NewFromInt(150), Quantity: decimal.NewFromInt(1)}},
+	}}}
+	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+
+	newTestWorker(db, ext, store).processOne(context.Background(), txnID, "AI receipt")
+
+	assert.Equal(t, "ja", ext.lastHints.Locale)
+	assert.Equal(t, "7-11 は常に 便利商店", ext.lastHints.Instructions)
+	assert.Equal(t, promptID, loadTxn(t, db, txnID).AIPrompt)
+}
+
+func TestAIWorker_ProcessOne_RecordsAttempts(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+
+	raw := `{"items":[{"name":"拿鐵","unit_price":"abc"}]}`
+	ext := &fakeExtractor{errs: []error{&ExtractError{Err: errors.New("parse receipt json: invalid"), Raw: raw}}}
+	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+
+	newTestWorker(db, ext, store).processOne(context.Background(), txnID, "AI receipt")
+
+	txn := loadTxn(t, db, txnID)
+	assert.Equal(t, "辨識結果格式錯誤", txn.AIError)
+
+	var attempts []models.AIExtractionAttempt
+	require.NoError(t, db.Where("transaction_id = ?", txnID).Find(&attempts).Error)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, aiSourceImage, attempts[0].Source)
+	assert.Equal(t, aiStatusFailed, attempts[0].Status)
+	assert.Equal(t, "parse receipt json: invalid", attempts[0].Error)
+	assert.Equal(t, raw, attempts[0].RawResponse)
+
+	var meta aiRequestMeta
+	require.NoError(t, json.Unmarshal(attempts[0].Request, &meta))
+	assert.Equal(t, "transaction/a.jpg", meta.ImageKey)
+	assert.Equal(t, "image/jpeg", meta.MimeType)
+	assert.Equal(t, 3, meta.ImageBytes)
+}
+
+func TestAIWorker_Reparse_FromStoredOutput(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+	require.NoError(t, db.Model(&models.Transaction{}).Where("id = ?", txnID).
+		Updates(map[string]interface{}{"ai_status": aiStatusFailed, "ai_error": "辨識結果格式錯誤"}).Error)
+
+	src := &models.AIExtractionAttempt{
+		ID:            uuid.New(),
+		TransactionID: txnID,
+		Source:        aiSourceImage,
+		Status:        aiStatusFailed,
+		PromptVersion: "v1/zh-TW",
+		RawResponse:   `{"title":"全聯","items":[{"name":"牛奶","unit_price":55,"quantity":2}]}`,
+	}
+	require.NoError(t, db.Create(src).Error)
+
+	ext := &fakeExtractor{}
+	worker := newTestWorker(db, ext, &fakeStorage{})
+	attempt, err := worker.Reparse(context.Background(), src.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, ext.calls, "re-parse must not call the extractor")
+	require.NotNil(t, attempt.ReparsedFrom)
+	assert.Equal(t, src.ID, *attempt.ReparsedFrom)
+	assert.Equal(t, aiStatusCompleted, attempt.Status)
+
+	txn := loadTxn(t, db, txnID)
+	require.NotNil(t, txn.AIStatus)
+	assert.Equal(t, aiStatusCompleted, *txn.AIStatus)
+	assert.Equal(t, "全聯", txn.Title)
+	assert.Equal(t, "v1/zh-TW", txn.AIPrompt)
+	require.Len(t, txn.Expense.Items, 1)
+	assert.True(t, decimal.NewFromInt(110).Equal(txn.TotalAmount))
+
+	// A row the worker owns is left alone.
+	require.NoError(t, db.Model(&models.Transaction{}).Where("id = ?", txnID).Update("ai_status", aiStatusProcessing).Error)
+	_, err = worker.Reparse(context.Background(), src.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrConflict))
+
+	empty := &models.AIExtractionAttempt{ID: uuid.New(), TransactionID: txnID, Source: aiSourceImage, Status: aiStatusFailed}
+	require.NoError(t, db.Create(empty).Error)
+	_, err = worker.Reparse(context.Background(), empty.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+}
+
+func TestAIWorker_ProcessOne_SkipsExactDuplicate(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	ext := &fakeExtractor{}
+	store := &fakeStorage{data: []byte("img"), contentType: "image/jpeg"}
+
+	originalID := createPendingExpense(t, db, space.ID, "transaction/first.jpg")
+	againID := createPendingExpense(t, db, space.ID, "transaction/again.jpg")
+	require.NoError(t, db.Model(&models.Image{}).Where("entity_id = ?", originalID).
+		Updates(map[string]any{"content_hash": "abc", "created_at": time.Now().Add(-time.Minute)}).Error)
+	require.NoError(t, db.Model(&models.Image{}).Where("entity_id = ?", againID).
+		Update("content_hash", "abc").Error)
+
+	worker := newTestWorker(db, ext, store)
+	worker.processOne(context.Background(), againID, "AI receipt")
+	txn := loadTxn(t, db, againID)
+	require.NotNil(t, txn.AIStatus)
+	assert.Equal(t, aiStatusSkipped, *txn.AIStatus, "a duplicate is not a failure")
+	assert.Contains(t, txn.AIError, originalID)
+	assert.Zero(t, ext.calls)
+
+	// The earlier upload is still extracted.
+	worker.processOne(context.Background(), originalID, "AI receipt")
+	assert.Equal(t, aiStatusCompleted, *loadTxn(t, db, originalID).AIStatus)
+	assert.Equal(t, 1, ext.calls)
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Balance is what one split member is owed (positive) or owes (negative)
+// across a space. Base is in the space's base currency; Foreign holds debts
+// in other currencies that have no settled amount yet, keyed by currency.
+type Balance struct {
+	Name    string                     `json:"name"`
+	Base    decimal.Decimal            `json:"base_amount"`
+	Foreign map[string]decimal.Decimal `json:"foreign_amounts"`
+}
+
+// Settled reports whether nothing is owed either way.
+func (b Balance) Settled() bool {
+	if !b.Base.Round(2).IsZero() {
+		return false
+	}
+	for _, v := range b.Foreign {
+		if !v.Round(2).IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+type debtRow struct {
+	TransactionID string
+	PayerName     string
+	PayeeName     string
+	Amount        decimal.Decimal
+	SettledAmount decimal.Decimal
+	IsSpotPaid    bool
+	Currency      string
+}
+
+// SpaceBalances nets every debt in the space per split member, the same way
+// the stats page does: a debt with a settled amount counts in the base
+// currency, an unconverted one in its own currency, and spot-paid debts not
+// at all. Largest creditor first.
+func SpaceBalances(ctx context.Context, db *gorm.DB, spaceID uuid.UUID) ([]Balance, error) {
+	rows, err := spaceDebts(ctx, db, spaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*Balance{}
+	get := func(name string) *Balance {
+		b, ok := byName[name]
+		if !ok {
+			b = &Balance{Name: name, Foreign: map[string]decimal.Decimal{}}
+			byName[name] = b
+		}
+		return b
+	}
+	for _, r := range rows {
+		get(r.PayerName).addDebt(r, false)
+		get(r.PayeeName).addDebt(r, true)
+	}
+	return sortedBalances(byName), nil
+}
+
+func spaceDebts(ctx context.Context, db *gorm.DB, spaceID uuid.UUID) ([]debtRow, error) {
+	var rows []debtRow
+	err := db.WithContext(ctx).Raw(`
+		SELECT d.transaction_id, d.payer_name, d.payee_name, d.amount, d.settled_amount, d.is_spot_paid, t.currency
+		FROM transaction_debts d
+		JOIN transactions t ON t.id = d.transaction_id
+		WHERE t.space_id = ?`, spaceID,
+	).Scan(&rows).Error
+	return rows, err
+}
+
+// addDebt books one debt on b, as the side that is owed or the side that owes.
+func (b *Balance) addDebt(r debtRow, owed bool) {
+	sign := decimal.NewFromInt(1)
+	if !owed {
+		sign = sign.Neg()
+	}
+	switch {
+	case r.SettledAmount.IsPositive():
+		b.Base = b.Base.Add(r.SettledAmount.Mul(sign))
+	case !r.IsSpotPaid:
+		b.Foreign[r.Currency] = b.Foreign[r.Currency].Add(r.Amount.Mul(sign))
+	}
+}
+
+// sortedBalances drops zeroed foreign currencies and orders the balances
+// largest creditor first.
+func sortedBalances(byName map[string]*Balance) []Balance {
+	balances := make([]Balance, 0, len(byName))
+	for _, b := range byName {
+		for cur, v := range b.Foreign {
+			if v.IsZero() {
+				delete(b.Foreign, cur)
+			}
+		}
+		balances = append(balances, *b)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if c := balances[i].Base.Cmp(balances[j].Base); c != 0 {
+			return c > 0
+		}
+		return balances[i].Name < balances[j].Name
+	})
+	return balances
+}
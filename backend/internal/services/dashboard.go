@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"lovelion/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	dashboardRecentLimit  = 10
+	dashboardPendingLimit = 20
+)
+
+// Dashboard is a user's overview across every space they are a member of.
+// Archived spaces are left out, as in the default space list.
+type Dashboard struct {
+	Currency string `json:"currency"`
+	// MonthTotal sums the spaces' MonthConverted; spaces without a known
+	// rate to Currency aren't in it.
+	MonthTotal decimal.Decimal  `json:"month_total"`
+	Spaces     []DashboardSpace `json:"spaces"`
+	PendingAI  []DashboardTxn   `json:"pending_ai"`
+	Recent     []DashboardTxn   `json:"recent"`
+}
+
+// DashboardSpace is one space's spend this month and where the user stands
+// in it. MonthSpend is in the base currency; foreign expenses without a
+// billing amount are converted with a known rate or, lacking one, left out
+// and listed in Unconverted by currency. MonthConverted is nil when there
+// is no rate from the base currency to the dashboard currency.
+type DashboardSpace struct {
+	ID             uuid.UUID                  `json:"id"`
+	Name           string                     `json:"name"`
+	Type           string                     `json:"type"`
+	BaseCurrency   string                     `json:"base_currency"`
+	MonthSpend     decimal.Decimal            `json:"month_spend"`
+	MonthConverted *decimal.Decimal           `json:"month_converted"`
+	Unconverted    map[string]decimal.Decimal `json:"month_unconverted,omitempty"`
+	MemberName     string                     `json:"member_name"`
+	Net            Balance                    `json:"net"`
+	// Balances are the user's unsettled balances with each other member.
+	Balances []Balance `json:"balances"`
+}
+
+// DashboardTxn is a transaction listed on the dashboard.
+type DashboardTxn struct {
+	ID          string          `json:"id"`
+	SpaceID     uuid.UUID       `json:"space_id"`
+	SpaceName   string          `json:"space_name"`
+	Type        string          `json:"type"`
+	Title       string          `json:"title"`
+	Currency    string          `json:"currency"`
+	TotalAmount decimal.Decimal `json:"total_amount"`
+	AIStatus    *string         `gorm:"column:ai_status" json:"ai_status,omitempty"`
+	CreatedBy   string          `json:"created_by_name"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type ratePair struct{ From, To string }
+
+// UserDashboard builds userID's dashboard with this month's spend converted
+// to currency. There is no rate feed, so conversions use the latest rate
+// implied by an expense the user can see (billing amount over total) for
+// the pair, in either direction.
+func UserDashboard(ctx context.Context, db *gorm.DB, userID uuid.UUID, currency string, now time.Time) (*Dashboard, error) {
+	db = db.WithContext(ctx)
+	dash := &Dashboard{Currency: currency, Spaces: []DashboardSpace{}, PendingAI: []DashboardTxn{}, Recent: []DashboardTxn{}}
+
+	var spaces []struct {
+		models.Space
+		Alias       string
+		DisplayName string
+	}
+	err := db.Model(&models.Space{}).
+		Select("spaces.id, spaces.name, spaces.type, spaces.base_currency, spaces.split_members, space_members.alias, users.display_name").
+		Joins("JOIN space_members ON space_members.space_id = spaces.id AND space_members.user_id = ?", userID).
+		Joins("JOIN users ON users.id = space_members.user_id").
+		Where("spaces.status <> ?", models.SpaceStatusArchived).
+		Order("spaces.name").
+		Scan(&spaces).Error
+	if err != nil || len(spaces) == 0 {
+		return dash, err
+	}
+	spaceIDs := make([]uuid.UUID, len(spaces))
+	memberNames := make(map[uuid.UUID]string, len(spaces))
+	for i, s := range spaces {
+		spaceIDs[i] = s.ID
+		var members []string
+		if len(s.SplitMembers) > 0 {
+			_ = json.Unmarshal(s.SplitMembers, &members)
+		}
+		memberNames[s.ID] = splitNameOf(s.Alias, s.DisplayName, members)
+	}
+	debts, err := memberDebts(ctx, db, spaceIDs, memberNames)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := knownRates(ctx, db, spaceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// A billing amount is already in the base currency. Otherwise the total
+	// is in the transaction's own currency and has to be converted, which
+	// only works when a rate for it is known.
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var spend []struct {
+		SpaceID  uuid.UUID
+		Currency string
+		Billed   decimal.Decimal
+		Unbilled decimal.Decimal
+	}
+	err = db.Raw(`
+		SELECT t.space_id, t.currency,
+		       SUM(CASE WHEN COALESCE(e.billing_amount, 0) > 0 THEN e.billing_amount ELSE 0 END) AS billed,
+		       SUM(CASE WHEN COALESCE(e.billing_amount, 0) > 0 THEN 0 ELSE t.total_amount END) AS unbilled
+		FROM transactions t
+		LEFT JOIN transaction_expenses e ON e.transaction_id = t.id
+		WHERE t.space_id IN ? AND t.type = 'expense' AND t.date >= ? AND t.date < ?
+		GROUP BY t.space_id, t.currency`, spaceIDs, monthStart, monthStart.AddDate(0, 1, 0),
+	).Scan(&spend).Error
+	if err != nil {
+		return nil, err
+	}
+	baseCurrency := make(map[uuid.UUID]string, len(spaces))
+	for _, s := range spaces {
+		baseCurrency[s.ID] = s.BaseCurrency
+	}
+	spendBySpace := map[uuid.UUID]decimal.Decimal{}
+	unconverted := map[uuid.UUID]map[string]decimal.Decimal{}
+	for _, row := range spend {
+		base := baseCurrency[row.SpaceID]
+		amount := row.Billed
+		if converted, ok := convertAmount(rates, row.Unbilled, row.Currency, base); ok {
+			amount = amount.Add(converted)
+		} else {
+			if unconverted[row.SpaceID] == nil {
+				unconverted[row.SpaceID] = map[string]decimal.Decimal{}
+			}
+			unconverted[row.SpaceID][row.Currency] = row.Unbilled
+		}
+		spendBySpace[row.SpaceID] = spendBySpace[row.SpaceID].Add(amount)
+	}
+
+	for _, s := range spaces {
+		ds := DashboardSpace{
+			ID:           s.ID,
+			Name:         s.Name,
+			Type:         s.Type,
+			BaseCurrency: s.BaseCurrency,
+			MonthSpend:   spendBySpace[s.ID].Round(2),
+			Unconverted:  unconverted[s.ID],
+			MemberName:   memberNames[s.ID],
+			Net:          Balance{Foreign: map[string]decimal.Decimal{}},
+			Balances:     []Balance{},
+		}
+		if ds.MemberName != "" {
+			var balances []Balance
+			ds.Net, balances = memberBalances(ds.MemberName, debts[s.ID])
+			for _, b := range balances {
+				if !b.Settled() {
+					ds.Balances = append(ds.Balances, b)
+				}
+			}
+		}
+		if converted, ok := convertAmount(rates, ds.MonthSpend, s.BaseCurrency, currency); ok {
+			converted = converted.Round(2)
+			ds.MonthConverted = &converted
+			dash.MonthTotal = dash.MonthTotal.Add(converted)
+		}
+		dash.Spaces = append(dash.Spaces, ds)
+	}
+
+	const txnSelect = `
+		SELECT t.id, t.space_id, s.name AS space_name, t.type, t.title, t.currency,
+		       t.total_amount, t.ai_status, COALESCE(m.alias, '') AS member_alias,
+		       COALESCE(u.display_name, '') AS created_by, t.created_at
+		FROM transactions t
+		JOIN spaces s ON s.id = t.space_id
+		LEFT JOIN users u ON u.id = t.created_by
+		LEFT JOIN space_members m ON m.space_id = t.space_id AND m.user_id = t.created_by`
+	if dash.PendingAI, err = dashboardTxns(db, txnSelect+`
+		WHERE t.space_id IN ? AND t.ai_status IN ?
+		ORDER BY t.created_at
+		LIMIT ?`, spaceIDs, []string{aiStatusPending, aiStatusProcessing}, dashboardPendingLimit); err != nil {
+		return nil, err
+	}
+	if dash.Recent, err = dashboardTxns(db, txnSelect+`
+		WHERE t.space_id IN ?
+		ORDER BY t.created_at DESC
+		LIMIT ?`, spaceIDs, dashboardRecentLimit); err != nil {
+		return nil, err
+	}
+	return dash, nil
+}
+
+// memberDebts loads, per space, the debts between the user's split member
+// there and anyone else, in one query over all the spaces.
+func memberDebts(ctx context.Context, db *gorm.DB, spaceIDs []uuid.UUID, memberNames map[uuid.UUID]string) (map[uuid.UUID][]debtRow, error) {
+	var names []string
+	for _, name := range memberNames {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	bySpace := map[uuid.UUID][]debtRow{}
+	if len(names) == 0 {
+		return bySpace, nil
+	}
+	var rows []struct {
+		SpaceID       uuid.UUID
+		TransactionID string
+		PayerName     string
+		PayeeName     string
+		Amount        decimal.Decimal
+		SettledAmount decimal.Decimal
+		IsSpotPaid    bool
+		Currency      string
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT t.space_id, d.transaction_id, d.payer_name, d.payee_name, d.amount, d.settled_amount, d.is_spot_paid, t.currency
+		FROM transaction_debts d
+		JOIN transactions t ON t.id = d.transaction_id
+		WHERE t.space_id IN ? AND d.payer_name <> d.payee_name
+		  AND (d.payer_name IN ? OR d.payee_name IN ?)`, spaceIDs, names, names,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		// Names are per space: a match in one space says nothing elsewhere.
+		if name := memberNames[r.SpaceID]; r.PayerName == name || r.PayeeName == name {
+			bySpace[r.SpaceID] = append(bySpace[r.SpaceID], debtRow{
+				TransactionID: r.TransactionID, PayerName: r.PayerName, PayeeName: r.PayeeName,
+				Amount: r.Amount, SettledAmount: r.SettledAmount, IsSpotPaid: r.IsSpotPaid, Currency: r.Currency,
+			})
+		}
+	}
+	return bySpace, nil
+}
+
+// dashboardTxns runs one of the dashboard's transaction queries, naming each
+// creator by their alias in the space when they have one.
+func dashboardTxns(db *gorm.DB, query string, args ...interface{}) ([]DashboardTxn, error) {
+	var rows []struct {
+		DashboardTxn
+		MemberAlias string
+	}
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	txns := make([]DashboardTxn, len(rows))
+	for i, r := range rows {
+		txns[i] = r.DashboardTxn
+		if r.MemberAlias != "" {
+			txns[i].CreatedBy = r.MemberAlias
+		}
+	}
+	return txns, nil
+}
+
+// knownRates returns, per currency pair, the latest rate implied by a
+// foreign-currency expense in the given spaces: one From is Rate To.
+func knownRates(ctx context.Context, db *gorm.DB, spaceIDs []uuid.UUID) (map[ratePair]decimal.Decimal, error) {
+	var rows []struct {
+		FromCurrency string
+		ToCurrency   string
+		Rate         decimal.Decimal
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT t.currency AS from_currency, s.base_currency AS to_currency,
+		       e.billing_amount / t.total_amount AS rate
+		FROM transaction_expenses e
+		JOIN transactions t ON t.id = e.transaction_id
+		JOIN spaces s ON s.id = t.space_id
+		WHERE t.space_id IN ? AND t.currency <> s.base_currency
+		  AND e.billing_amount > 0 AND t.total_amount > 0
+		ORDER BY t.date DESC, t.created_at DESC`, spaceIDs,
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	rates := map[ratePair]decimal.Decimal{}
+	for _, r := range rows {
+		pair := ratePair{r.FromCurrency, r.ToCurrency}
+		if _, ok := rates[pair]; !ok {
+			rates[pair] = r.Rate
+		}
+	}
+	return rates, nil
+}
+
+// convertAmount converts amount between currencies with a direct or inverse
+// known rate.
+func convertAmount(rates map[ratePair]decimal.Decimal, amount decimal.Decimal, from, to string) (decimal.Decimal, bool) {
+	if from == to || amount.IsZero() {
+		return amount, true
+	}
+	if r, ok := rates[ratePair{from, to}]; ok {
+		return amount.Mul(r), true
+	}
+	if r, ok := rates[ratePair{to, from}]; ok && r.IsPositive() {
+		return amount.Div(r), true
+	}
+	return decimal.Zero, false
+}
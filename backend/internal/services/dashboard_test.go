@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestConvertAmount(t *testing.T) {
+	rates := map[ratePair]decimal.Decimal{{"JPY", "TWD"}: decimal.RequireFromString("0.2")}
+
+	got, ok := convertAmount(rates, decimal.NewFromInt(1000), "JPY", "TWD")
+	assert.True(t, ok)
+	assert.Equal(t, "200", got.String())
+
+	got, ok = convertAmount(rates, decimal.NewFromInt(200), "TWD", "JPY")
+	assert.True(t, ok, "inverse of a known rate")
+	assert.Equal(t, "1000", got.String())
+
+	_, ok = convertAmount(rates, decimal.NewFromInt(10), "USD", "TWD")
+	assert.False(t, ok)
+	got, ok = convertAmount(rates, decimal.NewFromInt(10), "USD", "USD")
+	assert.True(t, ok)
+	assert.Equal(t, "10", got.String())
+}
+
+func TestUserDashboard(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	friend := testutil.CreateTestUser(t, db)
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	home := createTestSpace(t, db, user.ID)
+	addMember(t, db, home.ID, user.ID)
+	trip := &models.Space{ID: uuid.New(), Name: "Tokyo", UserID: friend.ID, BaseCurrency: "JPY", SplitMembers: datatypes.JSON(`["Me","Friend"]`)}
+	require.NoError(t, db.Create(trip).Error)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: trip.ID, UserID: user.ID, Alias: "Me"}).Error)
+	archived := &models.Space{ID: uuid.New(), Name: "Old", UserID: user.ID, Status: models.SpaceStatusArchived}
+	require.NoError(t, db.Create(archived).Error)
+	addMember(t, db, archived.ID, user.ID)
+	// Someone else's space stays out.
+	createTestSpace(t, db, friend.ID)
+
+	expense := func(spaceID uuid.UUID, id, currency string, date time.Time, total, billing int64) {
+		require.NoError(t, db.Create(&models.Transaction{ID: id, SpaceID: spaceID, Type: "expense", Title: id, Currency: currency, Date: date, TotalAmount: decimal.NewFromInt(total), CreatedBy: &user.ID}).Error)
+		require.NoError(t, db.Create(&models.TransactionExpense{ID: uuid.New(), TransactionID: id, BillingAmount: decimal.NewFromInt(billing)}).Error)
+	}
+	expense(home.ID, "h1", "TWD", now.AddDate(0, 0, -1), 300, 0)
+	// Last month: not in this month's spend, but sets the JPY rate.
+	expense(home.ID, "h2", "JPY", now.AddDate(0, -1, 0), 1000, 200)
+	expense(trip.ID, "t1", "JPY", now.AddDate(0, 0, -2), 5000, 0)
+	// Foreign expenses without a billing amount: JPY converts with the known
+	// rate, USD has none and is reported apart.
+	expense(home.ID, "h3", "JPY", now.AddDate(0, 0, -3), 500, 0)
+	expense(home.ID, "h4", "USD", now.AddDate(0, 0, -3), 10, 0)
+	require.NoError(t, db.Create(&models.TransactionDebt{ID: uuid.New(), TransactionID: "t1", PayerName: "Friend", PayeeName: "Me", Amount: decimal.NewFromInt(2500), SettledAmount: decimal.NewFromInt(2500)}).Error)
+	pendingID := createPendingExpense(t, db, trip.ID, "")
+
+	dash, err := UserDashboard(context.Background(), db, user.ID, "TWD", now)
+	require.NoError(t, err)
+	require.Len(t, dash.Spaces, 2, "archived and foreign spaces are left out")
+
+	byName := map[string]DashboardSpace{}
+	for _, s := range dash.Spaces {
+		byName[s.Name] = s
+	}
+	assert.True(t, byName[home.Name].MonthSpend.Equal(decimal.NewFromInt(400)))
+	require.Len(t, byName[home.Name].Unconverted, 1)
+	assert.True(t, byName[home.Name].Unconverted["USD"].Equal(decimal.NewFromInt(10)))
+	tokyo := byName["Tokyo"]
+	assert.True(t, tokyo.MonthSpend.Equal(decimal.NewFromInt(5000)))
+	require.NotNil(t, tokyo.MonthConverted)
+	assert.Equal(t, "1000.00", tokyo.MonthConverted.StringFixed(2))
+	assert.Nil(t, tokyo.Unconverted)
+	assert.Equal(t, "1400.00", dash.MonthTotal.StringFixed(2))
+	require.Len(t, tokyo.Balances, 1)
+	assert.Equal(t, "Friend", tokyo.Balances[0].Name)
+	assert.True(t, tokyo.Net.Base.Equal(decimal.NewFromInt(2500)))
+
+	require.Len(t, dash.PendingAI, 1)
+	assert.Equal(t, pendingID, dash.PendingAI[0].ID)
+	assert.Len(t, dash.Recent, 6)
+	for _, r := range dash.Recent {
+		if r.ID == "t1" {
+			assert.Equal(t, "Me", r.CreatedBy, "named by alias in the space")
+		}
+	}
+}
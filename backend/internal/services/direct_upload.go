@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
+	"lovelion/internal/storage"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxImageSize is the largest image accepted through the API.
+	MaxImageSize = 5 * 1024 * 1024 // 5MB
+	// MaxDirectUploadSize is the largest image a client may PUT straight to
+	// storage. Those bytes never pass through a request body, so full-size
+	// phone photos are allowed; the megapixel cap still bounds decoding.
+	MaxDirectUploadSize = 25 * 1024 * 1024 // 25MB
+	// directUploadTTL is how long an upload intent, and its PUT URL, stays valid.
+	directUploadTTL = 30 * time.Minute
+	// directUploadPrefix is where clients PUT objects before they are
+	// finalized; nothing under it is ever referenced by an Image row.
+	directUploadPrefix = "uploads/"
+)
+
+// uploadContentTypes are the Content-Types an upload intent may declare.
+// The declared type only has to match the PUT; the content is sniffed again
+// on finalize.
+var uploadContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+	"image/heic": true,
+	"image/heif": true,
+}
+
+// DirectUploadStorage is the part of storage.Storage direct uploads need.
+type DirectUploadStorage interface {
+	ImageStorage
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (storage.Object, error)
+	PresignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error)
+}
+
+// DirectUploads lets clients upload images straight to storage instead of
+// through the API: CreateIntent hands out a presigned PUT URL for a staging
+// key, and Finalize (or CreateExpense, for photos of a new expense) checks
+// the object and turns it into an Image like any other upload. Staging
+// objects that are never finalized are removed by the image GC.
+type DirectUploads struct {
+	db        *gorm.DB
+	store     DirectUploadStorage
+	access    *ImageAccess
+	imageOpts imaging.Options
+}
+
+func NewDirectUploads(db *gorm.DB, store DirectUploadStorage) *DirectUploads {
+	return &DirectUploads{db: db, store: store, access: NewImageAccess(db)}
+}
+
+// WithImageOptions sets how uploaded originals are downscaled.
+func (u *DirectUploads) WithImageOptions(opts imaging.Options) *DirectUploads {
+	u.imageOpts = opts
+	return u
+}
+
+// UploadIntentInput describes the image a client is about to upload.
+// EntityID may be empty for a transaction that doesn't exist yet; SpaceID
+// then names the space it will be created in, and the upload is attached
+// through CreateExpenseInput.UploadIDs.
+type UploadIntentInput struct {
+	EntityType  string
+	EntityID    string
+	SpaceID     uuid.UUID
+	ContentType string
+	Size        int64
+}
+
+// UploadIntent is what the client needs to perform the upload.
+type UploadIntent struct {
+	ID        uuid.UUID         `json:"id"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CreateIntent records a pending upload and presigns a PUT for it.
+func (u *DirectUploads) CreateIntent(ctx context.Context, userID uuid.UUID, input UploadIntentInput) (*UploadIntent, error) {
+	if input.Size <= 0 {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "size is required")
+	}
+	if input.Size > MaxDirectUploadSize {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Image exceeds 25MB")
+	}
+	if !uploadContentTypes[input.ContentType] {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, MsgUnsupportedImageType)
+	}
+
+	spaceID := input.SpaceID
+	var err error
+	if input.EntityID != "" {
+		spaceID, err = u.access.CheckWrite(ctx, userID, input.EntityType, input.EntityID)
+	} else {
+		if input.EntityType != ImageEntityTransaction {
+			return nil, errorx.Wrap(errorx.ErrBadRequest, "entity_id is required")
+		}
+		err = u.access.CheckNewTransaction(ctx, userID, input.SpaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pending := models.PendingUpload{
+		ID:          uuid.New(),
+		UserID:      userID,
+		SpaceID:     spaceID,
+		EntityType:  input.EntityType,
+		EntityID:    input.EntityID,
+		ContentType: input.ContentType,
+		Size:        input.Size,
+		ExpiresAt:   time.Now().Add(directUploadTTL),
+	}
+	pending.Key = directUploadPrefix + pending.ID.String()
+
+	uploadURL, err := u.store.PresignedUploadURL(ctx, pending.Key, pending.ContentType, pending.Size, directUploadTTL)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to create upload URL")
+	}
+	if err := u.db.WithContext(ctx).Create(&pending).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to create upload")
+	}
+	return &UploadIntent{
+		ID:        pending.ID,
+		UploadURL: uploadURL,
+		Method:    "PUT",
+		Headers:   map[string]string{"Content-Type": pending.ContentType},
+		ExpiresAt: pending.ExpiresAt,
+	}, nil
+}
+
+// Finalize checks an uploaded object, stores it with its variants and
+// attaches it to the intent's entity after that entity's existing images.
+func (u *DirectUploads) Finalize(ctx context.Context, userID, id uuid.UUID) (*models.Image, error) {
+	pending, err := u.find(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if pending.EntityID == "" {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "This upload is attached when the expense is created")
+	}
+	// Membership may have changed since the intent was created.
+	spaceID, err := u.access.CheckWrite(ctx, userID, pending.EntityType, pending.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := u.process(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	image, keys, err := StoreImage(ctx, u.store, pending.EntityType, pending.EntityID, processed)
+	if err != nil {
+		u.deleteKeys(keys)
+		return nil, err
+	}
+	err = u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Deleting the intent first makes a concurrent finalize of the
+		// same upload lose instead of attaching the image twice.
+		if err := consumePendingUploads(tx, []uuid.UUID{pending.ID}); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Image{}).
+			Where("entity_type = ? AND entity_id = ?", pending.EntityType, pending.EntityID).
+			Select("COALESCE(MAX(sort_order) + 1, 0)").
+			Scan(&image.SortOrder).Error; err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to save image record")
+		}
+		if err := tx.Create(image).Error; err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to save image record")
+		}
+		return nil
+	})
+	if err != nil {
+		u.deleteKeys(keys)
+		return nil, err
+	}
+	u.deleteKeys([]string{pending.Key})
+	image.Duplicates = TransactionImageDuplicates(ctx, u.db, spaceID, image, processed)
+	return image, nil
+}
+
+// takeForExpense loads and processes the uploads of a new expense in
+// spaceID, in the order given. The intents stay in place; the caller
+// consumes them in the same DB transaction that creates the expense.
+func (u *DirectUploads) takeForExpense(ctx context.Context, userID, spaceID uuid.UUID, ids []uuid.UUID) ([]*models.PendingUpload, []*imaging.Result, error) {
+	uploads := make([]*models.PendingUpload, 0, len(ids))
+	results := make([]*imaging.Result, 0, len(ids))
+	for _, id := range ids {
+		pending, err := u.find(ctx, userID, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pending.SpaceID != spaceID || pending.EntityType != ImageEntityTransaction || pending.EntityID != "" {
+			return nil, nil, errorx.Wrap(errorx.ErrBadRequest, "Upload does not belong to a new expense in this space")
+		}
+		res, err := u.process(ctx, pending)
+		if err != nil {
+			return nil, nil, err
+		}
+		uploads = append(uploads, pending)
+		results = append(results, res)
+	}
+	return uploads, results, nil
+}
+
+// find loads one of userID's unexpired intents.
+func (u *DirectUploads) find(ctx context.Context, userID, id uuid.UUID) (*models.PendingUpload, error) {
+	var pending models.PendingUpload
+	err := u.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&pending).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errorx.Wrap(errorx.ErrNotFound, "Upload not found")
+	}
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load upload")
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, errorx.Wrap(errorx.ErrExpired, "Upload has expired")
+	}
+	return &pending, nil
+}
+
+// process checks the staged object's size before downloading it, then runs
+// it through ProcessImage, which sniffs the format and fails on anything
+// that doesn't decode. The presigned PUT stays valid after Stat, so the
+// read itself must match the declared size too. The object is read once,
+// straight into a buffer of that size which the decoder then works from.
+func (u *DirectUploads) process(ctx context.Context, pending *models.PendingUpload) (*imaging.Result, error) {
+	obj, err := u.store.Stat(ctx, pending.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Image has not been uploaded")
+	}
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to check upload")
+	}
+	if obj.Size > MaxDirectUploadSize || obj.Size != pending.Size {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Uploaded image does not match the declared size")
+	}
+	rc, err := u.store.Open(ctx, pending.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Image has not been uploaded")
+	}
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to read upload")
+	}
+	defer rc.Close()
+	body := make([]byte, pending.Size)
+	_, err = io.ReadFull(rc, body)
+	if err == nil {
+		// Anything past the declared size means the object was replaced.
+		if n, _ := io.ReadFull(rc, make([]byte, 1)); n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Uploaded image does not match the declared size")
+	}
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to read upload")
+	}
+	return ProcessImage(body, u.imageOpts)
+}
+
+// deleteKeys removes staging objects and the objects of a failed finalize.
+// It uses a background context so cleanup still runs if the request was
+// cancelled; anything left over is collected by the image GC.
+func (u *DirectUploads) deleteKeys(keys []string) {
+	for _, key := range keys {
+		_ = u.store.Delete(context.Background(), key)
+	}
+}
+
+// consumePendingUploads deletes the given intents inside tx, failing if any
+// of them was already used.
+func consumePendingUploads(tx *gorm.DB, ids []uuid.UUID) error {
+	res := tx.Where("id IN ?", ids).Delete(&models.PendingUpload{})
+	if res.Error != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to finalize upload")
+	}
+	if res.RowsAffected != int64(len(ids)) {
+		return errorx.Wrap(errorx.ErrConflict, "Upload was already used")
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"lovelion/internal/models"
+	"lovelion/internal/storage"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestDirectUploads(t *testing.T, db *gorm.DB) (*DirectUploads, *storage.LocalStorage) {
+	t.Helper()
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080", "test-secret")
+	require.NoError(t, err)
+	return NewDirectUploads(db, local), local
+}
+
+// putUpload stands in for the client's PUT to the presigned URL.
+func putUpload(t *testing.T, db *gorm.DB, local *storage.LocalStorage, id uuid.UUID, body []byte) {
+	t.Helper()
+	var pending models.PendingUpload
+	require.NoError(t, db.First(&pending, "id = ?", id).Error)
+	_, err := local.Upload(context.Background(), pending.Key, bytes.NewReader(body), pending.ContentType)
+	require.NoError(t, err)
+}
+
+func addMember(t *testing.T, db *gorm.DB, spaceID, userID uuid.UUID) {
+	t.Helper()
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: spaceID, UserID: userID}).Error)
+}
+
+func TestDirectUploads_FinalizeAttachesImage(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	addMember(t, db, space.ID, owner.ID)
+	txnID := createPendingExpense(t, db, space.ID, "transaction/existing.jpg")
+	uploads, local := newTestDirectUploads(t, db)
+	ctx := context.Background()
+
+	photo := testPhoto(t, 800, 600)
+	intent, err := uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntityTransaction, EntityID: txnID,
+		ContentType: "image/jpeg", Size: int64(len(photo)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "PUT", intent.Method)
+	assert.Equal(t, "image/jpeg", intent.Headers["Content-Type"])
+	assert.Contains(t, intent.UploadURL, storage.FilesRoute+"uploads/"+intent.ID.String())
+
+	_, err = uploads.Finalize(ctx, owner.ID, intent.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest), "nothing uploaded yet")
+
+	putUpload(t, db, local, intent.ID, photo)
+	img, err := uploads.Finalize(ctx, owner.ID, intent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, txnID, img.EntityID)
+	assert.Equal(t, 1, img.SortOrder, "placed after the existing image")
+	assert.NotEmpty(t, img.BlurHash)
+	assert.NotEmpty(t, img.ThumbPath)
+
+	_, err = local.Stat(ctx, "uploads/"+intent.ID.String())
+	assert.ErrorIs(t, err, storage.ErrNotFound, "the staging object is removed")
+	_, err = uploads.Finalize(ctx, owner.ID, intent.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound), "an intent is used once")
+}
+
+func TestDirectUploads_RejectsBadIntentsAndUploads(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	outsider := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	addMember(t, db, space.ID, owner.ID)
+	uploads, local := newTestDirectUploads(t, db)
+	ctx := context.Background()
+	spaceID := space.ID.String()
+
+	_, err := uploads.CreateIntent(ctx, outsider.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: spaceID, ContentType: "image/jpeg", Size: 100,
+	})
+	assert.True(t, errorx.Is(err, errorx.ErrForbidden))
+	_, err = uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: spaceID, ContentType: "image/gif", Size: 100,
+	})
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+	_, err = uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: spaceID, ContentType: "image/jpeg", Size: MaxDirectUploadSize + 1,
+	})
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+	_, err = uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: spaceID, ContentType: "image/jpeg", Size: MaxImageSize + 1,
+	})
+	assert.NoError(t, err, "direct uploads may exceed the API's limit")
+
+	// A body that isn't an image fails finalize even with the right size.
+	garbage := []byte("definitely not a jpeg")
+	intent, err := uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: spaceID, ContentType: "image/jpeg", Size: int64(len(garbage)),
+	})
+	require.NoError(t, err)
+	putUpload(t, db, local, intent.ID, garbage)
+	_, err = uploads.Finalize(ctx, outsider.ID, intent.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound), "intents belong to their creator")
+	_, err = uploads.Finalize(ctx, owner.ID, intent.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	// A body of a different size than declared is rejected before download.
+	photo := testPhoto(t, 64, 64)
+	intent, err = uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: spaceID, ContentType: "image/jpeg", Size: int64(len(photo)) + 1,
+	})
+	require.NoError(t, err)
+	putUpload(t, db, local, intent.ID, photo)
+	_, err = uploads.Finalize(ctx, owner.ID, intent.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	var count int64
+	db.Model(&models.Image{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+// staleStatStorage reports the size the object had when the client first
+// uploaded it, as if it were overwritten between Stat and the download.
+type staleStatStorage struct {
+	*storage.LocalStorage
+	size int64
+}
+
+func (s staleStatStorage) Stat(ctx context.Context, key string) (storage.Object, error) {
+	obj, err := s.LocalStorage.Stat(ctx, key)
+	obj.Size = s.size
+	return obj, err
+}
+
+func TestDirectUploads_RejectsObjectReplacedAfterStat(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	addMember(t, db, space.ID, owner.ID)
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080", "test-secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	photo := testPhoto(t, 64, 64)
+	uploads := NewDirectUploads(db, staleStatStorage{local, int64(len(photo))})
+	intent, err := uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+		EntityType: ImageEntitySpace, EntityID: space.ID.String(), ContentType: "image/jpeg", Size: int64(len(photo)),
+	})
+	require.NoError(t, err)
+	putUpload(t, db, local, intent.ID, append(photo, make([]byte, MaxImageSize)...))
+
+	_, err = uploads.Finalize(ctx, owner.ID, intent.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+}
+
+func TestCreateExpense_AttachesDirectUploads(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	addMember(t, db, space.ID, owner.ID)
+	uploads, local := newTestDirectUploads(t, db)
+	svc := newTestTransactionService(db).WithDirectUploads(uploads)
+	svc.storage = local
+	ctx := context.Background()
+
+	var ids []uuid.UUID
+	for range 2 {
+		photo := testPhoto(t, 320, 240)
+		intent, err := uploads.CreateIntent(ctx, owner.ID, UploadIntentInput{
+			EntityType: ImageEntityTransaction, SpaceID: space.ID,
+			ContentType: "image/jpeg", Size: int64(len(photo)),
+		})
+		require.NoError(t, err)
+		putUpload(t, db, local, intent.ID, photo)
+		ids = append(ids, intent.ID)
+	}
+
+	// Uploads for a new expense can't be finalized on their own.
+	_, err := uploads.Finalize(ctx, owner.ID, ids[0])
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	txn, err := svc.CreateExpense(ctx, space.ID, CreateExpenseInput{
+		Title: "Receipt", AIExtract: true, CreatedBy: owner.ID, UploadIDs: ids,
+	})
+	require.NoError(t, err)
+
+	var images []models.Image
+	require.NoError(t, db.Where("entity_type = ? AND entity_id = ?", ImageEntityTransaction, txn.ID).
+		Order("sort_order").Find(&images).Error)
+	require.Len(t, images, 2)
+	assert.Equal(t, 1, images[1].SortOrder)
+	for _, id := range ids {
+		_, err := local.Stat(ctx, "uploads/"+id.String())
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+
+	_, err = svc.CreateExpense(ctx, space.ID, CreateExpenseInput{
+		Title: "Again", CreatedBy: owner.ID, UploadIDs: ids[:1],
+	})
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound), "uploads are consumed by the first expense")
+}
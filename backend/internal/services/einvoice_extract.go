@@ -0,0 +1,242 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// Taiwan e-invoice (電子發票證明聯) QR code layout. The left code carries a
+// fixed-width header followed by colon-separated item triples; the right code
+// starts with "**" and continues the item list.
+const (
+	einvoiceHeaderLen   = 77 // invoice no + date + random + amounts + tax IDs + verification
+	einvoiceRightPrefix = "**"
+	// einvoiceMaxPixels bounds what the extractor will decode. Stored images
+	// are already downscaled, so only a hostile header gets near it.
+	einvoiceMaxPixels = 50_000_000
+)
+
+// errNoEInvoiceQR is returned when the image holds no decodable e-invoice
+// left QR code. ChainReceiptExtractor treats any error as "try the next one".
+var errNoEInvoiceQR = errors.New("einvoice: no e-invoice qr code found")
+
+// EInvoiceQRExtractor reads the two QR codes printed on a Taiwan electronic
+// invoice. It needs no network call and yields exact amounts, so it is meant
+// to run ahead of the LLM extractor.
+type EInvoiceQRExtractor struct{}
+
+// NewEInvoiceQRExtractor constructs a QR-based extractor.
+func NewEInvoiceQRExtractor() *EInvoiceQRExtractor {
+	return &EInvoiceQRExtractor{}
+}
+
+// Extract decodes every QR code in the image and parses the e-invoice pair.
+// Hints are ignored: the QR codes carry no category or payment information.
+func (e *EInvoiceQRExtractor) Extract(ctx context.Context, img []byte, mimeType string, _ ExtractHints) (*ReceiptData, error) {
+	if len(img) == 0 {
+		return nil, errors.New("empty image")
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("einvoice: decode image: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > einvoiceMaxPixels {
+		return nil, fmt.Errorf("einvoice: image is %dx%d pixels", cfg.Width, cfg.Height)
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("einvoice: decode image: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("einvoice: build bitmap: %w", err)
+	}
+	// Item names may be Big5, which the reader can't detect, so it is told
+	// to map bytes one to one and qrPayload recovers them.
+	results, err := multiqr.NewQRCodeMultiReader().DecodeMultiple(bmp, map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER:    true,
+		gozxing.DecodeHintType_CHARACTER_SET: "ISO-8859-1",
+	})
+	if err != nil || len(results) == 0 {
+		return nil, errNoEInvoiceQR
+	}
+
+	var left, right string
+	for _, r := range results {
+		text := qrPayload(r.GetText())
+		switch {
+		case strings.HasPrefix(text, einvoiceRightPrefix):
+			right = text
+		case isEInvoiceLeftCode(text):
+			left = text
+		}
+	}
+	if left == "" {
+		return nil, errNoEInvoiceQR
+	}
+	return ParseEInvoiceQR(left, right)
+}
+
+// qrPayload turns text read as ISO-8859-1 back into the code's bytes. A code
+// with an ECI header is decoded in its declared charset instead, which shows
+// as runes beyond Latin-1; such text is already right and kept as is.
+func qrPayload(text string) string {
+	raw := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xff {
+			return text
+		}
+		raw = append(raw, byte(r))
+	}
+	return string(raw)
+}
+
+// isEInvoiceLeftCode reports whether text starts with an invoice number and
+// is long enough to hold the fixed header.
+func isEInvoiceLeftCode(text string) bool {
+	return len(text) >= einvoiceHeaderLen && invoiceNumberPattern.MatchString(text[:10])
+}
+
+// ParseEInvoiceQR parses the left (and optional right) QR payloads of a
+// Taiwan e-invoice, as raw bytes, into ReceiptData. Items whose sum doesn't reach the
+// invoice total (e.g. the right code wasn't captured) are topped up with a
+// balancing item so total_amount always matches the invoice.
+func ParseEInvoiceQR(left, right string) (*ReceiptData, error) {
+	if !isEInvoiceLeftCode(left) {
+		return nil, errNoEInvoiceQR
+	}
+
+	date, err := parseROCDate(left[10:17])
+	if err != nil {
+		return nil, fmt.Errorf("einvoice: %w", err)
+	}
+	total, err := strconv.ParseInt(left[29:37], 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("einvoice: parse total: %w", err)
+	}
+
+	out := &ReceiptData{
+		Date:          &date,
+		Currency:      "TWD",
+		InvoiceNumber: left[:10],
+	}
+
+	// Trailer: ":<self-use>:<items in code>:<items total>:<encoding>:<name:qty:price>..."
+	trailer := strings.TrimPrefix(left[einvoiceHeaderLen:], ":")
+	fields := strings.Split(trailer, ":")
+	if len(fields) >= 4 {
+		itemFields := fields[4:]
+		if right != "" {
+			rest := strings.TrimPrefix(strings.TrimPrefix(right, einvoiceRightPrefix), ":")
+			itemFields = append(itemFields, strings.Split(rest, ":")...)
+		}
+		out.Items = parseEInvoiceItems(itemFields, fields[3])
+	}
+
+	totalAmount := decimal.NewFromInt(total)
+	sum := decimal.Zero
+	for _, it := range out.Items {
+		sum = sum.Add(it.UnitPrice.Mul(it.Quantity))
+	}
+	if diff := totalAmount.Sub(sum); diff.IsPositive() {
+		name := "其他品項"
+		if len(out.Items) == 0 {
+			name = "發票消費"
+		}
+		out.Items = append(out.Items, ReceiptItem{Name: name, UnitPrice: diff, Quantity: decimal.NewFromInt(1)})
+	}
+
+	return out, nil
+}
+
+// parseEInvoiceItems reads name:qty:price triples. encoding follows the spec:
+// "0" Big5, "1" UTF-8, "2" Base64 of UTF-8. Names that don't decode to valid
+// UTF-8 are dropped with their item, leaving the balancing item to cover it.
+func parseEInvoiceItems(fields []string, encoding string) []ReceiptItem {
+	var items []ReceiptItem
+	for i := 0; i+2 < len(fields); i += 3 {
+		name := strings.TrimSpace(fields[i])
+		switch encoding {
+		case "0":
+			if raw, err := traditionalchinese.Big5.NewDecoder().String(name); err == nil {
+				name = strings.TrimSpace(raw)
+			}
+		case "2":
+			if raw, err := base64.StdEncoding.DecodeString(name); err == nil {
+				name = strings.TrimSpace(string(raw))
+			}
+		}
+		if !utf8.ValidString(name) {
+			continue
+		}
+		qty, qErr := decimal.NewFromString(strings.TrimSpace(fields[i+1]))
+		price, pErr := decimal.NewFromString(strings.TrimSpace(fields[i+2]))
+		if name == "" || qErr != nil || pErr != nil {
+			continue
+		}
+		if qty.IsZero() {
+			qty = decimal.NewFromInt(1)
+		}
+		items = append(items, ReceiptItem{Name: name, UnitPrice: price, Quantity: qty})
+	}
+	return items
+}
+
+// parseROCDate converts a 7-digit Minguo date (yyyMMdd) to a UTC time.
+func parseROCDate(s string) (time.Time, error) {
+	if len(s) != 7 {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	year, err := strconv.Atoi(s[:3])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	t, err := time.Parse("2006-01-02", fmt.Sprintf("%04d-%s-%s", year+1911, s[3:5], s[5:7]))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+// ChainReceiptExtractor tries each extractor in order and returns the first
+// success. The last extractor's error is returned so retry classification
+// (isRetryableError) still sees the LLM's failure.
+type ChainReceiptExtractor []ReceiptExtractor
+
+// Extract implements ReceiptExtractor.
+func (c ChainReceiptExtractor) Extract(ctx context.Context, img []byte, mimeType string, hints ExtractHints) (*ReceiptData, error) {
+	if len(c) == 0 {
+		return nil, errors.New("no receipt extractor configured")
+	}
+	var lastErr error
+	for _, ext := range c {
+		data, err := ext.Extract(ctx, img, mimeType, hints)
+		if err == nil {
+			return data, nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
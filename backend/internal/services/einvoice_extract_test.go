@@ -0,0 +1,217 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// einvoiceLeft builds a left QR payload: invoice AB12345678 dated 113/07/14
+// with the given total (hex-encoded in the header) and trailer.
+func einvoiceLeft(total string, trailer string) string {
+	header := "AB12345678" + // invoice number
+		"1130714" + // ROC date
+		"1234" + // random code
+		"00000000" + // sales amount (hex)
+		total + // total amount (hex)
+		"00000000" + // buyer tax ID
+		"12345678" + // seller tax ID
+		strings.Repeat("X", 24) // verification
+	return header + trailer
+}
+
+func TestParseEInvoiceQR_LeftAndRight(t *testing.T) {
+	// total 0x104 = 260 → 牛奶 2×55 + 麵包 1×150
+	left := einvoiceLeft("00000104", ":**********:1:2:1:牛奶:2:55")
+	right := "**:麵包:1:150"
+
+	data, err := ParseEInvoiceQR(left, right)
+	require.NoError(t, err)
+
+	require.NotNil(t, data.Date)
+	assert.Equal(t, "2024-07-14", data.Date.Format("2006-01-02"))
+	assert.Equal(t, "AB12345678", data.InvoiceNumber)
+	assert.Equal(t, "TWD", data.Currency)
+
+	require.Len(t, data.Items, 2)
+	assert.Equal(t, "牛奶", data.Items[0].Name)
+	assert.True(t, decimal.NewFromInt(2).Equal(data.Items[0].Quantity))
+	assert.True(t, decimal.NewFromInt(55).Equal(data.Items[0].UnitPrice))
+	assert.Equal(t, "麵包", data.Items[1].Name)
+}
+
+func TestParseEInvoiceQR_MissingRightCodeAddsBalancingItem(t *testing.T) {
+	left := einvoiceLeft("00000104", ":**********:1:2:1:牛奶:2:55")
+
+	data, err := ParseEInvoiceQR(left, "")
+	require.NoError(t, err)
+	require.Len(t, data.Items, 2)
+	assert.Equal(t, "其他品項", data.Items[1].Name)
+	assert.True(t, decimal.NewFromInt(150).Equal(data.Items[1].UnitPrice))
+}
+
+func TestParseEInvoiceQR_NoItems(t *testing.T) {
+	data, err := ParseEInvoiceQR(einvoiceLeft("00000064", ""), "")
+	require.NoError(t, err)
+	require.Len(t, data.Items, 1)
+	assert.Equal(t, "發票消費", data.Items[0].Name)
+	assert.True(t, decimal.NewFromInt(100).Equal(data.Items[0].UnitPrice))
+}
+
+func TestParseEInvoiceQR_Base64Names(t *testing.T) {
+	name := base64.StdEncoding.EncodeToString([]byte("咖啡"))
+	left := einvoiceLeft("00000037", ":**********:1:1:2:"+name+":1:55")
+
+	data, err := ParseEInvoiceQR(left, "")
+	require.NoError(t, err)
+	require.Len(t, data.Items, 1)
+	assert.Equal(t, "咖啡", data.Items[0].Name)
+}
+
+func big5(t *testing.T, s string) string {
+	t.Helper()
+	out, err := traditionalchinese.Big5.NewEncoder().String(s)
+	require.NoError(t, err)
+	return out
+}
+
+func TestParseEInvoiceQR_Big5Names(t *testing.T) {
+	left := einvoiceLeft("00000037", ":**********:1:1:0:"+big5(t, "牛奶")+":1:55")
+
+	data, err := ParseEInvoiceQR(left, "")
+	require.NoError(t, err)
+	require.Len(t, data.Items, 1)
+	assert.Equal(t, "牛奶", data.Items[0].Name)
+}
+
+func TestParseEInvoiceQR_Invalid(t *testing.T) {
+	_, err := ParseEInvoiceQR("not an invoice", "")
+	assert.ErrorIs(t, err, errNoEInvoiceQR)
+
+	_, err = ParseEInvoiceQR(strings.Replace(einvoiceLeft("00000064", ""), "1130714", "1131399", 1), "")
+	assert.Error(t, err)
+}
+
+// renderQRPair draws two QR codes side by side on a white canvas, the way
+// they are printed on an e-invoice. With charset set the codes declare it in
+// an ECI header; without, they hold left and right byte for byte, as
+// printers do.
+func renderQRPair(t *testing.T, left, right, charset string) []byte {
+	t.Helper()
+	writer := qrcode.NewQRCodeWriter()
+	var hints map[gozxing.EncodeHintType]interface{}
+	if charset != "" {
+		hints = map[gozxing.EncodeHintType]interface{}{gozxing.EncodeHintType_CHARACTER_SET: charset}
+	} else {
+		// The writer leaves the ECI out only for its default charset, which
+		// is UTF-8 here; make it ISO-8859-1 so each rune of latin1 is a byte.
+		defer func(enc encoding.Encoding) { encoder.Encoder_DEFAULT_BYTE_MODE_ENCODING = enc }(encoder.Encoder_DEFAULT_BYTE_MODE_ENCODING)
+		encoder.Encoder_DEFAULT_BYTE_MODE_ENCODING = charmap.ISO8859_1
+		left, right = latin1(left), latin1(right)
+	}
+
+	canvas := image.NewGray(image.Rect(0, 0, 720, 360))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	for i, text := range []string{left, right} {
+		m, err := writer.Encode(text, gozxing.BarcodeFormat_QR_CODE, 300, 300, hints)
+		require.NoError(t, err)
+		offset := 20 + i*380
+		for y := 0; y < m.GetHeight(); y++ {
+			for x := 0; x < m.GetWidth(); x++ {
+				if m.Get(x, y) {
+					canvas.SetGray(offset+x, 30+y, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, canvas))
+	return buf.Bytes()
+}
+
+func TestEInvoiceQRExtractor_DecodesImage(t *testing.T) {
+	left := einvoiceLeft("00000104", ":**********:1:2:1:牛奶:2:55")
+	img := renderQRPair(t, left, "**:麵包:1:150", "UTF-8")
+
+	data, err := NewEInvoiceQRExtractor().Extract(context.Background(), img, "image/png", ExtractHints{})
+	require.NoError(t, err)
+	assert.Equal(t, "AB12345678", data.InvoiceNumber)
+	require.Len(t, data.Items, 2)
+	assert.Equal(t, "麵包", data.Items[1].Name)
+}
+
+func TestEInvoiceQRExtractor_DecodesBig5Image(t *testing.T) {
+	left := einvoiceLeft("00000104", ":**********:1:2:0:"+big5(t, "牛奶")+":2:55")
+	img := renderQRPair(t, left, "**:"+big5(t, "麵包")+":1:150", "")
+
+	data, err := NewEInvoiceQRExtractor().Extract(context.Background(), img, "image/png", ExtractHints{})
+	require.NoError(t, err)
+	require.Len(t, data.Items, 2)
+	assert.Equal(t, "牛奶", data.Items[0].Name)
+	assert.Equal(t, "麵包", data.Items[1].Name)
+}
+
+func latin1(raw string) string {
+	runes := make([]rune, len(raw))
+	for i := 0; i < len(raw); i++ {
+		runes[i] = rune(raw[i])
+	}
+	return string(runes)
+}
+
+func TestEInvoiceQRExtractor_NoQRCode(t *testing.T) {
+	canvas := image.NewGray(image.Rect(0, 0, 64, 64))
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, canvas))
+
+	_, err := NewEInvoiceQRExtractor().Extract(context.Background(), buf.Bytes(), "image/png", ExtractHints{})
+	assert.ErrorIs(t, err, errNoEInvoiceQR)
+}
+
+func TestChainReceiptExtractor_FallsBackInOrder(t *testing.T) {
+	first := &fakeExtractor{errs: []error{errNoEInvoiceQR}}
+	second := &fakeExtractor{results: []*ReceiptData{{Title: "LLM"}}}
+
+	data, err := ChainReceiptExtractor{first, second}.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{})
+	require.NoError(t, err)
+	assert.Equal(t, "LLM", data.Title)
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 1, second.calls)
+}
+
+func TestChainReceiptExtractor_StopsAtFirstSuccess(t *testing.T) {
+	first := &fakeExtractor{results: []*ReceiptData{{Title: "QR"}}}
+	second := &fakeExtractor{}
+
+	data, err := ChainReceiptExtractor{first, second}.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{})
+	require.NoError(t, err)
+	assert.Equal(t, "QR", data.Title)
+	assert.Equal(t, 0, second.calls)
+}
+
+func TestChainReceiptExtractor_ReturnsLastError(t *testing.T) {
+	first := &fakeExtractor{errs: []error{errNoEInvoiceQR}}
+	second := &fakeExtractor{errs: []error{errors.New("gemini http 503: overloaded")}}
+
+	_, err := ChainReceiptExtractor{first, second}.Extract(context.Background(), []byte("img"), "image/jpeg", ExtractHints{})
+	require.Error(t, err)
+	assert.True(t, isRetryableError(err))
+}
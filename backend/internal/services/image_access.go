@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"lovelion/internal/models"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Entity types an image can be attached to.
+const (
+	ImageEntitySpace       = "space"
+	ImageEntityTransaction = "transaction"
+	ImageEntityStore       = "store"
+	ImageEntityProduct     = "product"
+)
+
+// ImageAccess decides who may see an image by resolving the entity it is
+// attached to back to its space and checking space membership.
+type ImageAccess struct {
+	db *gorm.DB
+}
+
+func NewImageAccess(db *gorm.DB) *ImageAccess {
+	return &ImageAccess{db: db}
+}
+
+// SpaceOf returns the space that owns an image entity.
+func (a *ImageAccess) SpaceOf(ctx context.Context, entityType, entityID string) (uuid.UUID, error) {
+	db := a.db.WithContext(ctx)
+	var spaceID uuid.UUID
+	var err error
+	switch entityType {
+	case ImageEntitySpace:
+		var id uuid.UUID
+		if id, err = uuid.Parse(entityID); err != nil {
+			return uuid.Nil, errorx.Wrap(errorx.ErrNotFound, "Space not found")
+		}
+		err = db.Model(&models.Space{}).Select("id").Where("id = ?", id).Limit(1).Scan(&spaceID).Error
+	case ImageEntityTransaction:
+		err = db.Model(&models.Transaction{}).Select("space_id").Where("id = ?", entityID).Limit(1).Scan(&spaceID).Error
+	case ImageEntityStore:
+		err = db.Model(&models.ComparisonStore{}).Select("space_id").Where("id = ?", entityID).Limit(1).Scan(&spaceID).Error
+	case ImageEntityProduct:
+		if _, err = uuid.Parse(entityID); err != nil {
+			return uuid.Nil, errorx.Wrap(errorx.ErrNotFound, "Product not found")
+		}
+		err = db.Model(&models.ComparisonProduct{}).
+			Select("comparison_stores.space_id").
+			Joins("JOIN comparison_stores ON comparison_stores.id = comparison_products.store_id").
+			Where("comparison_products.id = ?", entityID).
+			Limit(1).Scan(&spaceID).Error
+	default:
+		return uuid.Nil, errorx.Wrap(errorx.ErrBadRequest, "Unsupported entity_type")
+	}
+	if err != nil {
+		return uuid.Nil, errorx.Wrap(errorx.ErrInternal, "Failed to resolve image owner")
+	}
+	if spaceID == uuid.Nil {
+		return uuid.Nil, errorx.Wrap(errorx.ErrNotFound, "Entity not found")
+	}
+	return spaceID, nil
+}
+
+// Check returns ErrForbidden unless userID is a member of the space that owns
+// the entity. It returns the space ID so callers can reuse it.
+func (a *ImageAccess) Check(ctx context.Context, userID uuid.UUID, entityType, entityID string) (uuid.UUID, error) {
+	spaceID, err := a.SpaceOf(ctx, entityType, entityID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if _, err := a.member(ctx, spaceID, userID); err != nil {
+		return uuid.Nil, err
+	}
+	return spaceID, nil
+}
+
+// CheckWrite is Check for adding, reordering or deleting an entity's images.
+// Contributors may only change the images of transactions they created;
+// anything else needs an editor, and nothing changes unless the space is
+// active (see models.Space.Writable): closed and archived spaces are read-only.
+func (a *ImageAccess) CheckWrite(ctx context.Context, userID uuid.UUID, entityType, entityID string) (uuid.UUID, error) {
+	spaceID, err := a.SpaceOf(ctx, entityType, entityID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	member, err := a.member(ctx, spaceID, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := a.writable(ctx, spaceID); err != nil {
+		return uuid.Nil, err
+	}
+	if member.HasRole(models.SpaceRoleEditor) {
+		return spaceID, nil
+	}
+	if entityType == ImageEntityTransaction {
+		var createdBy *uuid.UUID
+		if err := a.db.WithContext(ctx).Model(&models.Transaction{}).Select("created_by").
+			Where("id = ?", entityID).Limit(1).Scan(&createdBy).Error; err != nil {
+			return uuid.Nil, errorx.Wrap(errorx.ErrInternal, "Failed to resolve image owner")
+		}
+		if member.CanEditTransaction(createdBy) {
+			return spaceID, nil
+		}
+	}
+	return uuid.Nil, errorx.Wrap(errorx.ErrForbidden, "Your role in this space does not allow changing these images")
+}
+
+// CheckNewTransaction checks that userID may add photos to a transaction
+// they are about to create in spaceID.
+func (a *ImageAccess) CheckNewTransaction(ctx context.Context, userID, spaceID uuid.UUID) error {
+	member, err := a.member(ctx, spaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !member.HasRole(models.SpaceRoleContributor) {
+		return errorx.Wrap(errorx.ErrForbidden, "Your role in this space does not allow adding transactions")
+	}
+	return a.writable(ctx, spaceID)
+}
+
+// writable fails for closed and archived spaces.
+func (a *ImageAccess) writable(ctx context.Context, spaceID uuid.UUID) error {
+	var space models.Space
+	if err := a.db.WithContext(ctx).Select("id", "status").First(&space, "id = ?", spaceID).Error; err != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to load space")
+	}
+	if !space.Writable() {
+		return errorx.Wrap(errorx.ErrForbidden, "This space is "+space.Status+" and can no longer be changed")
+	}
+	return nil
+}
+
+func (a *ImageAccess) member(ctx context.Context, spaceID, userID uuid.UUID) (*models.SpaceMember, error) {
+	var member models.SpaceMember
+	err := a.db.WithContext(ctx).
+		Where("space_id = ? AND user_id = ?", spaceID, userID).
+		First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errorx.Wrap(errorx.ErrForbidden, "You do not have access to this space")
+	}
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to check space membership")
+	}
+	return &member, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageAccess_ResolvesEveryEntityType(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	outsider := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: "owner"}).Error)
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+
+	store := &models.ComparisonStore{ID: "store-" + uuid.NewString()[:8], SpaceID: space.ID, Name: "Shop"}
+	require.NoError(t, db.Create(store).Error)
+	product := &models.ComparisonProduct{ID: uuid.New(), StoreID: store.ID, Name: "Milk", Price: decimal.NewFromInt(50)}
+	require.NoError(t, db.Create(product).Error)
+
+	access := NewImageAccess(db)
+	ctx := context.Background()
+	for entityType, entityID := range map[string]string{
+		ImageEntitySpace:       space.ID.String(),
+		ImageEntityTransaction: txnID,
+		ImageEntityStore:       store.ID,
+		ImageEntityProduct:     product.ID.String(),
+	} {
+		spaceID, err := access.Check(ctx, owner.ID, entityType, entityID)
+		require.NoError(t, err, entityType)
+		assert.Equal(t, space.ID, spaceID, entityType)
+
+		_, err = access.Check(ctx, outsider.ID, entityType, entityID)
+		assert.True(t, errorx.Is(err, errorx.ErrForbidden), entityType)
+	}
+
+	_, err := access.SpaceOf(ctx, ImageEntityProduct, "not-a-uuid")
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound))
+	_, err = access.SpaceOf(ctx, ImageEntityTransaction, "missing")
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound))
+	_, err = access.SpaceOf(ctx, "trip", "x")
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+}
+
+func TestImageAccess_CheckWriteFollowsRoles(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	contributor := testutil.CreateTestUser(t, db)
+	viewer := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: contributor.ID, Role: models.SpaceRoleContributor}).Error)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: viewer.ID, Role: models.SpaceRoleViewer}).Error)
+	mine := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+	require.NoError(t, db.Model(&models.Transaction{}).Where("id = ?", mine).Update("created_by", contributor.ID).Error)
+	theirs := createPendingExpense(t, db, space.ID, "transaction/b.jpg")
+
+	access := NewImageAccess(db)
+	ctx := context.Background()
+
+	_, err := access.CheckWrite(ctx, contributor.ID, ImageEntityTransaction, mine)
+	assert.NoError(t, err)
+	_, err = access.CheckWrite(ctx, contributor.ID, ImageEntityTransaction, theirs)
+	assert.True(t, errorx.Is(err, errorx.ErrForbidden))
+	_, err = access.CheckWrite(ctx, contributor.ID, ImageEntitySpace, space.ID.String())
+	assert.True(t, errorx.Is(err, errorx.ErrForbidden), "space covers need an editor")
+	assert.NoError(t, access.CheckNewTransaction(ctx, contributor.ID, space.ID))
+
+	_, err = access.Check(ctx, viewer.ID, ImageEntityTransaction, mine)
+	assert.NoError(t, err, "viewers still see images")
+	_, err = access.CheckWrite(ctx, viewer.ID, ImageEntityTransaction, mine)
+	assert.True(t, errorx.Is(err, errorx.ErrForbidden))
+	assert.True(t, errorx.Is(access.CheckNewTransaction(ctx, viewer.ID, space.ID), errorx.ErrForbidden))
+}
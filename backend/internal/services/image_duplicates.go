@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// duplicateMaxDistance is the largest perceptual hash distance still
+// reported as the same picture. dHash rarely moves more than a few bits for
+// a recompressed or resized copy, while unrelated photos differ in ~32.
+const duplicateMaxDistance = 6
+
+type duplicateCandidate struct {
+	ImageID        uuid.UUID
+	ContentHash    string
+	PerceptualHash string
+	TransactionID  string
+	Title          string
+	Date           time.Time
+}
+
+// duplicateScanLimit bounds the perceptual comparison to the space's most
+// recent transaction images; a near copy of an old photo is rarely a double
+// entry. Exact matches use the content hash index and are always found.
+var duplicateScanLimit = 500
+
+// FindDuplicateImages returns the transactions in spaceID, other than
+// excludeTxnID, that already carry one of the uploaded pictures: one entry
+// per transaction, exact matches first, then newest first.
+func FindDuplicateImages(ctx context.Context, db *gorm.DB, spaceID uuid.UUID, excludeTxnID string, uploads []*imaging.Result) ([]models.DuplicateImage, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+	var hashes []string
+	for _, u := range uploads {
+		if u.ContentHash != "" {
+			hashes = append(hashes, u.ContentHash)
+		}
+	}
+	const candidateQuery = `
+		SELECT i.id AS image_id,
+		       COALESCE(i.content_hash, '') AS content_hash,
+		       COALESCE(i.perceptual_hash, '') AS perceptual_hash,
+		       t.id AS transaction_id, t.title, t.date
+		FROM images i
+		JOIN transactions t ON t.id = i.entity_id
+		WHERE i.entity_type = ? AND t.space_id = ? AND t.id <> ?`
+	var candidates []duplicateCandidate
+	if len(hashes) > 0 {
+		err := db.WithContext(ctx).Raw(candidateQuery+` AND i.content_hash IN ?`,
+			ImageEntityTransaction, spaceID, excludeTxnID, hashes,
+		).Scan(&candidates).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	var recent []duplicateCandidate
+	err := db.WithContext(ctx).Raw(candidateQuery+` AND i.perceptual_hash <> ''
+		ORDER BY t.date DESC, t.created_at DESC LIMIT ?`,
+		ImageEntityTransaction, spaceID, excludeTxnID, duplicateScanLimit,
+	).Scan(&recent).Error
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, recent...)
+
+	byTxn := map[string]*models.DuplicateImage{}
+	for _, c := range candidates {
+		exact, near := false, false
+		for _, u := range uploads {
+			if c.ContentHash != "" && c.ContentHash == u.ContentHash {
+				exact = true
+				break
+			}
+			if d := imaging.HashDistance(c.PerceptualHash, u.PerceptualHash); d >= 0 && d <= duplicateMaxDistance {
+				near = true
+			}
+		}
+		if !exact && !near {
+			continue
+		}
+		if prev, ok := byTxn[c.TransactionID]; ok && (prev.Exact || !exact) {
+			continue
+		}
+		byTxn[c.TransactionID] = &models.DuplicateImage{
+			ImageID:       c.ImageID,
+			TransactionID: c.TransactionID,
+			Title:         c.Title,
+			Date:          c.Date,
+			Exact:         exact,
+		}
+	}
+
+	dups := make([]models.DuplicateImage, 0, len(byTxn))
+	for _, d := range byTxn {
+		dups = append(dups, *d)
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].Exact != dups[j].Exact {
+			return dups[i].Exact
+		}
+		return dups[i].Date.After(dups[j].Date)
+	})
+	return dups, nil
+}
+
+// TransactionImageDuplicates is FindDuplicateImages for one image just added
+// to an existing entity. Only transaction images are checked, and a failed
+// lookup is logged rather than failing the upload.
+func TransactionImageDuplicates(ctx context.Context, db *gorm.DB, spaceID uuid.UUID, image *models.Image, res *imaging.Result) []models.DuplicateImage {
+	if image.EntityType != ImageEntityTransaction {
+		return nil
+	}
+	dups, err := FindDuplicateImages(ctx, db, spaceID, image.EntityID, []*imaging.Result{res})
+	if err != nil {
+		slog.Warn("duplicate image lookup failed", "txn_id", image.EntityID, "error", err)
+	}
+	return dups
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicateImages(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	other := createTestSpace(t, db, user.ID)
+
+	exactID := createPendingExpense(t, db, space.ID, "transaction/exact.jpg")
+	nearID := createPendingExpense(t, db, space.ID, "transaction/near.jpg")
+	farID := createPendingExpense(t, db, space.ID, "transaction/far.jpg")
+	elsewhereID := createPendingExpense(t, db, other.ID, "transaction/elsewhere.jpg")
+	hashes := map[string][2]string{
+		exactID:     {"sha-1", "ffffffffffffffff"},
+		nearID:      {"sha-2", "00000000000000f0"}, // 4 bits off
+		farID:       {"sha-3", "ffffffff00000000"},
+		elsewhereID: {"sha-1", "0000000000000000"},
+	}
+	for id, h := range hashes {
+		require.NoError(t, db.Model(&models.Image{}).Where("entity_id = ?", id).
+			Updates(map[string]any{"content_hash": h[0], "perceptual_hash": h[1]}).Error)
+	}
+
+	dups, err := FindDuplicateImages(context.Background(), db, space.ID, "new", []*imaging.Result{
+		{ContentHash: "sha-1", PerceptualHash: "0000000000000000"},
+	})
+	require.NoError(t, err)
+	require.Len(t, dups, 2)
+	assert.Equal(t, exactID, dups[0].TransactionID)
+	assert.True(t, dups[0].Exact)
+	assert.Equal(t, nearID, dups[1].TransactionID)
+	assert.False(t, dups[1].Exact)
+
+	// Outside the perceptual scan only exact copies are found.
+	defer func(limit int) { duplicateScanLimit = limit }(duplicateScanLimit)
+	duplicateScanLimit = 0
+	dups, err = FindDuplicateImages(context.Background(), db, space.ID, "new", []*imaging.Result{
+		{ContentHash: "sha-1", PerceptualHash: "0000000000000000"},
+	})
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+	assert.Equal(t, exactID, dups[0].TransactionID)
+
+	dups, err = FindDuplicateImages(context.Background(), db, space.ID, exactID, []*imaging.Result{
+		{ContentHash: "sha-1", PerceptualHash: "ffffffffffffffff"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, dups, "the transaction's own image is not a duplicate")
+}
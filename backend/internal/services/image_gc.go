@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/storage"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImageGCStorage is the part of storage.Storage the image GC needs.
+type ImageGCStorage interface {
+	List(ctx context.Context, fn func(storage.Object) error) error
+	Delete(ctx context.Context, key string) error
+}
+
+// gcPrefixes are the key prefixes the app writes to. The collector never
+// looks outside them, so a bucket shared with anything else is safe.
+var gcPrefixes = []string{
+	ImageEntityTransaction + "/",
+	ImageEntitySpace + "/",
+	ImageEntityStore + "/",
+	ImageEntityProduct + "/",
+	directUploadPrefix,
+}
+
+func gcOwnsKey(key string) bool {
+	for _, prefix := range gcPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageGCConfig controls the collector. Zero values get sensible defaults.
+type ImageGCConfig struct {
+	Interval time.Duration // default 24h; only used by Run
+	Grace    time.Duration // default 72h; how long something must stay orphaned
+	DryRun   bool          // report only, never delete
+}
+
+// ImageGC reconciles the images table with the bucket. Images are attached
+// polymorphically (entity_type + entity_id), so deleting a transaction or a
+// space leaves its Image rows behind, and a failed request can leave objects
+// no row points at. Each pass records when every orphan was first seen in
+// orphan_candidates and deletes those that stayed orphaned for the grace
+// period; anything referenced again in the meantime is forgotten.
+type ImageGC struct {
+	db    *gorm.DB
+	store ImageGCStorage
+	cfg   ImageGCConfig
+}
+
+func NewImageGC(db *gorm.DB, store ImageGCStorage, cfg ImageGCConfig) *ImageGC {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 24 * time.Hour
+	}
+	if cfg.Grace <= 0 {
+		cfg.Grace = 72 * time.Hour
+	}
+	return &ImageGC{db: db, store: store, cfg: cfg}
+}
+
+// WithGrace overrides the grace period, allowing zero (delete on first
+// sight), which the constructor treats as "use the default".
+func (g *ImageGC) WithGrace(grace time.Duration) *ImageGC {
+	g.cfg.Grace = grace
+	return g
+}
+
+// Orphan is one entry of a GCReport.
+type Orphan struct {
+	Kind      string    `json:"kind"`   // models.OrphanKindImage or models.OrphanKindObject
+	Ref       string    `json:"ref"`    // image ID or storage key
+	Detail    string    `json:"detail"` // "<entity_type>/<entity_id>" for images
+	FirstSeen time.Time `json:"first_seen"`
+	Due       bool      `json:"due"` // orphaned for longer than the grace period
+	Deleted   bool      `json:"deleted"`
+}
+
+// GCReport is the result of one pass.
+type GCReport struct {
+	DryRun  bool     `json:"dry_run"`
+	Grace   string   `json:"grace"`
+	Objects int      `json:"objects"` // objects listed under gcPrefixes
+	Orphans []Orphan `json:"orphans"`
+	Deleted int      `json:"deleted"`
+	Failed  int      `json:"failed"`
+	// ExpiredUploads counts upload intents dropped because they expired
+	// without being finalized. Their staging objects become orphans.
+	ExpiredUploads int `json:"expired_uploads"`
+}
+
+// Run collects on cfg.Interval until ctx is cancelled, starting with one
+// pass right away.
+func (g *ImageGC) Run(ctx context.Context) {
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+
+	slog.Info("image gc started", "interval", g.cfg.Interval, "grace", g.cfg.Grace, "dry_run", g.cfg.DryRun)
+
+	g.runOnce(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("image gc shutting down")
+			return
+		case <-ticker.C:
+			g.runOnce(ctx)
+		}
+	}
+}
+
+func (g *ImageGC) runOnce(ctx context.Context) {
+	report, err := g.Collect(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("image gc failed", "error", err)
+		}
+		return
+	}
+	due := 0
+	for _, o := range report.Orphans {
+		if o.Due {
+			due++
+		}
+	}
+	slog.Info("image gc finished",
+		"orphans", len(report.Orphans), "due", due,
+		"deleted", report.Deleted, "failed", report.Failed, "dry_run", report.DryRun)
+}
+
+// Collect runs one reconciliation pass. In dry-run mode it still records
+// first sightings, so the grace period counts from the first run whether or
+// not deletion was enabled then, but it never deletes anything.
+func (g *ImageGC) Collect(ctx context.Context) (*GCReport, error) {
+	now := time.Now()
+	report := &GCReport{DryRun: g.cfg.DryRun, Grace: g.cfg.Grace.String()}
+
+	if !g.cfg.DryRun {
+		res := g.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.PendingUpload{})
+		if res.Error != nil {
+			return nil, fmt.Errorf("drop expired uploads: %w", res.Error)
+		}
+		report.ExpiredUploads = int(res.RowsAffected)
+	}
+
+	orphanImages, err := g.orphanImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("find orphaned images: %w", err)
+	}
+	orphanKeys, listed, err := g.orphanObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Objects = listed
+
+	current := make([]models.OrphanCandidate, 0, len(orphanImages)+len(orphanKeys))
+	details := map[string]string{}
+	for _, img := range orphanImages {
+		current = append(current, models.OrphanCandidate{Kind: models.OrphanKindImage, Ref: img.ID.String()})
+		details[img.ID.String()] = img.EntityType + "/" + img.EntityID
+	}
+	for _, key := range orphanKeys {
+		current = append(current, models.OrphanCandidate{Kind: models.OrphanKindObject, Ref: key})
+	}
+
+	firstSeen, err := g.reconcileCandidates(ctx, current, now)
+	if err != nil {
+		return nil, fmt.Errorf("record orphan candidates: %w", err)
+	}
+
+	imagesByID := make(map[string]models.Image, len(orphanImages))
+	for _, img := range orphanImages {
+		imagesByID[img.ID.String()] = img
+	}
+	for _, c := range current {
+		seen := firstSeen[c.Kind+"\x00"+c.Ref]
+		o := Orphan{
+			Kind:      c.Kind,
+			Ref:       c.Ref,
+			Detail:    details[c.Ref],
+			FirstSeen: seen,
+			Due:       !now.Before(seen.Add(g.cfg.Grace)),
+		}
+		if o.Due && !g.cfg.DryRun {
+			var err error
+			if c.Kind == models.OrphanKindImage {
+				err = g.deleteImage(ctx, imagesByID[c.Ref])
+			} else {
+				err = g.deleteObject(ctx, c.Ref)
+			}
+			if err != nil {
+				slog.Warn("image gc delete failed", "kind", c.Kind, "ref", c.Ref, "error", err)
+				report.Failed++
+			} else {
+				o.Deleted = true
+				report.Deleted++
+			}
+		}
+		report.Orphans = append(report.Orphans, o)
+	}
+	return report, nil
+}
+
+// orphanImages returns Image rows whose entity no longer exists. Rows with
+// an entity type this code doesn't know are left alone.
+func (g *ImageGC) orphanImages(ctx context.Context) ([]models.Image, error) {
+	var images []models.Image
+	err := g.db.WithContext(ctx).Raw(`
+		SELECT i.* FROM images i
+		WHERE (i.entity_type = ? AND NOT EXISTS (SELECT 1 FROM spaces s WHERE s.id::text = i.entity_id))
+		   OR (i.entity_type = ? AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.id = i.entity_id))
+		   OR (i.entity_type = ? AND NOT EXISTS (SELECT 1 FROM comparison_stores cs WHERE cs.id = i.entity_id))
+		   OR (i.entity_type = ? AND NOT EXISTS (SELECT 1 FROM comparison_products cp WHERE cp.id::text = i.entity_id))
+		ORDER BY i.created_at`,
+		ImageEntitySpace, ImageEntityTransaction, ImageEntityStore, ImageEntityProduct,
+	).Scan(&images).Error
+	return images, err
+}
+
+// orphanObjects lists the bucket and returns keys no Image row or unexpired
+// upload intent references, along with the number of objects listed.
+func (g *ImageGC) orphanObjects(ctx context.Context) ([]string, int, error) {
+	var rows []models.Image
+	if err := g.db.WithContext(ctx).Model(&models.Image{}).
+		Select("file_path", "thumb_path", "medium_path").
+		Find(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("load image keys: %w", err)
+	}
+	referenced := make(map[string]bool, len(rows)*3)
+	for _, img := range rows {
+		for _, key := range img.Keys() {
+			referenced[key] = true
+		}
+	}
+	var staging []string
+	if err := g.db.WithContext(ctx).Model(&models.PendingUpload{}).
+		Where("expires_at >= ?", time.Now()).
+		Pluck("key", &staging).Error; err != nil {
+		return nil, 0, fmt.Errorf("load upload keys: %w", err)
+	}
+	for _, key := range staging {
+		referenced[key] = true
+	}
+
+	var orphans []string
+	listed := 0
+	err := g.store.List(ctx, func(o storage.Object) error {
+		if !gcOwnsKey(o.Key) {
+			return nil
+		}
+		listed++
+		if !referenced[o.Key] {
+			orphans = append(orphans, o.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("list objects: %w", err)
+	}
+	return orphans, listed, nil
+}
+
+// reconcileCandidates records first sightings of the current orphans, drops
+// candidates that are no longer orphaned and returns when each current
+// orphan was first seen, keyed by kind + "\x00" + ref.
+func (g *ImageGC) reconcileCandidates(ctx context.Context, current []models.OrphanCandidate, now time.Time) (map[string]time.Time, error) {
+	firstSeen := make(map[string]time.Time, len(current))
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var known []models.OrphanCandidate
+		if err := tx.Find(&known).Error; err != nil {
+			return err
+		}
+		isCurrent := make(map[string]bool, len(current))
+		for _, c := range current {
+			isCurrent[c.Kind+"\x00"+c.Ref] = true
+		}
+		for _, k := range known {
+			id := k.Kind + "\x00" + k.Ref
+			if isCurrent[id] {
+				firstSeen[id] = k.FirstSeenAt
+				continue
+			}
+			if err := tx.Where("kind = ? AND ref = ?", k.Kind, k.Ref).
+				Delete(&models.OrphanCandidate{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var fresh []models.OrphanCandidate
+		for _, c := range current {
+			id := c.Kind + "\x00" + c.Ref
+			if _, ok := firstSeen[id]; !ok {
+				c.FirstSeenAt = now
+				firstSeen[id] = now
+				fresh = append(fresh, c)
+			}
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(fresh, 500).Error
+	})
+	return firstSeen, err
+}
+
+// deleteImage removes an orphaned Image row and its objects. Objects go
+// first: if the row went first and an object delete failed, nothing would
+// reference the leftover and the next pass would restart its grace period.
+func (g *ImageGC) deleteImage(ctx context.Context, img models.Image) error {
+	for _, key := range img.Keys() {
+		if err := g.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Image{}, "id = ?", img.ID).Error; err != nil {
+			return err
+		}
+		return tx.Where("kind = ? AND ref = ?", models.OrphanKindImage, img.ID.String()).
+			Delete(&models.OrphanCandidate{}).Error
+	})
+}
+
+// deleteObject removes an unreferenced object after checking that no row
+// picked the key up since the listing.
+func (g *ImageGC) deleteObject(ctx context.Context, key string) error {
+	var count int64
+	if err := g.db.WithContext(ctx).Model(&models.Image{}).
+		Where("file_path = ? OR thumb_path = ? OR medium_path = ?", key, key, key).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := g.store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return g.db.WithContext(ctx).
+		Where("kind = ? AND ref = ?", models.OrphanKindObject, key).
+		Delete(&models.OrphanCandidate{}).Error
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/storage"
+	"lovelion/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memObjects map[string]bool
+
+func (m memObjects) List(ctx context.Context, fn func(storage.Object) error) error {
+	for key := range m {
+		if err := fn(storage.Object{Key: key}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m memObjects) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestImageGC_DeletesOrphansAfterGracePeriod(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	createPendingExpense(t, db, space.ID, "transaction/live.jpg")
+
+	// An image left behind by a deleted transaction, with its variants.
+	stale := models.Image{
+		ID: uuid.New(), EntityType: ImageEntityTransaction, EntityID: "deleted1",
+		FilePath: "transaction/stale.jpg", ThumbPath: "transaction/stale_thumb.jpg",
+	}
+	require.NoError(t, db.Create(&stale).Error)
+
+	objects := memObjects{
+		"transaction/live.jpg":        true,
+		"transaction/stale.jpg":       true,
+		"transaction/stale_thumb.jpg": true,
+		"space/stray.jpg":             true,
+		"backups/db.sql.gz":           true, // not ours, never touched
+	}
+	ctx := context.Background()
+	gc := NewImageGC(db, objects, ImageGCConfig{Grace: time.Hour})
+
+	// First pass only records what it found.
+	report, err := gc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Objects)
+	require.Len(t, report.Orphans, 2)
+	refs := map[string]Orphan{}
+	for _, o := range report.Orphans {
+		refs[o.Ref] = o
+		assert.False(t, o.Due)
+	}
+	assert.Equal(t, "transaction/deleted1", refs[stale.ID.String()].Detail)
+	assert.Equal(t, models.OrphanKindObject, refs["space/stray.jpg"].Kind)
+	assert.Zero(t, report.Deleted)
+	assert.Len(t, objects, 5)
+
+	// Pretend the grace period has passed.
+	require.NoError(t, db.Model(&models.OrphanCandidate{}).Where("1 = 1").
+		Update("first_seen_at", time.Now().Add(-2*time.Hour)).Error)
+
+	dry := NewImageGC(db, objects, ImageGCConfig{Grace: time.Hour, DryRun: true})
+	report, err = dry.Collect(ctx)
+	require.NoError(t, err)
+	for _, o := range report.Orphans {
+		assert.True(t, o.Due)
+		assert.False(t, o.Deleted)
+	}
+	assert.Len(t, objects, 5, "dry run deletes nothing")
+
+	report, err = gc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Deleted)
+	assert.Zero(t, report.Failed)
+	assert.Equal(t, memObjects{"transaction/live.jpg": true, "backups/db.sql.gz": true}, objects)
+
+	var count int64
+	db.Model(&models.Image{}).Where("id = ?", stale.ID).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&models.Image{}).Count(&count)
+	assert.EqualValues(t, 1, count, "the live image is kept")
+	db.Model(&models.OrphanCandidate{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestImageGC_ForgetsObjectsThatAreReferencedAgain(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+
+	// An upload whose Image row wasn't committed yet when the pass listed it.
+	objects := memObjects{"space/late.jpg": true}
+	gc := NewImageGC(db, objects, ImageGCConfig{}).WithGrace(0)
+	ctx := context.Background()
+
+	require.NoError(t, db.Create(&models.OrphanCandidate{
+		Kind: models.OrphanKindObject, Ref: "space/late.jpg", FirstSeenAt: time.Now().Add(-time.Hour),
+	}).Error)
+	require.NoError(t, db.Create(&models.Image{
+		ID: uuid.New(), EntityType: ImageEntitySpace, EntityID: space.ID.String(), FilePath: "space/late.jpg",
+	}).Error)
+
+	report, err := gc.Collect(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Orphans)
+	assert.True(t, objects["space/late.jpg"])
+
+	var count int64
+	db.Model(&models.OrphanCandidate{}).Count(&count)
+	assert.Zero(t, count, "the candidate is dropped once referenced")
+}
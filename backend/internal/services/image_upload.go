@@ -0,0 +1,81 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+)
+
+// MsgUnsupportedImageType is the message returned for uploads whose content isn't one
+// of the accepted image formats, whatever their file name says.
+const MsgUnsupportedImageType = "Only JPEG, PNG, WebP and HEIC images are allowed"
+
+// ProcessImage runs an upload through imaging.Process, mapping its errors to
+// API errors. Every upload path goes through here so stored images are
+// always upright, free of EXIF and in a format browsers can show.
+func ProcessImage(body []byte, opts imaging.Options) (*imaging.Result, error) {
+	if imaging.Sniff(body) == "" {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, MsgUnsupportedImageType)
+	}
+	res, err := imaging.Process(body, opts)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupported) {
+			return nil, errorx.Wrap(errorx.ErrBadRequest, "Unsupported or corrupt image")
+		}
+		if errors.Is(err, imaging.ErrTooLarge) {
+			return nil, errorx.Wrap(errorx.ErrBadRequest, "Image dimensions are too large")
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to process image")
+	}
+	return res, nil
+}
+
+// StoreImage writes a processed image's original plus its thumb and medium
+// variants under "<entityType>/<id>". The returned row is not saved. keys
+// lists every object written, even on error, so the caller can delete them
+// if anything later fails.
+func StoreImage(ctx context.Context, store ImageStorage, entityType, entityID string, res *imaging.Result) (*models.Image, []string, error) {
+	id := uuid.New()
+	img := &models.Image{
+		ID:         id,
+		EntityID:   entityID,
+		EntityType: entityType,
+		BlurHash:   res.BlurHash,
+
+		ContentHash:    res.ContentHash,
+		PerceptualHash: res.PerceptualHash,
+	}
+
+	var keys []string
+	put := func(suffix string, enc *imaging.Encoded) (string, error) {
+		key := fmt.Sprintf("%s/%s%s%s", entityType, id.String(), suffix, enc.Ext)
+		if _, err := store.Upload(ctx, key, bytes.NewReader(enc.Data), enc.ContentType); err != nil {
+			return "", errorx.Wrap(errorx.ErrInternal, "Failed to upload image")
+		}
+		keys = append(keys, key)
+		return key, nil
+	}
+
+	var err error
+	if img.FilePath, err = put("", &res.Original); err != nil {
+		return nil, keys, err
+	}
+	if res.Medium != nil {
+		if img.MediumPath, err = put("_medium", res.Medium); err != nil {
+			return nil, keys, err
+		}
+	}
+	if res.Thumb != nil {
+		if img.ThumbPath, err = put("_thumb", res.Thumb); err != nil {
+			return nil, keys, err
+		}
+	}
+	return img, keys, nil
+}
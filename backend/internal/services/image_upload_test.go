@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingStorage struct {
+	uploads   map[string]string // key -> content type
+	failAfter int               // fail the nth upload (1-based); 0 never fails
+}
+
+func (r *recordingStorage) Upload(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	if r.failAfter > 0 && len(r.uploads)+1 == r.failAfter {
+		return "", errors.New("bucket unavailable")
+	}
+	if r.uploads == nil {
+		r.uploads = map[string]string{}
+	}
+	r.uploads[key] = contentType
+	return "", nil
+}
+
+func (r *recordingStorage) Delete(ctx context.Context, key string) error { return nil }
+
+func testPhoto(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil))
+	return buf.Bytes()
+}
+
+func TestStoreImage_UploadsVariants(t *testing.T) {
+	store := &recordingStorage{}
+	res, err := ProcessImage(testPhoto(t, 2000, 1500), imaging.Options{MaxDimension: 1600})
+	require.NoError(t, err)
+	img, keys, err := StoreImage(context.Background(), store, ImageEntityTransaction, "txn1", res)
+	require.NoError(t, err)
+
+	id := img.ID.String()
+	assert.Equal(t, "transaction/"+id+".jpg", img.FilePath)
+	assert.Equal(t, "transaction/"+id+"_medium.jpg", img.MediumPath)
+	assert.Equal(t, "transaction/"+id+"_thumb.jpg", img.ThumbPath)
+	assert.Equal(t, "txn1", img.EntityID)
+	assert.NotEmpty(t, img.BlurHash)
+	assert.ElementsMatch(t, img.Keys(), keys)
+	assert.Equal(t, "image/jpeg", store.uploads[img.ThumbPath])
+}
+
+func TestStoreImage_ReturnsWrittenKeysOnFailure(t *testing.T) {
+	store := &recordingStorage{failAfter: 2}
+	res, err := ProcessImage(testPhoto(t, 2000, 1500), imaging.Options{})
+	require.NoError(t, err)
+	_, keys, err := StoreImage(context.Background(), store, ImageEntitySpace, "s1", res)
+	require.Error(t, err)
+	assert.True(t, errorx.Is(err, errorx.ErrInternal))
+	assert.Len(t, keys, 1, "the original was written and must be cleaned up")
+}
+
+func TestProcessImage_RejectsGarbage(t *testing.T) {
+	_, err := ProcessImage([]byte("nope"), imaging.Options{})
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+}
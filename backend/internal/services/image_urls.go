@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"lovelion/internal/models"
+)
+
+// ImagePresigner issues time-limited read URLs for storage keys.
+type ImagePresigner interface {
+	PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ImageURLSigner fills in Image.URL for API responses. A nil signer leaves
+// URLs empty, so callers that don't serve images need not configure one.
+type ImageURLSigner struct {
+	store ImagePresigner
+	ttl   time.Duration
+}
+
+func NewImageURLSigner(store ImagePresigner, ttl time.Duration) *ImageURLSigner {
+	return &ImageURLSigner{store: store, ttl: ttl}
+}
+
+// Images signs every image and its variants in place. Missing variants fall
+// back to the original. A signing failure is logged and leaves that image's
+// URLs empty rather than failing the whole response.
+func (s *ImageURLSigner) Images(ctx context.Context, images []models.Image) {
+	if s == nil {
+		return
+	}
+	for i := range images {
+		img := &images[i]
+		url, err := s.sign(ctx, img, img.FilePath)
+		if err != nil {
+			continue
+		}
+		img.URL, img.ThumbURL, img.MediumURL = url, url, url
+		if img.ThumbPath != "" {
+			if thumb, err := s.sign(ctx, img, img.ThumbPath); err == nil {
+				img.ThumbURL = thumb
+			}
+		}
+		if img.MediumPath != "" {
+			if medium, err := s.sign(ctx, img, img.MediumPath); err == nil {
+				img.MediumURL = medium
+			}
+		}
+	}
+}
+
+func (s *ImageURLSigner) sign(ctx context.Context, img *models.Image, key string) (string, error) {
+	url, err := s.store.PresignedURL(ctx, key, s.ttl)
+	if err != nil {
+		slog.Warn("failed to sign image url", "image_id", img.ID, "key", key, "error", err)
+	}
+	return url, err
+}
+
+// Transaction signs the images attached to txn; a nil txn is a no-op.
+func (s *ImageURLSigner) Transaction(ctx context.Context, txn *models.Transaction) {
+	if txn != nil {
+		s.Images(ctx, txn.Images)
+	}
+}
+
+// Transactions signs the images of every transaction in txns.
+func (s *ImageURLSigner) Transactions(ctx context.Context, txns []models.Transaction) {
+	for i := range txns {
+		s.Images(ctx, txns[i].Images)
+	}
+}
+
+// Space signs the space's images and sets CoverImage from the first one's
+// medium variant.
+func (s *ImageURLSigner) Space(ctx context.Context, space *models.Space) {
+	s.Images(ctx, space.Images)
+	space.PopulateCoverImage()
+}
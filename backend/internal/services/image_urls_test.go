@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lovelion/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePresigner struct {
+	ttl time.Duration
+	err map[string]error
+}
+
+func (f *fakePresigner) PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	f.ttl = ttl
+	if err := f.err[key]; err != nil {
+		return "", err
+	}
+	return "https://signed.test/" + key, nil
+}
+
+func TestImageURLSigner_Space(t *testing.T) {
+	store := &fakePresigner{err: map[string]error{"space/broken.jpg": errors.New("boom")}}
+	signer := NewImageURLSigner(store, 5*time.Minute)
+
+	space := &models.Space{Images: []models.Image{
+		{FilePath: "space/cover.jpg", ThumbPath: "space/cover_thumb.jpg", MediumPath: "space/cover_medium.jpg"},
+		{FilePath: "space/broken.jpg"},
+		{FilePath: "space/small.jpg"},
+	}}
+	signer.Space(context.Background(), space)
+
+	assert.Equal(t, 5*time.Minute, store.ttl)
+	assert.Equal(t, "https://signed.test/space/cover.jpg", space.Images[0].URL)
+	assert.Equal(t, "https://signed.test/space/cover_thumb.jpg", space.Images[0].ThumbURL)
+	assert.Equal(t, "https://signed.test/space/cover_medium.jpg", space.Images[0].MediumURL)
+	assert.Equal(t, "https://signed.test/space/cover_medium.jpg", space.CoverImage)
+	assert.Equal(t, "https://signed.test/space/small.jpg", space.Images[2].ThumbURL, "missing variants fall back to the original")
+	assert.Empty(t, space.Images[1].URL, "a signing failure leaves only that URL empty")
+	assert.Equal(t, "space/cover.jpg", space.Images[0].FilePath, "the key is left untouched")
+}
+
+func TestImageURLSigner_NilIsNoop(t *testing.T) {
+	var signer *ImageURLSigner
+	txns := []models.Transaction{{Images: []models.Image{{FilePath: "transaction/a.jpg"}}}}
+	signer.Transactions(context.Background(), txns)
+	assert.Empty(t, txns[0].Images[0].URL)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"time"
 
 	"lovelion/internal/models"
@@ -15,29 +16,38 @@ import (
 )
 
 type InviteService struct {
-	db         *gorm.DB
-	inviteRepo *repositories.InviteRepo
-	memberRepo *repositories.MemberRepo
+	db              *gorm.DB
+	inviteRepo      *repositories.InviteRepo
+	memberRepo      *repositories.MemberRepo
+	joinRequestRepo *repositories.JoinRequestRepo
 }
 
-func NewInviteService(db *gorm.DB, inviteRepo *repositories.InviteRepo, memberRepo *repositories.MemberRepo) *InviteService {
+func NewInviteService(db *gorm.DB, inviteRepo *repositories.InviteRepo, memberRepo *repositories.MemberRepo, joinRequestRepo *repositories.JoinRequestRepo) *InviteService {
 	return &InviteService{
-		db:         db,
-		inviteRepo: inviteRepo,
-		memberRepo: memberRepo,
+		db:              db,
+		inviteRepo:      inviteRepo,
+		memberRepo:      memberRepo,
+		joinRequestRepo: joinRequestRepo,
 	}
 }
 
 type CreateInviteParams struct {
-	IsOneTime bool
-	MaxUses   int
-	ExpiresAt *time.Time
+	// Role is granted to everyone who joins through the invite; editor
+	// when empty. Owner can't be granted.
+	Role string
+	// RequiresApproval turns joining into a request the owner decides on.
+	RequiresApproval bool
+	IsOneTime        bool
+	MaxUses          int
+	ExpiresAt        *time.Time
 }
 
 type InviteInfo struct {
-	SpaceName   string `json:"space_name"`
-	CreatorName string `json:"creator_name"`
-	IsOneTime   bool   `json:"is_one_time"`
+	SpaceName        string `json:"space_name"`
+	CreatorName      string `json:"creator_name"`
+	IsOneTime        bool   `json:"is_one_time"`
+	Role             string `json:"role"`
+	RequiresApproval bool   `json:"requires_approval"`
 }
 
 func generateToken() string {
@@ -50,6 +60,10 @@ func validateInvite(invite *models.SpaceInvite) error {
 	if invite.ExpiresAt != nil && invite.ExpiresAt.Before(time.Now()) {
 		return errorx.Wrap(errorx.ErrExpired, "Invite link has expired")
 	}
+	return checkInviteUses(invite)
+}
+
+func checkInviteUses(invite *models.SpaceInvite) error {
 	if invite.MaxUses > 0 && invite.UseCount >= invite.MaxUses {
 		return errorx.Wrap(errorx.ErrExhausted, "Invite link has reached its maximum usage")
 	}
@@ -57,14 +71,23 @@ func validateInvite(invite *models.SpaceInvite) error {
 }
 
 func (s *InviteService) Create(ctx context.Context, spaceID uuid.UUID, userID uuid.UUID, params CreateInviteParams) (*models.SpaceInvite, error) {
+	if params.Role == "" {
+		params.Role = models.SpaceRoleEditor
+	}
+	if !models.IsSpaceRole(params.Role) || params.Role == models.SpaceRoleOwner {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Invalid role")
+	}
+
 	invite := &models.SpaceInvite{
-		ID:        uuid.New(),
-		SpaceID:   spaceID,
-		Token:     generateToken(),
-		IsOneTime: params.IsOneTime,
-		MaxUses:   params.MaxUses,
-		ExpiresAt: params.ExpiresAt,
-		CreatedBy: userID,
+		ID:               uuid.New(),
+		SpaceID:          spaceID,
+		Token:            generateToken(),
+		Role:             params.Role,
+		RequiresApproval: params.RequiresApproval,
+		IsOneTime:        params.IsOneTime,
+		MaxUses:          params.MaxUses,
+		ExpiresAt:        params.ExpiresAt,
+		CreatedBy:        userID,
 	}
 
 	if invite.IsOneTime && invite.MaxUses <= 0 {
@@ -89,16 +112,23 @@ func (s *InviteService) GetInfo(ctx context.Context, token string) (*InviteInfo,
 	}
 
 	return &InviteInfo{
-		SpaceName:   invite.Space.Name,
-		CreatorName: invite.Creator.DisplayName,
-		IsOneTime:   invite.IsOneTime,
+		SpaceName:        invite.Space.Name,
+		CreatorName:      invite.Creator.DisplayName,
+		IsOneTime:        invite.IsOneTime,
+		Role:             invite.Role,
+		RequiresApproval: invite.RequiresApproval,
 	}, nil
 }
 
-func (s *InviteService) Join(ctx context.Context, token string, userID uuid.UUID) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+// Join adds userID to the invite's space. For an invite that requires
+// approval it instead returns the user's pending join request, creating it
+// if needed; the invite's use is consumed when the owner approves it.
+func (s *InviteService) Join(ctx context.Context, token string, userID uuid.UUID) (*models.SpaceJoinRequest, error) {
+	var request *models.SpaceJoinRequest
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		inviteRepo := s.inviteRepo.WithTx(tx)
 		memberRepo := s.memberRepo.WithTx(tx)
+		joinRequestRepo := s.joinRequestRepo.WithTx(tx)
 
 		invite, err := inviteRepo.FindByTokenForUpdate(ctx, token)
 		if err != nil {
@@ -114,11 +144,29 @@ func (s *InviteService) Join(ctx context.Context, token string, userID uuid.UUID
 			return nil // Already a member, no-op
 		}
 
+		if invite.RequiresApproval {
+			if existing, err := joinRequestRepo.FindPending(ctx, invite.SpaceID, userID); err == nil {
+				request = existing
+				return nil
+			}
+			request = &models.SpaceJoinRequest{
+				ID:       uuid.New(),
+				SpaceID:  invite.SpaceID,
+				InviteID: invite.ID,
+				UserID:   userID,
+				Status:   models.JoinRequestPending,
+			}
+			if err := joinRequestRepo.Create(ctx, request); err != nil {
+				return errorx.Wrap(errorx.ErrInternal, "Failed to create join request")
+			}
+			return nil
+		}
+
 		member := &models.SpaceMember{
 			ID:      uuid.New(),
 			SpaceID: invite.SpaceID,
 			UserID:  userID,
-			Role:    "member",
+			Role:    invite.Role,
 		}
 
 		if err := memberRepo.Create(ctx, member); err != nil {
@@ -131,6 +179,86 @@ func (s *InviteService) Join(ctx context.Context, token string, userID uuid.UUID
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (s *InviteService) ListRequests(ctx context.Context, spaceID uuid.UUID) ([]models.SpaceJoinRequest, error) {
+	requests, err := s.joinRequestRepo.FindPendingBySpace(ctx, spaceID)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to fetch join requests")
+	}
+	return requests, nil
+}
+
+// ApproveRequest adds the requester with the invite's role and consumes one
+// use of the invite. Both rows are locked so approvals racing each other, or
+// a direct join, can't take the invite past its maximum uses. Expiry isn't
+// checked again: the request was made while the link was valid.
+func (s *InviteService) ApproveRequest(ctx context.Context, spaceID uuid.UUID, requestID uuid.UUID, deciderID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		inviteRepo := s.inviteRepo.WithTx(tx)
+		memberRepo := s.memberRepo.WithTx(tx)
+		joinRequestRepo := s.joinRequestRepo.WithTx(tx)
+
+		request, err := joinRequestRepo.FindPendingForUpdate(ctx, requestID, spaceID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.Wrap(errorx.ErrNotFound, "Join request not found")
+		}
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to load join request")
+		}
+
+		invite, err := inviteRepo.FindByIDForUpdate(ctx, request.InviteID, spaceID)
+		if err != nil {
+			return errorx.Wrap(errorx.ErrNotFound, "Invite link invalid")
+		}
+
+		// Someone who joined meanwhile through another link only has the
+		// request closed, without using up the invite.
+		if _, err := memberRepo.FindBySpaceAndUser(ctx, spaceID, request.UserID); err != nil {
+			if err := checkInviteUses(invite); err != nil {
+				return err
+			}
+			member := &models.SpaceMember{
+				ID:      uuid.New(),
+				SpaceID: spaceID,
+				UserID:  request.UserID,
+				Role:    invite.Role,
+			}
+			if err := memberRepo.Create(ctx, member); err != nil {
+				return errorx.Wrap(errorx.ErrInternal, "Failed to add member")
+			}
+			if err := inviteRepo.IncrementUseCount(ctx, invite.ID); err != nil {
+				return errorx.Wrap(errorx.ErrInternal, "Failed to update invite usage")
+			}
+		}
+
+		if err := joinRequestRepo.Decide(ctx, request.ID, models.JoinRequestApproved, deciderID); err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to update join request")
+		}
+		return nil
+	})
+}
+
+func (s *InviteService) RejectRequest(ctx context.Context, spaceID uuid.UUID, requestID uuid.UUID, deciderID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		joinRequestRepo := s.joinRequestRepo.WithTx(tx)
+
+		request, err := joinRequestRepo.FindPendingForUpdate(ctx, requestID, spaceID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorx.Wrap(errorx.ErrNotFound, "Join request not found")
+		}
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to load join request")
+		}
+		if err := joinRequestRepo.Decide(ctx, request.ID, models.JoinRequestRejected, deciderID); err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to update join request")
+		}
+		return nil
+	})
 }
 
 func (s *InviteService) ListActive(ctx context.Context, spaceID uuid.UUID) ([]models.SpaceInvite, error) {
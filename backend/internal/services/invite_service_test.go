@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lovelion/internal/models"
+	"lovelion/internal/repositories"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestInviteService(db *gorm.DB) *InviteService {
+	return NewInviteService(db, repositories.NewInviteRepo(db), repositories.NewMemberRepo(db), repositories.NewJoinRequestRepo(db))
+}
+
+func TestInviteService_ApprovalConsumesUse(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	first := testutil.CreateTestUser(t, db)
+	second := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: models.SpaceRoleOwner}).Error)
+
+	invites := newTestInviteService(db)
+	ctx := context.Background()
+	invite, err := invites.Create(ctx, space.ID, owner.ID, CreateInviteParams{
+		Role:             models.SpaceRoleViewer,
+		RequiresApproval: true,
+		MaxUses:          1,
+	})
+	require.NoError(t, err)
+
+	req, err := invites.Join(ctx, invite.Token, first.ID)
+	require.NoError(t, err)
+	require.NotNil(t, req)
+	assert.Equal(t, models.JoinRequestPending, req.Status)
+	again, err := invites.Join(ctx, invite.Token, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, req.ID, again.ID, "joining twice keeps one request")
+
+	other, err := invites.Join(ctx, invite.Token, second.ID)
+	require.NoError(t, err, "requests don't use up the invite")
+
+	var count int64
+	db.Model(&models.SpaceMember{}).Where("space_id = ? AND user_id = ?", space.ID, first.ID).Count(&count)
+	assert.Zero(t, count, "not a member until approved")
+
+	requests, err := invites.ListRequests(ctx, space.ID)
+	require.NoError(t, err)
+	assert.Len(t, requests, 2)
+
+	require.NoError(t, invites.ApproveRequest(ctx, space.ID, req.ID, owner.ID))
+	var member models.SpaceMember
+	require.NoError(t, db.Where("space_id = ? AND user_id = ?", space.ID, first.ID).First(&member).Error)
+	assert.Equal(t, models.SpaceRoleViewer, member.Role)
+	var reloaded models.SpaceInvite
+	require.NoError(t, db.First(&reloaded, "id = ?", invite.ID).Error)
+	assert.Equal(t, 1, reloaded.UseCount)
+
+	err = invites.ApproveRequest(ctx, space.ID, req.ID, owner.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound), "a request is decided once")
+	err = invites.ApproveRequest(ctx, space.ID, other.ID, owner.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrExhausted))
+
+	require.NoError(t, invites.RejectRequest(ctx, space.ID, other.ID, owner.ID))
+	requests, err = invites.ListRequests(ctx, space.ID)
+	require.NoError(t, err)
+	assert.Empty(t, requests)
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"lovelion/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// MemberSummary is one member's own figures in a space, in the space's base
+// currency.
+type MemberSummary struct {
+	// MemberName is the split member the user was matched to; empty when
+	// neither their alias nor their display name is one, and then all
+	// figures are zero.
+	MemberName string          `json:"member_name"`
+	Currency   string          `json:"currency"`
+	TotalPaid  decimal.Decimal `json:"total_paid"`
+	Share      decimal.Decimal `json:"share"`
+	// PaidUnconverted and ShareUnconverted hold, per currency, foreign
+	// expenses with no billing amount and no known rate to the base
+	// currency. They are not part of TotalPaid, Share or Categories.
+	PaidUnconverted  map[string]decimal.Decimal `json:"total_paid_unconverted,omitempty"`
+	ShareUnconverted map[string]decimal.Decimal `json:"share_unconverted,omitempty"`
+	// Net is Balances summed: positive when the others owe the member.
+	Net        Balance         `json:"net"`
+	Balances   []Balance       `json:"balances"`
+	Categories []CategorySpend `json:"categories"`
+}
+
+// CategorySpend is the member's share of the expenses in one category.
+type CategorySpend struct {
+	Category string          `json:"category"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
+type summaryExpenseRow struct {
+	ID            string
+	Currency      string
+	TotalAmount   decimal.Decimal
+	BillingAmount decimal.Decimal
+	Category      string
+	CreatedBy     *uuid.UUID
+}
+
+// SpaceMemberSummary works out what userID paid and consumed in a space and
+// where they stand with each other member.
+//
+// An expense's debts hold every participant's share, the payer's own
+// included, all owed to the payer, so the payee is who paid and a debt's
+// amount is that participant's share. Shares are converted to the base
+// currency in proportion to the billing amount; a foreign expense without
+// one is converted at the latest known rate, as on the dashboard, or else
+// reported unconverted. An expense without debts
+// counts as paid and consumed by whoever entered it. Balances follow
+// SpaceBalances, limited to debts between the member and one other.
+func SpaceMemberSummary(ctx context.Context, db *gorm.DB, spaceID uuid.UUID, userID uuid.UUID) (*MemberSummary, error) {
+	var space models.Space
+	if err := db.WithContext(ctx).Select("base_currency", "split_members").First(&space, "id = ?", spaceID).Error; err != nil {
+		return nil, err
+	}
+	summary := &MemberSummary{
+		Currency:         space.BaseCurrency,
+		PaidUnconverted:  map[string]decimal.Decimal{},
+		ShareUnconverted: map[string]decimal.Decimal{},
+		Net:              Balance{Foreign: map[string]decimal.Decimal{}},
+		Balances:         []Balance{},
+		Categories:       []CategorySpend{},
+	}
+
+	name, err := memberSplitName(ctx, db, spaceID, userID, space.SplitMembers)
+	if err != nil || name == "" {
+		return summary, err
+	}
+	summary.MemberName = name
+
+	debts, err := spaceDebts(ctx, db, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var expenses []summaryExpenseRow
+	err = db.WithContext(ctx).Raw(`
+		SELECT t.id, t.currency, t.total_amount, COALESCE(e.billing_amount, 0) AS billing_amount,
+		       COALESCE(e.category, '') AS category, t.created_by
+		FROM transactions t
+		LEFT JOIN transaction_expenses e ON e.transaction_id = t.id
+		WHERE t.space_id = ? AND t.type = 'expense'`, spaceID,
+	).Scan(&expenses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	debtsByTxn := map[string][]debtRow{}
+	for _, d := range debts {
+		debtsByTxn[d.TransactionID] = append(debtsByTxn[d.TransactionID], d)
+	}
+	summary.Net, summary.Balances = memberBalances(name, debts)
+
+	byCategory := map[string]decimal.Decimal{}
+	var rates map[ratePair]decimal.Decimal
+	for _, e := range expenses {
+		amount, converted := e.BillingAmount, e.BillingAmount.IsPositive()
+		if !converted {
+			if rates == nil && e.Currency != space.BaseCurrency {
+				if rates, err = knownRates(ctx, db, []uuid.UUID{spaceID}); err != nil {
+					return nil, err
+				}
+			}
+			if amount, converted = convertAmount(rates, e.TotalAmount, e.Currency, space.BaseCurrency); !converted {
+				amount = e.TotalAmount
+			}
+		}
+		if !amount.IsPositive() {
+			continue
+		}
+
+		var paid bool
+		var share decimal.Decimal
+		if rows := debtsByTxn[e.ID]; len(rows) == 0 {
+			paid = e.CreatedBy != nil && *e.CreatedBy == userID
+			if paid {
+				share = amount
+			}
+		} else if e.TotalAmount.IsPositive() {
+			paid = rows[0].PayeeName == name
+			for _, d := range rows {
+				if d.PayerName == name {
+					share = share.Add(d.Amount)
+				}
+			}
+			share = share.Mul(amount).Div(e.TotalAmount)
+		}
+
+		if !converted {
+			if paid {
+				summary.PaidUnconverted[e.Currency] = summary.PaidUnconverted[e.Currency].Add(amount)
+			}
+			if share.IsPositive() {
+				summary.ShareUnconverted[e.Currency] = summary.ShareUnconverted[e.Currency].Add(share)
+			}
+			continue
+		}
+		if paid {
+			summary.TotalPaid = summary.TotalPaid.Add(amount)
+		}
+		if share.IsPositive() {
+			summary.Share = summary.Share.Add(share)
+			byCategory[e.Category] = byCategory[e.Category].Add(share)
+		}
+	}
+
+	summary.Share = summary.Share.Round(2)
+	for cat, amount := range byCategory {
+		summary.Categories = append(summary.Categories, CategorySpend{Category: cat, Amount: amount.Round(2)})
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		if c := summary.Categories[i].Amount.Cmp(summary.Categories[j].Amount); c != 0 {
+			return c > 0
+		}
+		return summary.Categories[i].Category < summary.Categories[j].Category
+	})
+	return summary, nil
+}
+
+// memberBalances nets the debts between split member name and each other
+// member, plus their total. Debts not involving name are ignored.
+func memberBalances(name string, debts []debtRow) (Balance, []Balance) {
+	net := Balance{Foreign: map[string]decimal.Decimal{}}
+	others := map[string]*Balance{}
+	for _, d := range debts {
+		var other string
+		switch {
+		case d.PayerName == d.PayeeName:
+			continue
+		case d.PayeeName == name:
+			other = d.PayerName
+		case d.PayerName == name:
+			other = d.PayeeName
+		default:
+			continue
+		}
+		b, ok := others[other]
+		if !ok {
+			b = &Balance{Name: other, Foreign: map[string]decimal.Decimal{}}
+			others[other] = b
+		}
+		b.addDebt(d, d.PayeeName == name)
+		net.addDebt(d, d.PayeeName == name)
+	}
+	for cur, v := range net.Foreign {
+		if v.IsZero() {
+			delete(net.Foreign, cur)
+		}
+	}
+	return net, sortedBalances(others)
+}
+
+// memberSplitName maps a space member to one of the space's split members,
+// preferring their alias in the space over their display name, the same way
+// the AI split resolves "me".
+func memberSplitName(ctx context.Context, db *gorm.DB, spaceID, userID uuid.UUID, splitMembers []byte) (string, error) {
+	var members []string
+	if len(splitMembers) > 0 {
+		_ = json.Unmarshal(splitMembers, &members)
+	}
+	var row struct {
+		Alias       string
+		DisplayName string
+	}
+	err := db.WithContext(ctx).
+		Table("space_members").
+		Select("space_members.alias, users.display_name").
+		Joins("JOIN users ON users.id = space_members.user_id").
+		Where("space_members.space_id = ? AND space_members.user_id = ?", spaceID, userID).
+		Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return splitNameOf(row.Alias, row.DisplayName, members), nil
+}
+
+// splitNameOf returns the split member matching alias, or else displayName.
+func splitNameOf(alias, displayName string, members []string) string {
+	for _, name := range []string{alias, displayName} {
+		if m := matchMember(name, members); m != "" {
+			return m
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestSpaceMemberSummary(t *testing.T) {
+	db := testutil.TestDB(t)
+	amy := testutil.CreateTestUser(t, db)
+	bob := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, amy.ID)
+	require.NoError(t, db.Model(space).Update("split_members", datatypes.JSON(`["Amy","Bob","Cat"]`)).Error)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: amy.ID, Role: models.SpaceRoleOwner, Alias: "amy"}).Error)
+	addMember(t, db, space.ID, bob.ID)
+
+	expenseIn := func(currency, id, category string, total, billing int64, debts ...models.TransactionDebt) {
+		require.NoError(t, db.Create(&models.Transaction{ID: id, SpaceID: space.ID, Type: "expense", Currency: currency, TotalAmount: decimal.NewFromInt(total), CreatedBy: &bob.ID}).Error)
+		require.NoError(t, db.Create(&models.TransactionExpense{ID: uuid.New(), TransactionID: id, Category: category, BillingAmount: decimal.NewFromInt(billing)}).Error)
+		for _, d := range debts {
+			d.ID, d.TransactionID = uuid.New(), id
+			require.NoError(t, db.Create(&d).Error)
+		}
+	}
+	expense := func(id, category string, total, billing int64, debts ...models.TransactionDebt) {
+		expenseIn("JPY", id, category, total, billing, debts...)
+	}
+	debt := func(payer, payee string, amount, settled int64) models.TransactionDebt {
+		return models.TransactionDebt{PayerName: payer, PayeeName: payee, Amount: decimal.NewFromInt(amount), SettledAmount: decimal.NewFromInt(settled)}
+	}
+	// Amy pays 900 JPY (billed 200) for three; Bob pays 300 JPY (billed 60) for Amy alone.
+	expense("t1", "餐飲", 900, 200, debt("Amy", "Amy", 300, 0), debt("Bob", "Amy", 300, 66), debt("Cat", "Amy", 300, 66))
+	expense("t2", "交通", 300, 60, debt("Amy", "Bob", 300, 60))
+	// Entered by Bob with no split: not Amy's.
+	expense("t3", "購物", 500, 0)
+	// Bob's EUR expense sets a rate of 30; Amy's later one has no billing
+	// amount and is converted at it. Nothing gives a rate for USD.
+	expenseIn("EUR", "t4", "購物", 10, 300)
+	expenseIn("EUR", "t5", "住宿", 20, 0, debt("Amy", "Amy", 20, 0))
+	expenseIn("USD", "t6", "住宿", 15, 0, debt("Amy", "Amy", 15, 0))
+
+	summary, err := SpaceMemberSummary(context.Background(), db, space.ID, amy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Amy", summary.MemberName, "matched through the alias")
+	assert.True(t, summary.TotalPaid.Equal(decimal.NewFromInt(800)))
+	assert.Equal(t, "726.67", summary.Share.StringFixed(2))
+	require.Len(t, summary.PaidUnconverted, 1)
+	assert.True(t, summary.PaidUnconverted["USD"].Equal(decimal.NewFromInt(15)))
+	require.Len(t, summary.ShareUnconverted, 1)
+	assert.True(t, summary.ShareUnconverted["USD"].Equal(decimal.NewFromInt(15)))
+
+	byName := map[string]Balance{}
+	for _, b := range summary.Balances {
+		byName[b.Name] = b
+	}
+	require.Len(t, byName, 2)
+	assert.True(t, byName["Bob"].Base.Equal(decimal.NewFromInt(6)), "Bob owes 66, Amy owes 60")
+	assert.True(t, byName["Cat"].Base.Equal(decimal.NewFromInt(66)))
+	assert.True(t, summary.Net.Base.Equal(decimal.NewFromInt(72)))
+
+	require.Len(t, summary.Categories, 3)
+	assert.Equal(t, "住宿", summary.Categories[0].Category)
+	assert.Equal(t, "600.00", summary.Categories[0].Amount.StringFixed(2), "the USD share is left out")
+	assert.Equal(t, "餐飲", summary.Categories[1].Category)
+	assert.Equal(t, "66.67", summary.Categories[1].Amount.StringFixed(2))
+	assert.Equal(t, "交通", summary.Categories[2].Category)
+
+	outsider := testutil.CreateTestUser(t, db)
+	empty, err := SpaceMemberSummary(context.Background(), db, space.ID, outsider.ID)
+	require.NoError(t, err)
+	assert.Empty(t, empty.MemberName)
+	assert.True(t, empty.Share.IsZero())
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"lovelion/internal/models"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultAccessTokenTTL is how long an access token is accepted; a
+	// revoked session keeps working for at most this long.
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// refreshReuseGrace lets a refresh token that was just replaced fail
+	// softly instead of ending the session, for two tabs refreshing at once.
+	refreshReuseGrace  = 30 * time.Second
+	maxUserAgentLength = 255
+)
+
+// Sessions issues short-lived access tokens and rotating refresh tokens
+// backed by the sessions table. A session lasts sessionTTL past its last
+// refresh.
+type Sessions struct {
+	db         *gorm.DB
+	jwtSecret  string
+	accessTTL  time.Duration
+	sessionTTL time.Duration
+}
+
+func NewSessions(db *gorm.DB, jwtSecret string, sessionTTL time.Duration) *Sessions {
+	return &Sessions{db: db, jwtSecret: jwtSecret, accessTTL: DefaultAccessTokenTTL, sessionTTL: sessionTTL}
+}
+
+// WithAccessTokenTTL sets how long access tokens are valid.
+func (s *Sessions) WithAccessTokenTTL(ttl time.Duration) *Sessions {
+	s.accessTTL = ttl
+	return s
+}
+
+// TokenPair is what a client holds for a session.
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"` // of the access token
+}
+
+// ClientInfo describes the device a session is used from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// userAgent fits the header into the user_agent column, which counts
+// characters, dropping any invalid UTF-8 Postgres would reject.
+func (c ClientInfo) userAgent() string {
+	ua := strings.ToValidUTF8(c.UserAgent, "")
+	if utf8.RuneCountInString(ua) > maxUserAgentLength {
+		return string([]rune(ua)[:maxUserAgentLength])
+	}
+	return ua
+}
+
+// Start opens a session for a user who just signed in.
+func (s *Sessions) Start(ctx context.Context, userID uuid.UUID, client ClientInfo) (*TokenPair, error) {
+	now := time.Now()
+	refresh := newRefreshToken()
+	session := &models.Session{
+		ID:               uuid.New(),
+		UserID:           userID,
+		RefreshTokenHash: hashRefreshToken(refresh),
+		UserAgent:        client.userAgent(),
+		IP:               client.IP,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(s.sessionTTL),
+	}
+	if err := s.db.WithContext(ctx).Create(session).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to create session")
+	}
+	return s.tokens(session, refresh, now)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. Presenting a token that was already replaced means it leaked, so
+// the session is revoked, unless the replacement happened moments ago.
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+	now := time.Now()
+	var pair *TokenPair
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_token_hash = ?", hash).
+			First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s.replayed(tx, hash, now)
+		}
+		if err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to load session")
+		}
+		if !session.Active(now) {
+			return errorx.Wrap(errorx.ErrExpired, "Session has ended")
+		}
+
+		refresh := newRefreshToken()
+		updates := map[string]interface{}{
+			"refresh_token_hash":  hashRefreshToken(refresh),
+			"previous_token_hash": hash,
+			"rotated_at":          now,
+			"last_seen_at":        now,
+			"expires_at":          now.Add(s.sessionTTL),
+			"user_agent":          client.userAgent(),
+			"ip":                  client.IP,
+		}
+		if err := tx.Model(&session).Updates(updates).Error; err != nil {
+			return errorx.Wrap(errorx.ErrInternal, "Failed to refresh session")
+		}
+		pair, err = s.tokens(&session, refresh, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// replayed handles a refresh token that isn't current for any session.
+func (s *Sessions) replayed(tx *gorm.DB, hash string, now time.Time) error {
+	var session models.Session
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errorx.Wrap(errorx.ErrExpired, "Session has ended")
+	}
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to load session")
+	}
+	if session.RotatedAt != nil && now.Sub(*session.RotatedAt) < refreshReuseGrace {
+		return errorx.Wrap(errorx.ErrConflict, "Session was just refreshed")
+	}
+	if err := tx.Model(&session).Update("revoked_at", now).Error; err != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to revoke session")
+	}
+	return errorx.Wrap(errorx.ErrExpired, "Session has ended")
+}
+
+func (s *Sessions) tokens(session *models.Session, refresh string, now time.Time) (*TokenPair, error) {
+	expiresAt := now.Add(s.accessTTL)
+	claims := jwt.MapClaims{
+		"user_id": session.UserID.String(),
+		"sid":     session.ID.String(),
+		"exp":     expiresAt.Unix(),
+		"iat":     now.Unix(),
+	}
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.jwtSecret))
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to generate token")
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresAt: expiresAt}, nil
+}
+
+// List returns the user's usable sessions, most recently seen first.
+func (s *Sessions) List(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to fetch sessions")
+	}
+	return sessions, nil
+}
+
+// Revoke ends one of the user's sessions.
+func (s *Sessions) Revoke(ctx context.Context, userID, sessionID uuid.UUID) error {
+	res := s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to revoke session")
+	}
+	if res.RowsAffected == 0 {
+		return errorx.Wrap(errorx.ErrNotFound, "Session not found")
+	}
+	return nil
+}
+
+// RevokeAll ends every session of the user except keep, which may be
+// uuid.Nil to end them all.
+func (s *Sessions) RevokeAll(ctx context.Context, userID, keep uuid.UUID) (int64, error) {
+	res := s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return 0, errorx.Wrap(errorx.ErrInternal, "Failed to revoke sessions")
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions_RefreshRotates(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	sessions := NewSessions(db, "test-secret", 24*time.Hour)
+	ctx := context.Background()
+	client := ClientInfo{UserAgent: "Mozilla/5.0 (iPhone)", IP: "10.0.0.1"}
+
+	pair, err := sessions.Start(ctx, user.ID, client)
+	require.NoError(t, err)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(pair.AccessToken, claims, func(*jwt.Token) (interface{}, error) { return []byte("test-secret"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims["user_id"])
+	assert.NotEmpty(t, claims["sid"])
+
+	next, err := sessions.Refresh(ctx, pair.RefreshToken, client)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, next.RefreshToken)
+
+	// Two tabs racing: the replaced token fails without ending the session.
+	_, err = sessions.Refresh(ctx, pair.RefreshToken, client)
+	assert.True(t, errorx.Is(err, errorx.ErrConflict))
+
+	// Later reuse means the old token leaked: the session is revoked.
+	require.NoError(t, db.Model(&models.Session{}).Where("user_id = ?", user.ID).
+		Update("rotated_at", time.Now().Add(-time.Hour)).Error)
+	_, err = sessions.Refresh(ctx, pair.RefreshToken, client)
+	assert.True(t, errorx.Is(err, errorx.ErrExpired))
+	_, err = sessions.Refresh(ctx, next.RefreshToken, client)
+	assert.True(t, errorx.Is(err, errorx.ErrExpired), "the current token dies with the session")
+
+	_, err = sessions.Refresh(ctx, "unknown", client)
+	assert.True(t, errorx.Is(err, errorx.ErrExpired))
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	other := testutil.CreateTestUser(t, db)
+	sessions := NewSessions(db, "test-secret", 24*time.Hour)
+	ctx := context.Background()
+
+	for _, ua := range []string{"phone", "laptop", "tablet"} {
+		_, err := sessions.Start(ctx, user.ID, ClientInfo{UserAgent: ua})
+		require.NoError(t, err)
+	}
+	_, err := sessions.Start(ctx, other.ID, ClientInfo{})
+	require.NoError(t, err)
+
+	list, err := sessions.List(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	assert.True(t, errorx.Is(sessions.Revoke(ctx, other.ID, list[0].ID), errorx.ErrNotFound), "only the owner can revoke")
+	require.NoError(t, sessions.Revoke(ctx, user.ID, list[0].ID))
+	assert.True(t, errorx.Is(sessions.Revoke(ctx, user.ID, list[0].ID), errorx.ErrNotFound))
+
+	revoked, err := sessions.RevokeAll(ctx, user.ID, list[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+	list, err = sessions.List(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	revoked, err = sessions.RevokeAll(ctx, user.ID, uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+	otherList, err := sessions.List(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Len(t, otherList, 1)
+}
+
+func TestClientInfo_UserAgentKeepsWholeCharacters(t *testing.T) {
+	long := strings.Repeat("é", maxUserAgentLength+10)
+	ua := ClientInfo{UserAgent: long}.userAgent()
+	assert.True(t, utf8.ValidString(ua))
+	assert.Equal(t, maxUserAgentLength, utf8.RuneCountInString(ua))
+
+	assert.Equal(t, "Mozilla", ClientInfo{UserAgent: "Mozilla\xff"}.userAgent())
+}
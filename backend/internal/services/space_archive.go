@@ -0,0 +1,576 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"time"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
+	"lovelion/internal/utils"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// SpaceArchiveVersion is written to every manifest; Import rejects
+	// archives from a newer version.
+	SpaceArchiveVersion  = 1
+	spaceArchiveManifest = "manifest.json"
+	spaceArchiveImageDir = "images/"
+	// maxArchivedImageSize and maxArchiveManifestSize guard against zip
+	// bombs: stored renditions are re-encoded and downscaled, and manifests
+	// are plain rows, so nothing legitimate comes close.
+	maxArchivedImageSize   = 50 * 1024 * 1024
+	maxArchiveManifestSize = 64 * 1024 * 1024
+)
+
+// SpaceArchive is the manifest of an exported space. Rows keep their
+// original IDs so references inside the archive resolve; Import gives every
+// row a new one.
+type SpaceArchive struct {
+	Version      int                      `json:"version"`
+	ExportedAt   time.Time                `json:"exported_at"`
+	Space        ArchivedSpace            `json:"space"`
+	Members      []ArchivedMember         `json:"members"`
+	Transactions []models.Transaction     `json:"transactions"`
+	Templates    []models.ExpenseTemplate `json:"expense_templates"`
+	Stores       []models.ComparisonStore `json:"comparison_stores"`
+	Images       []ArchivedImage          `json:"images"`
+}
+
+// ArchivedSpace holds the space's settings.
+type ArchivedSpace struct {
+	Name           string         `json:"name"`
+	Description    string         `json:"description"`
+	Type           string         `json:"type"`
+	BaseCurrency   string         `json:"base_currency"`
+	Currencies     datatypes.JSON `json:"currencies"`
+	SplitMembers   datatypes.JSON `json:"split_members"`
+	Categories     datatypes.JSON `json:"categories"`
+	PaymentMethods datatypes.JSON `json:"payment_methods"`
+	StartDate      *time.Time     `json:"start_date"`
+	EndDate        *time.Time     `json:"end_date"`
+	AILocale       string         `json:"ai_locale"`
+	AIInstructions string         `json:"ai_instructions"`
+}
+
+// ArchivedMember records who was in the space. Users aren't part of the
+// archive, so only the importer becomes a member of the new space; the list
+// keeps aliases and roles for reference.
+type ArchivedMember struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Role        string    `json:"role"`
+	Alias       string    `json:"alias"`
+	Owner       bool      `json:"owner"`
+}
+
+// ArchivedImage is an Image row plus the archive paths of its files. Thumb
+// and Medium are empty when the image has no such variant. Import only
+// reads File: the original is processed again like any upload, so variants
+// and hashes are rebuilt rather than trusted.
+type ArchivedImage struct {
+	ID             uuid.UUID `json:"id"`
+	EntityType     string    `json:"entity_type"`
+	EntityID       string    `json:"entity_id"`
+	SortOrder      int       `json:"sort_order"`
+	BlurHash       string    `json:"blur_hash"`
+	ContentHash    string    `json:"content_hash,omitempty"`
+	PerceptualHash string    `json:"perceptual_hash,omitempty"`
+	File           string    `json:"file"`
+	Thumb          string    `json:"thumb,omitempty"`
+	Medium         string    `json:"medium,omitempty"`
+}
+
+// SpaceArchiveStorage is the part of storage.Storage export and import need.
+type SpaceArchiveStorage interface {
+	ImageStorage
+	Download(ctx context.Context, key string) ([]byte, string, error)
+}
+
+// SpaceArchiver moves whole spaces in and out as zip archives: a JSON
+// manifest plus every image file under images/.
+type SpaceArchiver struct {
+	db        *gorm.DB
+	store     SpaceArchiveStorage
+	imageOpts imaging.Options
+}
+
+func NewSpaceArchiver(db *gorm.DB, store SpaceArchiveStorage) *SpaceArchiver {
+	return &SpaceArchiver{db: db, store: store}
+}
+
+// WithImageOptions sets how imported originals are downscaled.
+func (a *SpaceArchiver) WithImageOptions(opts imaging.Options) *SpaceArchiver {
+	a.imageOpts = opts
+	return a
+}
+
+// Export writes the archive of spaceID to w: Manifest, then WriteArchive.
+func (a *SpaceArchiver) Export(ctx context.Context, spaceID uuid.UUID, w io.Writer) error {
+	archive, err := a.Manifest(ctx, spaceID)
+	if err != nil {
+		return err
+	}
+	return a.WriteArchive(ctx, archive, w)
+}
+
+// WriteArchive writes a manifest from Manifest, with the image files it
+// names, to w as a zip. Images whose original can't be downloaded are left
+// out rather than failing the whole export.
+func (a *SpaceArchiver) WriteArchive(ctx context.Context, archive *SpaceArchive, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	images := archive.Images[:0]
+	for _, img := range archive.Images {
+		ok, err := a.writeImageFiles(ctx, zw, &img)
+		if err != nil {
+			return err
+		}
+		if ok {
+			images = append(images, img)
+		}
+	}
+	archive.Images = images
+
+	mw, err := zw.Create(spaceArchiveManifest)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(archive); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Manifest loads everything in the space. It does all of an export's
+// database work, so its errors can still become a proper response. Image
+// file fields hold storage keys until WriteArchive replaces them with
+// archive paths.
+func (a *SpaceArchiver) Manifest(ctx context.Context, spaceID uuid.UUID) (*SpaceArchive, error) {
+	db := a.db.WithContext(ctx)
+	var space models.Space
+	if err := db.First(&space, "id = ?", spaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.Wrap(errorx.ErrNotFound, "Space not found")
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load space")
+	}
+	archive := &SpaceArchive{
+		Version:    SpaceArchiveVersion,
+		ExportedAt: time.Now().UTC(),
+		Space: ArchivedSpace{
+			Name:           space.Name,
+			Description:    space.Description,
+			Type:           space.Type,
+			BaseCurrency:   space.BaseCurrency,
+			Currencies:     space.Currencies,
+			SplitMembers:   space.SplitMembers,
+			Categories:     space.Categories,
+			PaymentMethods: space.PaymentMethods,
+			StartDate:      space.StartDate,
+			EndDate:        space.EndDate,
+			AILocale:       space.AILocale,
+			AIInstructions: space.AIInstructions,
+		},
+	}
+
+	var members []models.SpaceMember
+	if err := db.Preload("User").Where("space_id = ?", spaceID).Order("created_at").Find(&members).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load members")
+	}
+	for _, m := range members {
+		am := ArchivedMember{UserID: m.UserID, Role: m.Role, Alias: m.Alias, Owner: m.UserID == space.UserID}
+		if m.User != nil {
+			am.Username, am.DisplayName = m.User.Username, m.User.DisplayName
+		}
+		archive.Members = append(archive.Members, am)
+	}
+
+	if err := db.Preload("Expense.Items").Preload("Debts").
+		Where("space_id = ?", spaceID).Order("date, created_at").
+		Find(&archive.Transactions).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load transactions")
+	}
+	if err := db.Where("space_id = ?", spaceID).Order("created_at").Find(&archive.Templates).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load expense templates")
+	}
+	if err := db.Preload("Products").Where("space_id = ?", spaceID).Order("created_at").Find(&archive.Stores).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load comparison stores")
+	}
+
+	owners := map[string][]string{ImageEntitySpace: {spaceID.String()}}
+	for _, t := range archive.Transactions {
+		owners[ImageEntityTransaction] = append(owners[ImageEntityTransaction], t.ID)
+	}
+	for _, s := range archive.Stores {
+		owners[ImageEntityStore] = append(owners[ImageEntityStore], s.ID)
+		for _, p := range s.Products {
+			owners[ImageEntityProduct] = append(owners[ImageEntityProduct], p.ID.String())
+		}
+	}
+	for _, entityType := range []string{ImageEntitySpace, ImageEntityTransaction, ImageEntityStore, ImageEntityProduct} {
+		ids := owners[entityType]
+		if len(ids) == 0 {
+			continue
+		}
+		var rows []models.Image
+		if err := db.Where("entity_type = ? AND entity_id IN ?", entityType, ids).
+			Order("entity_id, sort_order").Find(&rows).Error; err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load images")
+		}
+		for _, img := range rows {
+			archive.Images = append(archive.Images, ArchivedImage{
+				ID:             img.ID,
+				EntityType:     img.EntityType,
+				EntityID:       img.EntityID,
+				SortOrder:      img.SortOrder,
+				BlurHash:       img.BlurHash,
+				ContentHash:    img.ContentHash,
+				PerceptualHash: img.PerceptualHash,
+				File:           img.FilePath,
+				Thumb:          img.ThumbPath,
+				Medium:         img.MediumPath,
+			})
+		}
+	}
+	return archive, nil
+}
+
+// writeImageFiles copies an image's objects into the zip and points its
+// file fields at them. It reports false when the original is missing; a
+// missing variant is only dropped.
+func (a *SpaceArchiver) writeImageFiles(ctx context.Context, zw *zip.Writer, img *ArchivedImage) (bool, error) {
+	for _, field := range []*string{&img.File, &img.Thumb, &img.Medium} {
+		key := *field
+		if key == "" {
+			continue
+		}
+		data, _, err := a.store.Download(ctx, key)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			slog.Warn("space export: image object missing", "image_id", img.ID, "key", key, "error", err)
+			if field == &img.File {
+				return false, nil
+			}
+			*field = ""
+			continue
+		}
+		name := spaceArchiveImageDir + key
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return false, err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return false, err
+		}
+		*field = name
+	}
+	return true, nil
+}
+
+// Import recreates an archived space as a new space owned by userID. Every
+// row gets a new ID, short IDs included, and references are remapped;
+// transactions created by the archive's owner are attributed to the
+// importer. Nothing is left behind if any part fails.
+func (a *SpaceArchiver) Import(ctx context.Context, userID uuid.UUID, r io.ReaderAt, size int64) (*models.Space, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Archive is not a valid zip file")
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	mf, ok := files[spaceArchiveManifest]
+	if !ok {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Archive has no "+spaceArchiveManifest)
+	}
+	var archive SpaceArchive
+	if err := readArchiveJSON(mf, &archive); err != nil {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Invalid "+spaceArchiveManifest)
+	}
+	if archive.Version < 1 || archive.Version > SpaceArchiveVersion {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, fmt.Sprintf("Unsupported archive version %d", archive.Version))
+	}
+	if archive.Space.Name == "" {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Archive has no space name")
+	}
+
+	im := &spaceImport{archive: &archive, files: files, userID: userID, store: a.store, imageOpts: a.imageOpts}
+	err = a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return im.run(ctx, tx)
+	})
+	if err != nil {
+		cleanupCtx := context.Background()
+		for _, key := range im.uploaded {
+			_ = a.store.Delete(cleanupCtx, key)
+		}
+		var appErr *errorx.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		slog.Error("space import failed", "error", err)
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to import space")
+	}
+	return im.space, nil
+}
+
+// spaceImport holds the state of one Import: the ID maps and the objects
+// uploaded so far, for cleanup.
+type spaceImport struct {
+	archive   *SpaceArchive
+	files     map[string]*zip.File
+	userID    uuid.UUID
+	store     SpaceArchiveStorage
+	imageOpts imaging.Options
+
+	space    *models.Space
+	ownerID  uuid.UUID // archive owner's old user ID
+	txnIDs   map[string]string
+	storeIDs map[string]string
+	prodIDs  map[string]string
+	uploaded []string
+}
+
+func (im *spaceImport) run(ctx context.Context, tx *gorm.DB) error {
+	s := im.archive.Space
+	im.space = &models.Space{
+		ID:             uuid.New(),
+		UserID:         im.userID,
+		Name:           s.Name,
+		Description:    s.Description,
+		Type:           s.Type,
+		BaseCurrency:   s.BaseCurrency,
+		Currencies:     s.Currencies,
+		SplitMembers:   s.SplitMembers,
+		Categories:     s.Categories,
+		PaymentMethods: s.PaymentMethods,
+		StartDate:      s.StartDate,
+		EndDate:        s.EndDate,
+		AILocale:       s.AILocale,
+		AIInstructions: s.AIInstructions,
+	}
+	if im.space.Type == "" {
+		im.space.Type = "personal"
+	}
+	if im.space.BaseCurrency == "" {
+		im.space.BaseCurrency = "TWD"
+	}
+	if im.space.AILocale == "" {
+		im.space.AILocale = DefaultPromptLocale
+	}
+	if err := tx.Create(im.space).Error; err != nil {
+		return err
+	}
+
+	owner := &models.SpaceMember{ID: uuid.New(), SpaceID: im.space.ID, UserID: im.userID, Role: models.SpaceRoleOwner}
+	for _, m := range im.archive.Members {
+		if m.Owner {
+			im.ownerID = m.UserID
+			owner.Alias = m.Alias
+		}
+	}
+	if err := tx.Create(owner).Error; err != nil {
+		return err
+	}
+
+	for _, step := range []func(context.Context, *gorm.DB) error{
+		im.transactions, im.templates, im.stores, im.images,
+	} {
+		if err := step(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *spaceImport) transactions(ctx context.Context, tx *gorm.DB) error {
+	im.txnIDs = make(map[string]string, len(im.archive.Transactions))
+	for _, t := range im.archive.Transactions {
+		newID, err := utils.NewShortID(tx, "transactions", "id")
+		if err != nil {
+			return err
+		}
+		im.txnIDs[t.ID] = newID
+
+		txn := t
+		txn.ID = newID
+		txn.SpaceID = im.space.ID
+		txn.Space, txn.Expense, txn.Debts, txn.Images = nil, nil, nil, nil
+		txn.CreatedBy = nil
+		if t.CreatedBy != nil && *t.CreatedBy == im.ownerID {
+			txn.CreatedBy = &im.userID
+		}
+		// Don't hand half-finished extractions to the AI worker again.
+		if txn.AIStatus != nil && (*txn.AIStatus == aiStatusPending || *txn.AIStatus == aiStatusProcessing) {
+			txn.AIStatus = nil
+		}
+		if err := tx.Omit(clause.Associations).Create(&txn).Error; err != nil {
+			return err
+		}
+
+		if t.Expense != nil {
+			expense := *t.Expense
+			expense.ID = uuid.New()
+			expense.TransactionID = newID
+			expense.Items = nil
+			if err := tx.Omit(clause.Associations).Create(&expense).Error; err != nil {
+				return err
+			}
+			for _, item := range t.Expense.Items {
+				item.ID = uuid.New()
+				item.ExpenseID = expense.ID
+				if err := tx.Create(&item).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for _, debt := range t.Debts {
+			debt.ID = uuid.New()
+			debt.TransactionID = newID
+			if err := tx.Create(&debt).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (im *spaceImport) templates(ctx context.Context, tx *gorm.DB) error {
+	for _, t := range im.archive.Templates {
+		t.ID = uuid.New()
+		t.SpaceID = im.space.ID
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *spaceImport) stores(ctx context.Context, tx *gorm.DB) error {
+	im.storeIDs = map[string]string{}
+	im.prodIDs = map[string]string{}
+	for _, s := range im.archive.Stores {
+		newID, err := utils.NewShortID(tx, "comparison_stores", "id")
+		if err != nil {
+			return err
+		}
+		im.storeIDs[s.ID] = newID
+
+		store := s
+		store.ID = newID
+		store.SpaceID = im.space.ID
+		store.Space, store.Products = nil, nil
+		if err := tx.Omit(clause.Associations).Create(&store).Error; err != nil {
+			return err
+		}
+		for _, p := range s.Products {
+			oldID := p.ID.String()
+			p.ID = uuid.New()
+			p.StoreID = newID
+			p.Store = nil
+			if err := tx.Omit(clause.Associations).Create(&p).Error; err != nil {
+				return err
+			}
+			im.prodIDs[oldID] = p.ID.String()
+		}
+	}
+	return nil
+}
+
+// images runs every archived original through the upload pipeline, so it
+// is decoded, stripped of metadata and gets fresh variants and hashes, then
+// inserts its row. Images of entities that aren't in the archive are
+// skipped.
+func (im *spaceImport) images(ctx context.Context, tx *gorm.DB) error {
+	for _, ai := range im.archive.Images {
+		var entityID string
+		switch ai.EntityType {
+		case ImageEntitySpace:
+			entityID = im.space.ID.String()
+		case ImageEntityTransaction:
+			entityID = im.txnIDs[ai.EntityID]
+		case ImageEntityStore:
+			entityID = im.storeIDs[ai.EntityID]
+		case ImageEntityProduct:
+			entityID = im.prodIDs[ai.EntityID]
+		}
+		if entityID == "" {
+			continue
+		}
+
+		data, err := im.readFile(ai.File)
+		if err != nil {
+			return err
+		}
+		res, err := ProcessImage(data, im.imageOpts)
+		if errorx.Is(err, errorx.ErrBadRequest) {
+			return errorx.Wrap(errorx.ErrBadRequest, "Image file "+ai.File+" is not a valid image")
+		}
+		if err != nil {
+			return err
+		}
+		img, keys, err := StoreImage(ctx, im.store, ai.EntityType, entityID, res)
+		im.uploaded = append(im.uploaded, keys...)
+		if err != nil {
+			return err
+		}
+		img.SortOrder = ai.SortOrder
+		if err := tx.Create(img).Error; err != nil {
+			return err
+		}
+		if img.EntityType == ImageEntitySpace {
+			im.space.Images = append(im.space.Images, *img)
+		}
+	}
+	return nil
+}
+
+// readFile reads one archived image file, refusing anything larger than
+// maxArchivedImageSize whatever its header claims.
+func (im *spaceImport) readFile(name string) ([]byte, error) {
+	f, ok := im.files[path.Clean(name)]
+	if !ok || f.UncompressedSize64 > maxArchivedImageSize {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Archive is missing image file "+name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Cannot read image file "+name)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxArchivedImageSize+1))
+	if err != nil || len(data) > maxArchivedImageSize {
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Cannot read image file "+name)
+	}
+	return data, nil
+}
+
+// readArchiveJSON decodes the manifest, limited to maxArchiveManifestSize
+// both by the size the zip header declares and by what is actually read.
+func readArchiveJSON(f *zip.File, v any) error {
+	if f.UncompressedSize64 > maxArchiveManifestSize {
+		return errors.New("manifest too large")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(io.LimitReader(rc, maxArchiveManifestSize)).Decode(v)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
+	"lovelion/internal/storage"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpaceArchiver_RoundTrip(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	friend := testutil.CreateTestUser(t, db)
+	importer := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: "owner", Alias: "Amy"}).Error)
+	addMember(t, db, space.ID, friend.ID)
+
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080", "test-secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// One pending-AI expense with a photo, created by the owner.
+	txnID := createPendingExpense(t, db, space.ID, "")
+	require.NoError(t, db.Model(&models.Transaction{}).Where("id = ?", txnID).Update("created_by", owner.ID).Error)
+	require.NoError(t, db.Where("entity_id = ?", txnID).Delete(&models.Image{}).Error)
+	res, err := ProcessImage(testPhoto(t, 320, 240), imaging.Options{})
+	require.NoError(t, err)
+	img, _, err := StoreImage(ctx, local, ImageEntityTransaction, txnID, res)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(img).Error)
+	var expense models.TransactionExpense
+	require.NoError(t, db.First(&expense, "transaction_id = ?", txnID).Error)
+	require.NoError(t, db.Create(&models.TransactionExpenseItem{ID: uuid.New(), ExpenseID: expense.ID, Name: "Tea", UnitPrice: decimal.NewFromInt(50), Quantity: decimal.NewFromInt(1), Amount: decimal.NewFromInt(50)}).Error)
+	require.NoError(t, db.Create(&models.TransactionDebt{ID: uuid.New(), TransactionID: txnID, PayerName: "Amy", PayeeName: "Bob", Amount: decimal.NewFromInt(25)}).Error)
+
+	require.NoError(t, db.Create(&models.ExpenseTemplate{ID: uuid.New(), SpaceID: space.ID, Name: "Lunch", Data: models.ExpenseTemplateData{Title: "Lunch"}}).Error)
+	store := &models.ComparisonStore{ID: "store_1", SpaceID: space.ID, Name: "Costco"}
+	require.NoError(t, db.Create(store).Error)
+	require.NoError(t, db.Create(&models.ComparisonProduct{ID: uuid.New(), StoreID: store.ID, Name: "Milk", Price: decimal.NewFromInt(90)}).Error)
+
+	archiver := NewSpaceArchiver(db, local)
+	var buf bytes.Buffer
+	require.NoError(t, archiver.Export(ctx, space.ID, &buf))
+
+	imported, err := archiver.Import(ctx, importer.ID, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.NotEqual(t, space.ID, imported.ID)
+	assert.Equal(t, importer.ID, imported.UserID)
+	assert.Equal(t, space.Name, imported.Name)
+
+	var members []models.SpaceMember
+	require.NoError(t, db.Where("space_id = ?", imported.ID).Find(&members).Error)
+	require.Len(t, members, 1, "only the importer joins the new space")
+	assert.Equal(t, importer.ID, members[0].UserID)
+	assert.Equal(t, "owner", members[0].Role)
+	assert.Equal(t, "Amy", members[0].Alias)
+
+	var txns []models.Transaction
+	require.NoError(t, db.Preload("Expense.Items").Preload("Debts").Preload("Images").
+		Where("space_id = ?", imported.ID).Find(&txns).Error)
+	require.Len(t, txns, 1)
+	txn := txns[0]
+	assert.NotEqual(t, txnID, txn.ID)
+	assert.Nil(t, txn.AIStatus, "pending extraction isn't resumed")
+	require.NotNil(t, txn.CreatedBy)
+	assert.Equal(t, importer.ID, *txn.CreatedBy)
+	require.NotNil(t, txn.Expense)
+	assert.NotEqual(t, expense.ID, txn.Expense.ID)
+	require.Len(t, txn.Expense.Items, 1)
+	assert.Equal(t, "Tea", txn.Expense.Items[0].Name)
+	require.Len(t, txn.Debts, 1)
+	assert.Equal(t, "Bob", txn.Debts[0].PayeeName)
+
+	require.Len(t, txn.Images, 1)
+	copied := txn.Images[0]
+	assert.NotEqual(t, img.ID, copied.ID)
+	assert.NotEmpty(t, copied.ContentHash, "hashes are rebuilt from the archived file")
+	assert.LessOrEqual(t, imaging.HashDistance(img.PerceptualHash, copied.PerceptualHash), 4)
+	assert.Contains(t, copied.FilePath, copied.ID.String())
+	_, err = local.Stat(ctx, copied.ThumbPath)
+	assert.NoError(t, err)
+
+	var stores []models.ComparisonStore
+	require.NoError(t, db.Preload("Products").Where("space_id = ?", imported.ID).Find(&stores).Error)
+	require.Len(t, stores, 1)
+	assert.NotEqual(t, store.ID, stores[0].ID)
+	require.Len(t, stores[0].Products, 1)
+	assert.Equal(t, "Milk", stores[0].Products[0].Name)
+
+	var templates int64
+	db.Model(&models.ExpenseTemplate{}).Where("space_id = ?", imported.ID).Count(&templates)
+	assert.Equal(t, int64(1), templates)
+}
+
+func TestSpaceArchiver_ImportRejectsBadArchives(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	local, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:8080", "test-secret")
+	require.NoError(t, err)
+	archiver := NewSpaceArchiver(db, local)
+	ctx := context.Background()
+
+	junk := []byte("not a zip")
+	_, err = archiver.Import(ctx, user.ID, bytes.NewReader(junk), int64(len(junk)))
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	archive := func(manifest string, files map[string][]byte) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create(spaceArchiveManifest)
+		w.Write([]byte(manifest))
+		for name, data := range files {
+			w, _ := zw.Create(name)
+			w.Write(data)
+		}
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	newer := archive(`{"version": 99, "space": {"name": "X"}}`, nil)
+	_, err = archiver.Import(ctx, user.ID, bytes.NewReader(newer), int64(len(newer)))
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	// A space image that isn't JPEG or PNG rolls the whole import back.
+	bad := archive(`{"version": 1, "exported_at": "`+time.Now().Format(time.RFC3339)+`",
+		"space": {"name": "X"},
+		"images": [{"entity_type": "space", "entity_id": "old", "file": "images/space/a.jpg"}]}`,
+		map[string][]byte{"images/space/a.jpg": []byte("<html>")})
+	_, err = archiver.Import(ctx, user.ID, bytes.NewReader(bad), int64(len(bad)))
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	// So does one that only starts like a JPEG.
+	polyglot := archive(`{"version": 1, "space": {"name": "X"},
+		"images": [{"entity_type": "space", "entity_id": "old", "file": "images/space/a.jpg"}]}`,
+		map[string][]byte{"images/space/a.jpg": append([]byte{0xFF, 0xD8, 0xFF, 0xE0}, "<script>"...)})
+	_, err = archiver.Import(ctx, user.ID, bytes.NewReader(polyglot), int64(len(polyglot)))
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+
+	var count int64
+	db.Model(&models.Space{}).Count(&count)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SpaceLifecycle moves spaces between active, closed and archived, and
+// starts new spaces from old ones.
+type SpaceLifecycle struct {
+	db *gorm.DB
+}
+
+func NewSpaceLifecycle(db *gorm.DB) *SpaceLifecycle {
+	return &SpaceLifecycle{db: db}
+}
+
+// SetStatus changes the space's status. Closing fails with ErrConflict while
+// any balance in the space is still open.
+func (l *SpaceLifecycle) SetStatus(ctx context.Context, spaceID uuid.UUID, status string) (*models.Space, error) {
+	switch status {
+	case models.SpaceStatusActive, models.SpaceStatusClosed, models.SpaceStatusArchived:
+	default:
+		return nil, errorx.Wrap(errorx.ErrBadRequest, "Invalid status")
+	}
+
+	db := l.db.WithContext(ctx)
+	var space models.Space
+	if err := db.First(&space, "id = ?", spaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.Wrap(errorx.ErrNotFound, "Space not found")
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load space")
+	}
+	if space.Status == status {
+		return &space, nil
+	}
+
+	if status == models.SpaceStatusClosed {
+		balances, err := SpaceBalances(ctx, l.db, spaceID)
+		if err != nil {
+			return nil, errorx.Wrap(errorx.ErrInternal, "Failed to compute balances")
+		}
+		for _, b := range balances {
+			if !b.Settled() {
+				return nil, errorx.Wrap(errorx.ErrConflict, "Settle all balances before closing the space")
+			}
+		}
+	}
+
+	if err := db.Model(&space).Update("status", status).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to update space status")
+	}
+	space.Status = status
+	return &space, nil
+}
+
+// Duplicate creates a new active space owned by userID with the settings and
+// expense templates of spaceID, but none of its transactions, comparison
+// data or images. The caller keeps their alias from the original space.
+func (l *SpaceLifecycle) Duplicate(ctx context.Context, userID, spaceID uuid.UUID, name string) (*models.Space, error) {
+	db := l.db.WithContext(ctx)
+	var src models.Space
+	if err := db.First(&src, "id = ?", spaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errorx.Wrap(errorx.ErrNotFound, "Space not found")
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load space")
+	}
+	var member models.SpaceMember
+	if err := db.Where("space_id = ? AND user_id = ?", spaceID, userID).First(&member).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrForbidden, "You do not have access to this space")
+	}
+	var templates []models.ExpenseTemplate
+	if err := db.Where("space_id = ?", spaceID).Order("created_at").Find(&templates).Error; err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to load expense templates")
+	}
+
+	if name == "" {
+		name = src.Name + " (copy)"
+	}
+	space := &models.Space{
+		ID:             uuid.New(),
+		UserID:         userID,
+		Name:           name,
+		Description:    src.Description,
+		Type:           src.Type,
+		BaseCurrency:   src.BaseCurrency,
+		Currencies:     src.Currencies,
+		SplitMembers:   src.SplitMembers,
+		Categories:     src.Categories,
+		PaymentMethods: src.PaymentMethods,
+		AILocale:       src.AILocale,
+		AIInstructions: src.AIInstructions,
+		Status:         models.SpaceStatusActive,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(space).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.SpaceMember{
+			ID:      uuid.New(),
+			SpaceID: space.ID,
+			UserID:  userID,
+			Role:    models.SpaceRoleOwner,
+			Alias:   member.Alias,
+		}).Error; err != nil {
+			return err
+		}
+		for _, t := range templates {
+			t.ID = uuid.New()
+			t.SpaceID = space.ID
+			t.CreatedAt, t.UpdatedAt = time.Time{}, time.Time{}
+			if err := tx.Create(&t).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to duplicate space")
+	}
+	return space, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpaceLifecycle_CloseRequiresSettledBalances(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	txnID := createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+	require.NoError(t, db.Create(&models.TransactionDebt{
+		ID: uuid.New(), TransactionID: txnID, PayerName: "Bob", PayeeName: "Amy",
+		Amount: decimal.NewFromInt(300), SettledAmount: decimal.NewFromInt(300),
+	}).Error)
+
+	lifecycle := NewSpaceLifecycle(db)
+	ctx := context.Background()
+
+	balances, err := SpaceBalances(ctx, db, space.ID)
+	require.NoError(t, err)
+	require.Len(t, balances, 2)
+	assert.Equal(t, "Amy", balances[0].Name)
+	assert.True(t, balances[0].Base.Equal(decimal.NewFromInt(300)))
+
+	_, err = lifecycle.SetStatus(ctx, space.ID, models.SpaceStatusClosed)
+	assert.True(t, errorx.Is(err, errorx.ErrConflict))
+
+	// Bob pays Amy back.
+	payment := &models.Transaction{ID: "pay_1", SpaceID: space.ID, Type: "payment", Title: "Repay", Currency: "TWD"}
+	require.NoError(t, db.Create(payment).Error)
+	require.NoError(t, db.Create(&models.TransactionDebt{
+		ID: uuid.New(), TransactionID: payment.ID, PayerName: "Amy", PayeeName: "Bob",
+		Amount: decimal.NewFromInt(300), SettledAmount: decimal.NewFromInt(300),
+	}).Error)
+
+	closed, err := lifecycle.SetStatus(ctx, space.ID, models.SpaceStatusClosed)
+	require.NoError(t, err)
+	assert.Equal(t, models.SpaceStatusClosed, closed.Status)
+	assert.False(t, closed.Writable())
+
+	reopened, err := lifecycle.SetStatus(ctx, space.ID, models.SpaceStatusActive)
+	require.NoError(t, err)
+	assert.True(t, reopened.Writable())
+
+	_, err = lifecycle.SetStatus(ctx, space.ID, "deleted")
+	assert.True(t, errorx.Is(err, errorx.ErrBadRequest))
+}
+
+func TestSpaceLifecycle_DuplicateCopiesSettingsOnly(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	member := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Model(space).Updates(map[string]interface{}{
+		"categories": `["Food","Hotel"]`, "split_members": `["Amy","Bob"]`, "status": models.SpaceStatusClosed,
+	}).Error)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: member.ID, Role: models.SpaceRoleViewer, Alias: "Bob"}).Error)
+	createPendingExpense(t, db, space.ID, "transaction/a.jpg")
+	require.NoError(t, db.Create(&models.ExpenseTemplate{ID: uuid.New(), SpaceID: space.ID, Name: "Hotel", Data: models.ExpenseTemplateData{Title: "Hotel"}}).Error)
+
+	copied, err := NewSpaceLifecycle(db).Duplicate(context.Background(), member.ID, space.ID, "Trip 2027")
+	require.NoError(t, err)
+	assert.Equal(t, "Trip 2027", copied.Name)
+	assert.Equal(t, member.ID, copied.UserID)
+	assert.Equal(t, models.SpaceStatusActive, copied.Status)
+	assert.JSONEq(t, `["Food","Hotel"]`, string(copied.Categories))
+	assert.JSONEq(t, `["Amy","Bob"]`, string(copied.SplitMembers))
+
+	var owner2 models.SpaceMember
+	require.NoError(t, db.Where("space_id = ?", copied.ID).First(&owner2).Error)
+	assert.Equal(t, models.SpaceRoleOwner, owner2.Role)
+	assert.Equal(t, "Bob", owner2.Alias)
+
+	var txns, templates int64
+	db.Model(&models.Transaction{}).Where("space_id = ?", copied.ID).Count(&txns)
+	db.Model(&models.ExpenseTemplate{}).Where("space_id = ?", copied.ID).Count(&templates)
+	assert.Zero(t, txns)
+	assert.Equal(t, int64(1), templates)
+}
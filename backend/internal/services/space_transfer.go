@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"lovelion/internal/models"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpaceTransfers hands a space from its owner to another member. The owner
+// nominates a member, which only records Space.PendingOwnerID; nothing
+// changes hands until that member accepts.
+type SpaceTransfers struct {
+	db *gorm.DB
+}
+
+func NewSpaceTransfers(db *gorm.DB) *SpaceTransfers {
+	return &SpaceTransfers{db: db}
+}
+
+// Nominate offers the space to targetID, replacing any earlier offer.
+func (s *SpaceTransfers) Nominate(ctx context.Context, spaceID, ownerID, targetID uuid.UUID) error {
+	if targetID == ownerID {
+		return errorx.Wrap(errorx.ErrBadRequest, "You already own this space")
+	}
+	db := s.db.WithContext(ctx)
+	var member models.SpaceMember
+	err := db.Where("space_id = ? AND user_id = ?", spaceID, targetID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errorx.Wrap(errorx.ErrNotFound, "Member not found in this space")
+	}
+	if err != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to load member")
+	}
+	if err := db.Model(&models.Space{}).Where("id = ?", spaceID).
+		Update("pending_owner_id", targetID).Error; err != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to nominate new owner")
+	}
+	return nil
+}
+
+// Cancel withdraws the owner's offer.
+func (s *SpaceTransfers) Cancel(ctx context.Context, spaceID uuid.UUID) error {
+	res := s.db.WithContext(ctx).Model(&models.Space{}).
+		Where("id = ? AND pending_owner_id IS NOT NULL", spaceID).
+		Update("pending_owner_id", nil)
+	if res.Error != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to cancel transfer")
+	}
+	if res.RowsAffected == 0 {
+		return errorx.Wrap(errorx.ErrNotFound, "No ownership transfer is pending")
+	}
+	return nil
+}
+
+// Decline turns down an offer made to userID.
+func (s *SpaceTransfers) Decline(ctx context.Context, spaceID, userID uuid.UUID) error {
+	res := s.db.WithContext(ctx).Model(&models.Space{}).
+		Where("id = ? AND pending_owner_id = ?", spaceID, userID).
+		Update("pending_owner_id", nil)
+	if res.Error != nil {
+		return errorx.Wrap(errorx.ErrInternal, "Failed to decline transfer")
+	}
+	if res.RowsAffected == 0 {
+		return errorx.Wrap(errorx.ErrNotFound, "No ownership transfer is pending for you")
+	}
+	return nil
+}
+
+// Accept makes userID the owner if the space was offered to them. The space
+// row is locked so the owner can't withdraw or redirect the offer halfway;
+// Space.UserID and both members' roles change in one transaction, and the
+// previous owner stays on as an editor.
+func (s *SpaceTransfers) Accept(ctx context.Context, spaceID, userID uuid.UUID) (*models.Space, error) {
+	var space models.Space
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&space, "id = ?", spaceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errorx.Wrap(errorx.ErrNotFound, "Space not found")
+			}
+			return err
+		}
+		if space.PendingOwnerID == nil || *space.PendingOwnerID != userID {
+			return errorx.Wrap(errorx.ErrNotFound, "No ownership transfer is pending for you")
+		}
+		previousOwner := space.UserID
+
+		res := tx.Model(&models.SpaceMember{}).
+			Where("space_id = ? AND user_id = ?", spaceID, userID).
+			Update("role", models.SpaceRoleOwner)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// The nominee left or was removed since the offer was made.
+			return errorx.Wrap(errorx.ErrConflict, "You are no longer a member of this space")
+		}
+		if err := tx.Model(&models.SpaceMember{}).
+			Where("space_id = ? AND user_id = ?", spaceID, previousOwner).
+			Update("role", models.SpaceRoleEditor).Error; err != nil {
+			return err
+		}
+
+		space.UserID = userID
+		space.PendingOwnerID = nil
+		return tx.Model(&space).Select("user_id", "pending_owner_id").Updates(&space).Error
+	})
+	if err != nil {
+		var appErr *errorx.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to transfer ownership")
+	}
+	return &space, nil
+}
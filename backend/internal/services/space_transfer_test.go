@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"lovelion/internal/models"
+	"lovelion/internal/repositories"
+	"lovelion/internal/testutil"
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpaceTransfers_AcceptSwapsOwner(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	heir := testutil.CreateTestUser(t, db)
+	other := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: models.SpaceRoleOwner}).Error)
+	addMember(t, db, space.ID, heir.ID)
+	addMember(t, db, space.ID, other.ID)
+
+	transfers := NewSpaceTransfers(db)
+	ctx := context.Background()
+
+	outsider := testutil.CreateTestUser(t, db)
+	assert.True(t, errorx.Is(transfers.Nominate(ctx, space.ID, owner.ID, outsider.ID), errorx.ErrNotFound))
+	require.NoError(t, transfers.Nominate(ctx, space.ID, owner.ID, heir.ID))
+
+	_, err := transfers.Accept(ctx, space.ID, other.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound), "only the nominee can accept")
+
+	updated, err := transfers.Accept(ctx, space.ID, heir.ID)
+	require.NoError(t, err)
+	assert.Equal(t, heir.ID, updated.UserID)
+	assert.Nil(t, updated.PendingOwnerID)
+
+	roles := map[uuid.UUID]string{}
+	var members []models.SpaceMember
+	require.NoError(t, db.Where("space_id = ?", space.ID).Find(&members).Error)
+	for _, m := range members {
+		roles[m.UserID] = m.Role
+	}
+	assert.Equal(t, models.SpaceRoleOwner, roles[heir.ID])
+	assert.Equal(t, models.SpaceRoleEditor, roles[owner.ID])
+
+	_, err = transfers.Accept(ctx, space.ID, heir.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound), "an offer is accepted once")
+}
+
+func TestSpaceTransfers_DeclineAndCancel(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	heir := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: models.SpaceRoleOwner}).Error)
+	addMember(t, db, space.ID, heir.ID)
+
+	transfers := NewSpaceTransfers(db)
+	ctx := context.Background()
+
+	require.NoError(t, transfers.Nominate(ctx, space.ID, owner.ID, heir.ID))
+	require.NoError(t, transfers.Decline(ctx, space.ID, heir.ID))
+	assert.True(t, errorx.Is(transfers.Cancel(ctx, space.ID), errorx.ErrNotFound))
+
+	require.NoError(t, transfers.Nominate(ctx, space.ID, owner.ID, heir.ID))
+	require.NoError(t, transfers.Cancel(ctx, space.ID))
+	_, err := transfers.Accept(ctx, space.ID, heir.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound))
+
+	// A nominee who left can't take over.
+	require.NoError(t, transfers.Nominate(ctx, space.ID, owner.ID, heir.ID))
+	require.NoError(t, db.Where("space_id = ? AND user_id = ?", space.ID, heir.ID).Delete(&models.SpaceMember{}).Error)
+	_, err = transfers.Accept(ctx, space.ID, heir.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrConflict))
+	var reloaded models.Space
+	require.NoError(t, db.First(&reloaded, "id = ?", space.ID).Error)
+	assert.Equal(t, owner.ID, reloaded.UserID)
+}
+
+func TestSpaceTransfers_MemberChangesWithdrawOffer(t *testing.T) {
+	db := testutil.TestDB(t)
+	owner := testutil.CreateTestUser(t, db)
+	heir := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, owner.ID)
+	require.NoError(t, db.Create(&models.SpaceMember{ID: uuid.New(), SpaceID: space.ID, UserID: owner.ID, Role: models.SpaceRoleOwner}).Error)
+	addMember(t, db, space.ID, heir.ID)
+
+	transfers := NewSpaceTransfers(db)
+	members := repositories.NewMemberRepo(db)
+	ctx := context.Background()
+	pending := func() *uuid.UUID {
+		var reloaded models.Space
+		require.NoError(t, db.First(&reloaded, "id = ?", space.ID).Error)
+		return reloaded.PendingOwnerID
+	}
+
+	require.NoError(t, transfers.Nominate(ctx, space.ID, owner.ID, heir.ID))
+	_, err := members.UpdateRole(ctx, space.ID, heir.ID, models.SpaceRoleViewer)
+	require.NoError(t, err)
+	assert.Nil(t, pending(), "a role change withdraws the offer")
+
+	// Removed and invited back: the old offer must not carry over.
+	require.NoError(t, transfers.Nominate(ctx, space.ID, owner.ID, heir.ID))
+	_, err = members.Delete(ctx, space.ID, heir.ID)
+	require.NoError(t, err)
+	assert.Nil(t, pending())
+	addMember(t, db, space.ID, heir.ID)
+	_, err = transfers.Accept(ctx, space.ID, heir.ID)
+	assert.True(t, errorx.Is(err, errorx.ErrNotFound))
+}
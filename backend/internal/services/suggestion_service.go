@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"lovelion/internal/utils/errorx"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SuggestionService learns category and payment-method mappings from a
+// space's own history. Mappings are computed on the fly from stored expenses,
+// so a user's corrections take effect on the next lookup without any
+// training step.
+type SuggestionService struct {
+	db *gorm.DB
+}
+
+func NewSuggestionService(db *gorm.DB) *SuggestionService {
+	return &SuggestionService{db: db}
+}
+
+// SuggestQuery describes the expense being filled in.
+type SuggestQuery struct {
+	Title        string   // merchant / transaction title
+	ItemNames    []string // used for category when the title has no history
+	ExcludeTxnID string   // the row being filled in, so it can't vote for itself
+}
+
+// Suggestion is the learned category and payment method. Empty fields mean
+// the space has no history for the query.
+type Suggestion struct {
+	Category      string `json:"category"`
+	PaymentMethod string `json:"payment_method"`
+}
+
+// learnedValue is one grouped row of a history query.
+type learnedValue struct {
+	Value string
+	Hits  int
+}
+
+// Suggest returns the most frequent category and payment method previously
+// used for the same title (case-insensitive), falling back to the category
+// most often given to the same item names. Ties go to the most recent use.
+// Rows still owned by the AI worker are ignored.
+func (s *SuggestionService) Suggest(ctx context.Context, spaceID uuid.UUID, q SuggestQuery) (Suggestion, error) {
+	var out Suggestion
+	title := normalizeSuggestKey(q.Title)
+
+	if title != "" {
+		category, err := s.mostUsedByTitle(ctx, spaceID, title, "category", q.ExcludeTxnID)
+		if err != nil {
+			return Suggestion{}, errorx.Wrap(errorx.ErrInternal, "Failed to load suggestions")
+		}
+		out.Category = category
+
+		method, err := s.mostUsedByTitle(ctx, spaceID, title, "payment_method", q.ExcludeTxnID)
+		if err != nil {
+			return Suggestion{}, errorx.Wrap(errorx.ErrInternal, "Failed to load suggestions")
+		}
+		out.PaymentMethod = method
+	}
+
+	if out.Category == "" {
+		var names []string
+		for _, n := range q.ItemNames {
+			if key := normalizeSuggestKey(n); key != "" {
+				names = append(names, key)
+			}
+		}
+		if len(names) > 0 {
+			category, err := s.mostUsedByItems(ctx, spaceID, names, q.ExcludeTxnID)
+			if err != nil {
+				return Suggestion{}, errorx.Wrap(errorx.ErrInternal, "Failed to load suggestions")
+			}
+			out.Category = category
+		}
+	}
+
+	return out, nil
+}
+
+// mostUsedByTitle groups past expenses with the given title by column
+// ("category" or "payment_method") and returns the winner.
+func (s *SuggestionService) mostUsedByTitle(ctx context.Context, spaceID uuid.UUID, title, column, excludeTxnID string) (string, error) {
+	var rows []learnedValue
+	err := s.historyScope(ctx, spaceID, excludeTxnID).
+		Select("transaction_expenses."+column+" AS value, COUNT(*) AS hits").
+		Where("LOWER(TRIM(transactions.title)) = ?", title).
+		Where("transaction_expenses." + column + " <> ''").
+		Group("transaction_expenses." + column).
+		Order("hits DESC, MAX(transactions.date) DESC").
+		Limit(1).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return "", err
+	}
+	return rows[0].Value, nil
+}
+
+// mostUsedByItems returns the category most often given to expenses that
+// contained any of the item names.
+func (s *SuggestionService) mostUsedByItems(ctx context.Context, spaceID uuid.UUID, names []string, excludeTxnID string) (string, error) {
+	var rows []learnedValue
+	err := s.historyScope(ctx, spaceID, excludeTxnID).
+		Joins("JOIN transaction_expense_items ON transaction_expense_items.expense_id = transaction_expenses.id").
+		Select("transaction_expenses.category AS value, COUNT(*) AS hits").
+		Where("LOWER(TRIM(transaction_expense_items.name)) IN ?", names).
+		Where("transaction_expenses.category <> ''").
+		Group("transaction_expenses.category").
+		Order("hits DESC, MAX(transactions.date) DESC").
+		Limit(1).
+		Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return "", err
+	}
+	return rows[0].Value, nil
+}
+
+// historyScope selects the space's settled expenses joined to their detail row.
+func (s *SuggestionService) historyScope(ctx context.Context, spaceID uuid.UUID, excludeTxnID string) *gorm.DB {
+	q := s.db.WithContext(ctx).
+		Table("transactions").
+		Joins("JOIN transaction_expenses ON transaction_expenses.transaction_id = transactions.id").
+		Where("transactions.space_id = ? AND transactions.type = ?", spaceID, "expense").
+		Where("(transactions.ai_status IS NULL OR transactions.ai_status NOT IN ?)", []string{aiStatusPending, aiStatusProcessing})
+	if excludeTxnID != "" {
+		q = q.Where("transactions.id <> ?", excludeTxnID)
+	}
+	return q
+}
+
+func normalizeSuggestKey(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
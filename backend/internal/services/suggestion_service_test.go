@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lovelion/internal/models"
+	"lovelion/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createHistoryExpense inserts a settled expense with one item.
+func createHistoryExpense(t *testing.T, db *gorm.DB, spaceID uuid.UUID, title, category, method, item string, date time.Time) {
+	t.Helper()
+	txnID := "txn_" + uuid.NewString()[:8]
+	require.NoError(t, db.Create(&models.Transaction{
+		ID: txnID, SpaceID: spaceID, Type: "expense", Title: title,
+		Date: date, Currency: "TWD", TotalAmount: decimal.NewFromInt(100),
+	}).Error)
+	expenseID := uuid.New()
+	require.NoError(t, db.Create(&models.TransactionExpense{
+		ID: expenseID, TransactionID: txnID, Category: category, PaymentMethod: method,
+		ExchangeRate: decimal.NewFromInt(1),
+	}).Error)
+	require.NoError(t, db.Create(&models.TransactionExpenseItem{
+		ID: uuid.New(), ExpenseID: expenseID, Name: item,
+		UnitPrice: decimal.NewFromInt(100), Quantity: decimal.NewFromInt(1), Amount: decimal.NewFromInt(100),
+	}).Error)
+}
+
+func TestSuggestionService_TitleHistory(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+	now := time.Now()
+
+	createHistoryExpense(t, db, space.ID, "全聯", "購物", "現金", "牛奶", now.AddDate(0, 0, -3))
+	createHistoryExpense(t, db, space.ID, "全聯", "生活", "信用卡", "衛生紙", now.AddDate(0, 0, -2))
+	createHistoryExpense(t, db, space.ID, "全聯", "生活", "信用卡", "洗衣精", now.AddDate(0, 0, -1))
+
+	svc := NewSuggestionService(db)
+	got, err := svc.Suggest(context.Background(), space.ID, SuggestQuery{Title: " 全聯 "})
+	require.NoError(t, err)
+	assert.Equal(t, "生活", got.Category)
+	assert.Equal(t, "信用卡", got.PaymentMethod)
+
+	// Other spaces don't leak in.
+	other := createTestSpace(t, db, user.ID)
+	got, err = svc.Suggest(context.Background(), other.ID, SuggestQuery{Title: "全聯"})
+	require.NoError(t, err)
+	assert.Equal(t, Suggestion{}, got)
+}
+
+func TestSuggestionService_ItemFallback(t *testing.T) {
+	db := testutil.TestDB(t)
+	user := testutil.CreateTestUser(t, db)
+	space := createTestSpace(t, db, user.ID)
+
+	createHistoryExpense(t, db, space.ID, "星巴克", "飲料", "", "Latte", time.Now())
+
+	got, err := NewSuggestionService(db).Suggest(context.Background(), space.ID, SuggestQuery{
+		Title:     "路易莎",
+		ItemNames: []string{"latte"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "飲料", got.Category)
+	assert.Empty(t, got.PaymentMethod)
+}
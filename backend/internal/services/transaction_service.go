@@ -1,16 +1,17 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"lovelion/internal/events"
+	"lovelion/internal/imaging"
 	"lovelion/internal/models"
 	"lovelion/internal/repositories"
 	"lovelion/internal/utils"
@@ -28,6 +29,12 @@ type ImageStorage interface {
 	Delete(ctx context.Context, key string) error
 }
 
+// EventPublisher receives change notifications once a write has committed.
+// *events.Bus implements it; nil disables publishing.
+type EventPublisher interface {
+	Publish(e events.Event)
+}
+
 type TransactionService struct {
 	db          *gorm.DB
 	txnRepo     *repositories.TransactionRepo
@@ -35,6 +42,10 @@ type TransactionService struct {
 	itemRepo    *repositories.TransactionExpenseItemRepo
 	debtRepo    *repositories.TransactionDebtRepo
 	storage     ImageStorage // optional — nil means image-bearing flows are rejected
+	publisher   EventPublisher
+	imageURLs   *ImageURLSigner
+	imageOpts   imaging.Options
+	uploads     *DirectUploads
 }
 
 func NewTransactionService(
@@ -55,6 +66,39 @@ func NewTransactionService(
 	}
 }
 
+// WithEvents enables change notifications for SSE clients.
+func (s *TransactionService) WithEvents(p EventPublisher) *TransactionService {
+	s.publisher = p
+	return s
+}
+
+// WithImageURLs signs image URLs on every transaction the service returns.
+func (s *TransactionService) WithImageURLs(signer *ImageURLSigner) *TransactionService {
+	s.imageURLs = signer
+	return s
+}
+
+// WithImageOptions sets how uploaded originals are downscaled.
+func (s *TransactionService) WithImageOptions(opts imaging.Options) *TransactionService {
+	s.imageOpts = opts
+	return s
+}
+
+// WithDirectUploads lets CreateExpense attach images the client uploaded
+// straight to storage.
+func (s *TransactionService) WithDirectUploads(u *DirectUploads) *TransactionService {
+	s.uploads = u
+	return s
+}
+
+// publish is a no-op when no publisher is configured.
+func (s *TransactionService) publish(eventType string, spaceID uuid.UUID, txnID string) {
+	if s.publisher == nil {
+		return
+	}
+	s.publisher.Publish(events.Event{Type: eventType, SpaceID: spaceID, TransactionID: txnID})
+}
+
 // --- Input types ---
 
 type ExpenseItemInput struct {
@@ -70,6 +114,7 @@ type ExpenseInput struct {
 	BillingAmount decimal.Decimal
 	HandlingFee   decimal.Decimal
 	PaymentMethod string
+	LocationURL   string
 	Items         []ExpenseItemInput
 }
 
@@ -81,10 +126,7 @@ type DebtInput struct {
 }
 
 type ImageUpload struct {
-	FileName    string // original upload name, used for extension
-	Body        []byte
-	ContentType string
-	BlurHash    string
+	Body []byte // raw upload; the format is sniffed from the content
 }
 
 type CreateExpenseInput struct {
@@ -96,7 +138,14 @@ type CreateExpenseInput struct {
 	Expense     ExpenseInput
 	Debts       []DebtInput
 	Images      []ImageUpload // optional — uploaded to R2 in the same tx
-	AIExtract   bool          // when true, ai_status is set to pending for worker pickup
+	// UploadIDs are direct uploads (see DirectUploads) of CreatedBy to
+	// attach after Images, in order.
+	UploadIDs []uuid.UUID
+	AIExtract bool      // when true, ai_status is set to pending for worker pickup
+	CreatedBy uuid.UUID // uuid.Nil when unknown; lets the AI worker resolve "我"
+	// UsePhotoMetadata takes the date and, when none was given, the
+	// location from the first image whose EXIF has them.
+	UsePhotoMetadata bool
 }
 
 type UpdateExpenseInput struct {
@@ -119,6 +168,7 @@ type CreatePaymentInput struct {
 	TotalAmount decimal.Decimal
 	PayerName   string
 	PayeeName   string
+	CreatedBy   uuid.UUID
 }
 
 type UpdatePaymentInput struct {
@@ -211,6 +261,7 @@ func (s *TransactionService) List(ctx context.Context, spaceID uuid.UUID) ([]mod
 	if err != nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to fetch transactions")
 	}
+	s.imageURLs.Transactions(ctx, transactions)
 	return transactions, nil
 }
 
@@ -219,6 +270,7 @@ func (s *TransactionService) ListPaginated(ctx context.Context, spaceID uuid.UUI
 	if err != nil {
 		return nil, 0, errorx.Wrap(errorx.ErrInternal, "Failed to fetch transactions")
 	}
+	s.imageURLs.Transactions(ctx, transactions)
 	return transactions, total, nil
 }
 
@@ -230,6 +282,17 @@ func (s *TransactionService) GetByID(ctx context.Context, txnID string, spaceID
 		}
 		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to fetch transaction")
 	}
+	s.imageURLs.Transaction(ctx, txn)
+	return txn, nil
+}
+
+// reload fetches a transaction after a write, with signed image URLs.
+func (s *TransactionService) reload(ctx context.Context, txnID string, spaceID uuid.UUID) (*models.Transaction, error) {
+	txn, err := s.txnRepo.FindByID(ctx, txnID, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	s.imageURLs.Transaction(ctx, txn)
 	return txn, nil
 }
 
@@ -241,6 +304,7 @@ func (s *TransactionService) Delete(ctx context.Context, txnID string, spaceID u
 	if rows == 0 {
 		return errorx.Wrap(errorx.ErrNotFound, "Transaction not found")
 	}
+	s.publish(events.TypeTransactionDeleted, spaceID, txnID)
 	return nil
 }
 
@@ -279,6 +343,9 @@ func (s *TransactionService) CreateExpense(ctx context.Context, spaceID uuid.UUI
 		TotalAmount: totalAmount,
 		Note:        input.Note,
 	}
+	if input.CreatedBy != uuid.Nil {
+		txn.CreatedBy = &input.CreatedBy
+	}
 
 	if input.Date != nil {
 		txn.Date = *input.Date
@@ -294,17 +361,38 @@ func (s *TransactionService) CreateExpense(ctx context.Context, spaceID uuid.UUI
 		BillingAmount: input.Expense.BillingAmount,
 		HandlingFee:   input.Expense.HandlingFee,
 		PaymentMethod: input.Expense.PaymentMethod,
+		LocationURL:   input.Expense.LocationURL,
 	}
 
 	debts := buildDebts(txnID, input.Debts, totalAmount, &input.Expense, currency)
 
 	// Validate image-bearing input before doing any DB work.
-	if len(input.Images) > 0 && s.storage == nil {
+	imageCount := len(input.Images) + len(input.UploadIDs)
+	if imageCount > 0 && s.storage == nil {
 		return nil, errorx.Wrap(errorx.ErrInternal, "Image storage not configured")
 	}
-	if input.AIExtract && len(input.Images) == 0 && strings.TrimSpace(input.Title) == "" {
+	if len(input.UploadIDs) > 0 && s.uploads == nil {
+		return nil, errorx.Wrap(errorx.ErrInternal, "Direct uploads not configured")
+	}
+	if input.AIExtract && imageCount == 0 && strings.TrimSpace(input.Title) == "" {
 		return nil, errorx.Wrap(errorx.ErrBadRequest, "AI extraction requires an image or text")
 	}
+	processed, err := s.processImages(input.Images)
+	if err != nil {
+		return nil, err
+	}
+	var staged []*models.PendingUpload
+	if len(input.UploadIDs) > 0 {
+		var results []*imaging.Result
+		staged, results, err = s.uploads.takeForExpense(ctx, input.CreatedBy, spaceID, input.UploadIDs)
+		if err != nil {
+			return nil, err
+		}
+		processed = append(processed, results...)
+	}
+	if input.UsePhotoMetadata {
+		applyPhotoMetadata(txn, expense, processed)
+	}
 
 	// Keys of objects written to R2 so we can clean them up if the DB tx rolls back.
 	var uploadedKeys []string
@@ -333,17 +421,21 @@ func (s *TransactionService) CreateExpense(ctx context.Context, spaceID uuid.UUI
 		}
 
 		// Upload images to R2 and insert image records under the same entity_id.
-		for i, img := range input.Images {
-			_, key, err := s.uploadImageForTransaction(ctx, tx, txnID, i, img)
-			if key != "" {
-				// Track the key for rollback even if the DB insert failed.
-				uploadedKeys = append(uploadedKeys, key)
-			}
+		for i, img := range processed {
+			_, keys, err := s.uploadImageForTransaction(ctx, tx, txnID, i, img)
+			// Track the keys for rollback even if the DB insert failed.
+			uploadedKeys = append(uploadedKeys, keys...)
 			if err != nil {
 				return err
 			}
 		}
 
+		if len(input.UploadIDs) > 0 {
+			if err := consumePendingUploads(tx, input.UploadIDs); err != nil {
+				return err
+			}
+		}
+
 		// Set ai_status=pending so the worker will pick the row up.
 		if input.AIExtract {
 			pending := aiStatusPending
@@ -370,46 +462,81 @@ func (s *TransactionService) CreateExpense(ctx context.Context, spaceID uuid.UUI
 		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to create expense")
 	}
 
-	return s.txnRepo.FindByID(ctx, txnID, spaceID)
+	for _, p := range staged {
+		s.uploads.deleteKeys([]string{p.Key})
+	}
+	s.publish(events.TypeTransactionCreated, spaceID, txnID)
+	created, err := s.reload(ctx, txnID, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	// A duplicate is only a warning; the expense is created either way.
+	dups, err := FindDuplicateImages(ctx, s.db, spaceID, txnID, processed)
+	if err != nil {
+		slog.Warn("duplicate image lookup failed", "txn_id", txnID, "error", err)
+	}
+	created.Duplicates = dups
+	return created, nil
+}
+
+// processImages validates and decodes every upload before any DB or storage
+// work, so a bad file fails the request without side effects.
+func (s *TransactionService) processImages(images []ImageUpload) ([]*imaging.Result, error) {
+	results := make([]*imaging.Result, 0, len(images))
+	for _, img := range images {
+		res, err := ProcessImage(img.Body, s.imageOpts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// applyPhotoMetadata copies the capture time and GPS position read from the
+// photos' EXIF onto a new expense. The first photo carrying each value wins;
+// a location typed by the user is kept.
+func applyPhotoMetadata(txn *models.Transaction, expense *models.TransactionExpense, images []*imaging.Result) {
+	var takenAt *time.Time
+	var gps *imaging.LatLng
+	for _, img := range images {
+		if takenAt == nil {
+			takenAt = img.Metadata.TakenAt
+		}
+		if gps == nil {
+			gps = img.Metadata.GPS
+		}
+	}
+	if takenAt != nil {
+		txn.Date = *takenAt
+	}
+	if gps != nil && expense.LocationURL == "" {
+		expense.LocationURL = fmt.Sprintf("https://www.google.com/maps?q=%.6f,%.6f", gps.Lat, gps.Lng)
+	}
 }
 
-// uploadImageForTransaction uploads a single image to R2 and inserts an image
-// record bound to the transaction. On any error the caller is responsible for
-// rolling back both the DB tx and the R2 object (the key is returned so the
-// caller can add it to a cleanup list before returning).
+// uploadImageForTransaction uploads a single processed image and its resized
+// variants and inserts an image record bound to the transaction. On any
+// error the caller is responsible for rolling back both the DB tx and the
+// stored objects (the keys are returned so the caller can add them to a
+// cleanup list before returning).
 func (s *TransactionService) uploadImageForTransaction(
 	ctx context.Context,
 	tx *gorm.DB,
 	txnID string,
 	sortOrder int,
-	img ImageUpload,
-) (*models.Image, string, error) {
-	ext := strings.ToLower(filepath.Ext(img.FileName))
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-		return nil, "", errorx.Wrap(errorx.ErrBadRequest, "Only jpg, jpeg, and png are allowed")
-	}
-
-	fileID := uuid.New()
-	key := fmt.Sprintf("transaction/%s%s", fileID.String(), ext)
-
-	url, err := s.storage.Upload(ctx, key, bytes.NewReader(img.Body), img.ContentType)
+	img *imaging.Result,
+) (*models.Image, []string, error) {
+	record, keys, err := StoreImage(ctx, s.storage, ImageEntityTransaction, txnID, img)
 	if err != nil {
-		return nil, "", errorx.Wrap(errorx.ErrInternal, "Failed to upload image")
-	}
-
-	record := &models.Image{
-		ID:         fileID,
-		EntityID:   txnID,
-		EntityType: "transaction",
-		FilePath:   url,
-		BlurHash:   img.BlurHash,
-		SortOrder:  sortOrder,
+		return nil, keys, err
 	}
+	record.SortOrder = sortOrder
 	if err := tx.Create(record).Error; err != nil {
-		// Return the key so caller can delete the uploaded object.
-		return nil, key, errorx.Wrap(errorx.ErrInternal, "Failed to save image record")
+		// Return the keys so caller can delete the uploaded objects.
+		return nil, keys, errorx.Wrap(errorx.ErrInternal, "Failed to save image record")
 	}
-	return record, key, nil
+	return record, keys, nil
 }
 
 func (s *TransactionService) UpdateExpense(ctx context.Context, txnID string, spaceID uuid.UUID, input UpdateExpenseInput) (*models.Transaction, error) {
@@ -491,6 +618,7 @@ func (s *TransactionService) UpdateExpense(ctx context.Context, txnID string, sp
 			BillingAmount: &input.Expense.BillingAmount,
 			HandlingFee:   &input.Expense.HandlingFee,
 			PaymentMethod: &input.Expense.PaymentMethod,
+			LocationURL:   &input.Expense.LocationURL,
 		}
 		if err := expenseRepo.Update(ctx, txnID, expenseParams); err != nil {
 			return err
@@ -515,9 +643,20 @@ func (s *TransactionService) UpdateExpense(ctx context.Context, txnID string, sp
 			return err
 		}
 
+		// Saving means the user has reviewed the debts and currency, so any
+		// pending AI split or currency suggestion is resolved.
+		if err := tx.Model(&models.Transaction{}).
+			Where("id = ? AND (ai_split_suggestion IS NOT NULL OR ai_currency_suggestion IS NOT NULL)", txnID).
+			Updates(map[string]interface{}{
+				"ai_split_suggestion":    gorm.Expr("NULL"),
+				"ai_currency_suggestion": gorm.Expr("NULL"),
+			}).Error; err != nil {
+			return err
+		}
+
 		// ai_status transitions:
 		//   ai_extract=true  → pending (worker picks up)
-		//   failed + ai_extract=false → NULL (user editing manually)
+		//   failed/skipped + ai_extract=false → NULL (user editing manually)
 		if input.AIExtract {
 			if err := tx.Model(&models.Transaction{}).
 				Where("id = ?", txnID).
@@ -527,7 +666,7 @@ func (s *TransactionService) UpdateExpense(ctx context.Context, txnID string, sp
 				}).Error; err != nil {
 				return err
 			}
-		} else if currentAIStatus == aiStatusFailed {
+		} else if currentAIStatus == aiStatusFailed || currentAIStatus == aiStatusSkipped {
 			if err := tx.Model(&models.Transaction{}).
 				Where("id = ?", txnID).
 				Updates(map[string]interface{}{
@@ -547,7 +686,8 @@ func (s *TransactionService) UpdateExpense(ctx context.Context, txnID string, sp
 		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to update expense")
 	}
 
-	return s.txnRepo.FindByID(ctx, txnID, spaceID)
+	s.publish(events.TypeTransactionUpdated, spaceID, txnID)
+	return s.reload(ctx, txnID, spaceID)
 }
 
 // CancelAIExtract aborts an in-flight AI extraction by resetting ai_status to
@@ -571,6 +711,7 @@ func (s *TransactionService) CancelAIExtract(ctx context.Context, txnID string,
 	if result.RowsAffected == 0 {
 		return errorx.Wrap(errorx.ErrConflict, "Transaction is not being processed by AI")
 	}
+	s.publish(events.TypeAIStatus, spaceID, txnID)
 	return nil
 }
 
@@ -605,6 +746,9 @@ func (s *TransactionService) CreatePayment(ctx context.Context, spaceID uuid.UUI
 		TotalAmount: input.TotalAmount,
 		Note:        input.Note,
 	}
+	if input.CreatedBy != uuid.Nil {
+		txn.CreatedBy = &input.CreatedBy
+	}
 
 	if input.Date != nil {
 		txn.Date = *input.Date
@@ -634,7 +778,8 @@ func (s *TransactionService) CreatePayment(ctx context.Context, spaceID uuid.UUI
 		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to create payment")
 	}
 
-	return s.txnRepo.FindByID(ctx, txnID, spaceID)
+	s.publish(events.TypeTransactionCreated, spaceID, txnID)
+	return s.reload(ctx, txnID, spaceID)
 }
 
 func (s *TransactionService) UpdatePayment(ctx context.Context, txnID string, spaceID uuid.UUID, input UpdatePaymentInput) (*models.Transaction, error) {
@@ -698,5 +843,6 @@ func (s *TransactionService) UpdatePayment(ctx context.Context, txnID string, sp
 		return nil, errorx.Wrap(errorx.ErrInternal, "Failed to update payment")
 	}
 
-	return s.txnRepo.FindByID(ctx, txnID, spaceID)
+	s.publish(events.TypeTransactionUpdated, spaceID, txnID)
+	return s.reload(ctx, txnID, spaceID)
 }
@@ -2,6 +2,10 @@ package services
 
 import (
 	"testing"
+	"time"
+
+	"lovelion/internal/imaging"
+	"lovelion/internal/models"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -242,3 +246,34 @@ func TestBuildDebts_UniqueIDs(t *testing.T) {
 		ids[debt.ID] = true
 	}
 }
+
+// --- applyPhotoMetadata ---
+
+func TestApplyPhotoMetadata_FirstPhotoWithEachValueWins(t *testing.T) {
+	taken := time.Date(2026, 3, 14, 12, 34, 56, 0, time.UTC)
+	images := []*imaging.Result{
+		{},
+		{Metadata: imaging.Metadata{TakenAt: &taken}},
+		{Metadata: imaging.Metadata{GPS: &imaging.LatLng{Lat: 25.0339, Lng: 121.5645}}},
+	}
+	txn := &models.Transaction{Date: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	expense := &models.TransactionExpense{}
+
+	applyPhotoMetadata(txn, expense, images)
+
+	assert.Equal(t, taken, txn.Date)
+	assert.Equal(t, "https://www.google.com/maps?q=25.033900,121.564500", expense.LocationURL)
+}
+
+func TestApplyPhotoMetadata_KeepsTypedLocationAndDateWithoutEXIF(t *testing.T) {
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	txn := &models.Transaction{Date: date}
+	expense := &models.TransactionExpense{LocationURL: "https://maps.app.goo.gl/x"}
+
+	applyPhotoMetadata(txn, expense, []*imaging.Result{
+		{Metadata: imaging.Metadata{GPS: &imaging.LatLng{Lat: 1, Lng: 2}}},
+	})
+
+	assert.Equal(t, date, txn.Date)
+	assert.Equal(t, "https://maps.app.goo.gl/x", expense.LocationURL)
+}
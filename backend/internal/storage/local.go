@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilesRoute is the path prefix LocalStorage URLs point at; main.go serves it
+// with handlers.FilesHandler.
+const FilesRoute = "/files/"
+
+// ErrInvalidSignature is returned by Verify for tampered or expired URLs.
+var ErrInvalidSignature = errors.New("storage: invalid or expired signature")
+
+// LocalStorage keeps objects on the local filesystem for self-hosting and
+// development. Files are served by the API itself under FilesRoute, and every
+// URL carries an HMAC of the key so the route can't be used to list or guess
+// other files.
+type LocalStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalStorage stores files under root and builds URLs on baseURL (the
+// API's public origin, e.g. "http://localhost:8080").
+func NewLocalStorage(root, baseURL, secret string) (*LocalStorage, error) {
+	if secret == "" {
+		return nil, errors.New("local storage: signing key is required")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("local storage: %w", err)
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("local storage: create %s: %w", abs, err)
+	}
+	return &LocalStorage{
+		root:    abs,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+	}, nil
+}
+
+// Path maps a key to its file, rejecting keys that would escape the root.
+func (l *LocalStorage) Path(key string) (string, error) {
+	clean := path.Clean("/" + strings.TrimLeft(key, "/"))
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("local storage: invalid key %q", key)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
+
+// Upload writes the object to disk. contentType is not stored; Download
+// derives it from the extension or the content.
+func (l *LocalStorage) Upload(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", fmt.Errorf("local put %s: %w", key, err)
+	}
+	// Write to a temp file first so a failed upload never leaves a partial
+	// object under the final name.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("local put %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("local put %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("local put %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", fmt.Errorf("local put %s: %w", key, err)
+	}
+	return l.PublicURL(key), nil
+}
+
+// Delete removes the object's file.
+func (l *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("local delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// List walks the storage root. Temp files of uploads still in progress are
+// skipped.
+func (l *LocalStorage) List(ctx context.Context, fn func(Object) error) error {
+	return filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(Object{Key: filepath.ToSlash(rel), Size: info.Size(), LastModified: info.ModTime()})
+	})
+}
+
+// Download reads the object's file.
+func (l *LocalStorage) Download(ctx context.Context, key string) ([]byte, string, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, "", fmt.Errorf("local get %s: %w", key, err)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(p))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return data, contentType, nil
+}
+
+// Open opens the object's file.
+func (l *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("local open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// DownloadByURL extracts the key from a stored file URL and calls Download.
+func (l *LocalStorage) DownloadByURL(ctx context.Context, fullURL string) ([]byte, string, error) {
+	return l.Download(ctx, l.KeyFromURL(fullURL))
+}
+
+// PublicURL returns a signed, non-expiring URL for key.
+func (l *LocalStorage) PublicURL(key string) string {
+	return l.SignedURL(key, 0)
+}
+
+// SignedURL returns a URL for key that stops working after ttl; ttl <= 0
+// means it never expires.
+func (l *LocalStorage) SignedURL(key string, ttl time.Duration) string {
+	key = strings.TrimLeft(key, "/")
+	var exp int64
+	if ttl > 0 {
+		exp = time.Now().Add(ttl).Unix()
+	}
+	q := url.Values{"sig": {l.sign(key, exp)}}
+	if exp > 0 {
+		q.Set("exp", strconv.FormatInt(exp, 10))
+	}
+	return l.baseURL + FilesRoute + key + "?" + q.Encode()
+}
+
+// PresignedURL is SignedURL under the Storage interface.
+func (l *LocalStorage) PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if _, err := l.Path(key); err != nil {
+		return "", err
+	}
+	return l.SignedURL(key, ttl), nil
+}
+
+// PresignedUploadURL returns a FilesRoute URL that accepts one PUT of
+// exactly size bytes of contentType; FilesHandler.Upload checks it with
+// VerifyUpload. The signature covers the size and type, and differs from
+// any download signature, so a download link can't be used to write.
+func (l *LocalStorage) PresignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	if _, err := l.Path(key); err != nil {
+		return "", err
+	}
+	key = strings.TrimLeft(key, "/")
+	exp := time.Now().Add(ttl).Unix()
+	q := url.Values{
+		"exp":  {strconv.FormatInt(exp, 10)},
+		"size": {strconv.FormatInt(size, 10)},
+		"type": {contentType},
+		"sig":  {l.signUpload(key, exp, size, contentType)},
+	}
+	return l.baseURL + FilesRoute + key + "?" + q.Encode(), nil
+}
+
+// VerifyUpload checks a PUT to FilesRoute against its query values and
+// returns the size the upload must have.
+func (l *LocalStorage) VerifyUpload(key string, q url.Values, contentType string) (int64, error) {
+	key = strings.TrimLeft(key, "/")
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return 0, ErrInvalidSignature
+	}
+	size, err := strconv.ParseInt(q.Get("size"), 10, 64)
+	if err != nil || size <= 0 || contentType != q.Get("type") {
+		return 0, ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(l.signUpload(key, exp, size, contentType))) {
+		return 0, ErrInvalidSignature
+	}
+	return size, nil
+}
+
+// Stat reports an object's size and modification time.
+func (l *LocalStorage) Stat(ctx context.Context, key string) (Object, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("local stat %s: %w", key, err)
+	}
+	return Object{Key: strings.TrimLeft(key, "/"), Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+// KeyFromURL strips the base URL, route prefix and signature from a URL
+// built by PublicURL or SignedURL.
+func (l *LocalStorage) KeyFromURL(fullURL string) string {
+	prefix := l.baseURL + FilesRoute
+	if !strings.HasPrefix(fullURL, prefix) {
+		return fullURL
+	}
+	key := strings.TrimPrefix(fullURL, prefix)
+	if i := strings.IndexByte(key, '?'); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+// Verify checks the sig and exp query values of a FilesRoute request.
+func (l *LocalStorage) Verify(key, sig, exp string) error {
+	key = strings.TrimLeft(key, "/")
+	var expires int64
+	if exp != "" {
+		n, err := strconv.ParseInt(exp, 10, 64)
+		if err != nil || time.Now().Unix() > n {
+			return ErrInvalidSignature
+		}
+		expires = n
+	}
+	if !hmac.Equal([]byte(sig), []byte(l.sign(key, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (l *LocalStorage) sign(key string, exp int64) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(exp, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (l *LocalStorage) signUpload(key string, exp, size int64, contentType string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	for _, part := range []string{"PUT", key, strconv.FormatInt(exp, 10), strconv.FormatInt(size, 10), contentType} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
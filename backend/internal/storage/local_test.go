@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLocal(t *testing.T) *LocalStorage {
+	t.Helper()
+	l, err := NewLocalStorage(t.TempDir(), "http://api.test/", "secret")
+	require.NoError(t, err)
+	return l
+}
+
+func TestLocalStorage_RoundTrip(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+
+	fullURL, err := l.Upload(ctx, "transaction/a.png", bytes.NewReader([]byte("png-bytes")), "image/png")
+	require.NoError(t, err)
+	assert.Contains(t, fullURL, "http://api.test/files/transaction/a.png?sig=")
+	assert.Equal(t, "transaction/a.png", l.KeyFromURL(fullURL))
+
+	data, contentType, err := l.DownloadByURL(ctx, fullURL)
+	require.NoError(t, err)
+	assert.Equal(t, "png-bytes", string(data))
+	assert.Equal(t, "image/png", contentType)
+
+	require.NoError(t, l.Delete(ctx, "transaction/a.png"))
+	require.NoError(t, l.Delete(ctx, "transaction/a.png"), "deleting twice is fine")
+	_, _, err = l.Download(ctx, "transaction/a.png")
+	assert.Error(t, err)
+}
+
+func TestLocalStorage_KeysStayInsideRoot(t *testing.T) {
+	l := newTestLocal(t)
+	_, err := l.Upload(context.Background(), "../escape.txt", bytes.NewReader(nil), "")
+	assert.Error(t, err)
+	_, err = l.Path("")
+	assert.Error(t, err)
+
+	p, err := l.Path("/space/x.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(l.root, "space", "x.jpg"), p)
+	_, statErr := os.Stat(filepath.Join(filepath.Dir(l.root), "escape.txt"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestLocalStorage_Verify(t *testing.T) {
+	l := newTestLocal(t)
+
+	u, err := url.Parse(l.PublicURL("space/x.jpg"))
+	require.NoError(t, err)
+	q := u.Query()
+	assert.NoError(t, l.Verify("/space/x.jpg", q.Get("sig"), q.Get("exp")))
+	assert.ErrorIs(t, l.Verify("/space/y.jpg", q.Get("sig"), ""), ErrInvalidSignature)
+
+	u, err = url.Parse(l.SignedURL("space/x.jpg", time.Minute))
+	require.NoError(t, err)
+	q = u.Query()
+	require.NotEmpty(t, q.Get("exp"))
+	assert.NoError(t, l.Verify("space/x.jpg", q.Get("sig"), q.Get("exp")))
+	assert.ErrorIs(t, l.Verify("space/x.jpg", q.Get("sig"), ""), ErrInvalidSignature, "dropping exp changes the signature")
+
+	// A correctly signed but past exp is rejected.
+	assert.ErrorIs(t, l.Verify("space/x.jpg", l.sign("space/x.jpg", 1), "1"), ErrInvalidSignature)
+}
+
+func TestLocalStorage_PresignedURL(t *testing.T) {
+	l := newTestLocal(t)
+
+	signed, err := l.PresignedURL(context.Background(), "space/x.jpg", time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.NotEmpty(t, u.Query().Get("exp"))
+	assert.NoError(t, l.Verify("space/x.jpg", u.Query().Get("sig"), u.Query().Get("exp")))
+
+	_, err = l.PresignedURL(context.Background(), "../x.jpg", time.Minute)
+	assert.Error(t, err)
+}
+
+func TestLocalStorage_List(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+	for _, key := range []string{"space/a.jpg", "transaction/b_thumb.jpg"} {
+		_, err := l.Upload(ctx, key, bytes.NewReader([]byte("x")), "")
+		require.NoError(t, err)
+	}
+	// An interrupted upload's temp file is not an object.
+	require.NoError(t, os.WriteFile(filepath.Join(l.root, "space", ".upload-123"), nil, 0o644))
+
+	var keys []string
+	require.NoError(t, l.List(ctx, func(o Object) error {
+		keys = append(keys, o.Key)
+		assert.EqualValues(t, 1, o.Size)
+		assert.False(t, o.LastModified.IsZero())
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"space/a.jpg", "transaction/b_thumb.jpg"}, keys)
+}
+
+func TestLocalStorage_PresignedUploadURL(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+
+	raw, err := l.PresignedUploadURL(ctx, "uploads/abc", "image/jpeg", 1234, time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "/files/uploads/abc", u.Path)
+
+	size, err := l.VerifyUpload("uploads/abc", u.Query(), "image/jpeg")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1234, size)
+
+	_, err = l.VerifyUpload("uploads/abc", u.Query(), "image/png")
+	assert.ErrorIs(t, err, ErrInvalidSignature, "content type is part of the signature")
+	tampered := u.Query()
+	tampered.Set("size", "99999999")
+	_, err = l.VerifyUpload("uploads/abc", tampered, "image/jpeg")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = l.VerifyUpload("uploads/other", u.Query(), "image/jpeg")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// A download link for the same key does not authorize a write.
+	get, err := url.Parse(l.SignedURL("uploads/abc", time.Minute))
+	require.NoError(t, err)
+	_, err = l.VerifyUpload("uploads/abc", get.Query(), "image/jpeg")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestLocalStorage_Stat(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+
+	_, err := l.Stat(ctx, "uploads/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = l.Upload(ctx, "uploads/abc", bytes.NewReader([]byte("12345")), "")
+	require.NoError(t, err)
+	obj, err := l.Stat(ctx, "uploads/abc")
+	require.NoError(t, err)
+	assert.Equal(t, "uploads/abc", obj.Key)
+	assert.EqualValues(t, 5, obj.Size)
+
+	_, err = l.Open(ctx, "uploads/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	rc, err := l.Open(ctx, "uploads/abc")
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(data))
+}
@@ -1,13 +1,16 @@
-// Package storage provides a thin wrapper around Cloudflare R2 (S3-compatible)
-// so that handlers, workers and other services share one client configuration
-// without depending on the S3 SDK types directly.
+// Package storage provides the Storage interface with an S3-compatible backend
+// (Cloudflare R2, AWS S3, MinIO, ...) and a local-filesystem backend, so that
+// handlers, workers and other services share one client configuration without
+// depending on the S3 SDK types directly.
 package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"lovelion/internal/config"
 
@@ -15,9 +18,12 @@ import (
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 // R2Storage wraps an *s3.Client bound to a specific bucket + public domain.
+// Despite the name it works with any S3-compatible service.
 type R2Storage struct {
 	client       *s3.Client
 	bucket       string
@@ -34,25 +40,31 @@ func NewR2StorageFromClient(client *s3.Client, bucket, publicDomain string) *R2S
 	}
 }
 
-// NewR2Storage constructs an R2 client from the app config.
+// NewR2Storage constructs an S3 client from the app config. R2_ENDPOINT
+// overrides the Cloudflare endpoint derived from R2_ACCOUNT_ID.
 func NewR2Storage(cfg *config.Config) (*R2Storage, error) {
+	endpoint := cfg.R2Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.R2AccountID)
+	}
 	resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-		return aws.Endpoint{
-			URL: fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.R2AccountID),
-		}, nil
+		return aws.Endpoint{URL: endpoint}, nil
 	})
 
 	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
 		awsconfig.WithEndpointResolverWithOptions(resolver),
 		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.R2AccessKey, cfg.R2SecretKey, "")),
-		awsconfig.WithRegion("auto"),
+		awsconfig.WithRegion(cfg.R2Region),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("load R2 config: %w", err)
 	}
 
 	return &R2Storage{
-		client:       s3.NewFromConfig(awsCfg),
+		client: s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+			// MinIO and most self-hosted services need bucket-in-path URLs.
+			o.UsePathStyle = cfg.R2PathStyle
+		}),
 		bucket:       cfg.R2Bucket,
 		publicDomain: strings.TrimRight(cfg.R2PublicDomain, "/"),
 	}, nil
@@ -72,6 +84,55 @@ func (r *R2Storage) PublicURL(key string) string {
 	return r.publicDomain + "/" + strings.TrimLeft(key, "/")
 }
 
+// PresignedURL signs a GET for key against the S3 endpoint, so the bucket
+// itself can stay private.
+func (r *R2Storage) PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(r.client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(strings.TrimLeft(key, "/")),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("r2 presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+// PresignedUploadURL signs a PUT for key. Content-Length and Content-Type
+// are part of the signature, so the bucket rejects any other size or type.
+func (r *R2Storage) PresignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(r.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(r.bucket),
+		Key:           aws.String(strings.TrimLeft(key, "/")),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("r2 presign put %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+// Stat issues a HEAD for key.
+func (r *R2Storage) Stat(ctx context.Context, key string) (Object, error) {
+	out, err := r.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		var apiErr smithy.APIError
+		if errors.As(err, &notFound) || (errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotFound") {
+			return Object{}, ErrNotFound
+		}
+		return Object{}, fmt.Errorf("r2 head %s: %w", key, err)
+	}
+	o := Object{Key: key, Size: aws.ToInt64(out.ContentLength)}
+	if out.LastModified != nil {
+		o.LastModified = *out.LastModified
+	}
+	return o, nil
+}
+
 // KeyFromURL strips the public domain prefix from a stored file URL.
 // If the URL doesn't match the configured domain it is returned unchanged.
 func (r *R2Storage) KeyFromURL(fullURL string) string {
@@ -131,6 +192,45 @@ func (r *R2Storage) Download(ctx context.Context, key string) ([]byte, string, e
 	return data, contentType, nil
 }
 
+// Open starts fetching an object and returns its body unread.
+func (r *R2Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noKey *types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("r2 get %s: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+// List pages through every object in the bucket.
+func (r *R2Storage) List(ctx context.Context, fn func(Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("r2 list: %w", err)
+		}
+		for _, obj := range page.Contents {
+			o := Object{Key: aws.ToString(obj.Key), Size: aws.ToInt64(obj.Size)}
+			if obj.LastModified != nil {
+				o.LastModified = *obj.LastModified
+			}
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // DownloadByURL is a convenience wrapper that extracts the key from a stored
 // file URL and calls Download.
 func (r *R2Storage) DownloadByURL(ctx context.Context, fullURL string) ([]byte, string, error) {
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestR2Storage_PresignedURL(t *testing.T) {
+	client := s3.New(s3.Options{
+		Region:       "auto",
+		BaseEndpoint: aws.String("https://s3.test"),
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		UsePathStyle: true,
+	})
+	r := NewR2StorageFromClient(client, "bucket", "https://cdn.test")
+
+	signed, err := r.PresignedURL(context.Background(), "transaction/a.jpg", 15*time.Minute)
+	require.NoError(t, err)
+
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "s3.test", u.Host, "presigned URLs go to the bucket endpoint, not the public domain")
+	assert.Equal(t, "/bucket/transaction/a.jpg", u.Path)
+	assert.Equal(t, "900", u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestR2Storage_PresignedUploadURL(t *testing.T) {
+	client := s3.New(s3.Options{
+		Region:       "auto",
+		BaseEndpoint: aws.String("https://s3.test"),
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+		UsePathStyle: true,
+	})
+	r := NewR2StorageFromClient(client, "bucket", "https://cdn.test")
+
+	signed, err := r.PresignedUploadURL(context.Background(), "uploads/abc", "image/heic", 2048, 10*time.Minute)
+	require.NoError(t, err)
+
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/bucket/uploads/abc", u.Path)
+	assert.Equal(t, "600", u.Query().Get("X-Amz-Expires"))
+	assert.Equal(t, "content-length;content-type;host", u.Query().Get("X-Amz-SignedHeaders"),
+		"size and type are enforced by the bucket")
+}
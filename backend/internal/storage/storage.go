@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"lovelion/internal/config"
+)
+
+// Storage is a blob store for user uploads. Keys are slash-separated paths
+// such as "transaction/<uuid>.jpg" and are what gets stored on Image rows;
+// clients only ever see short-lived URLs from PresignedURL.
+type Storage interface {
+	// Upload stores an object at key and returns its URL. contentType may be empty.
+	Upload(ctx context.Context, key string, body io.Reader, contentType string) (string, error)
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// Download returns an object's bytes and content type.
+	Download(ctx context.Context, key string) ([]byte, string, error)
+	// Open streams an object, for callers that must bound how much they
+	// read. A missing object yields ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// DownloadByURL is Download for a URL previously returned by Upload.
+	DownloadByURL(ctx context.Context, fullURL string) ([]byte, string, error)
+	// PublicURL returns a permanent URL for key. Only meaningful when the
+	// bucket is public; API responses use PresignedURL instead.
+	PublicURL(key string) string
+	// PresignedURL returns a URL that grants read access to key for ttl.
+	PresignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// PresignedUploadURL returns a URL that accepts one PUT of exactly size
+	// bytes with the given Content-Type to key, valid for ttl.
+	PresignedUploadURL(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (string, error)
+	// Stat describes an object without downloading it. A missing object
+	// yields ErrNotFound.
+	Stat(ctx context.Context, key string) (Object, error)
+	// KeyFromURL reverses PublicURL. Unknown URLs are returned unchanged.
+	KeyFromURL(fullURL string) string
+	// List calls fn for every stored object, in no particular order. An
+	// error from fn stops the listing and is returned.
+	List(ctx context.Context, fn func(Object) error) error
+}
+
+// ErrNotFound is returned by Stat and Open for missing objects.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Object describes one stored object as reported by List and Stat.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Backend names accepted by STORAGE_BACKEND.
+const (
+	BackendR2    = "r2"
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// New builds the backend selected by cfg.StorageBackend. "s3" is an alias for
+// "r2": both use the S3 API, with R2_ENDPOINT pointing at any compatible
+// service.
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case BackendR2, BackendS3, "":
+		return NewR2Storage(cfg)
+	case BackendLocal:
+		return NewLocalStorage(cfg.LocalStorageDir, cfg.LocalStorageBaseURL, cfg.StorageSigningKey)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", cfg.StorageBackend)
+	}
+}
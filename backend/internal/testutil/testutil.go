@@ -75,13 +75,18 @@ func TestDB(t *testing.T) *gorm.DB {
 
 	err = db.AutoMigrate(
 		&models.User{},
+		&models.Session{},
 		&models.Space{},
 		&models.SpaceMember{},
 		&models.SpaceInvite{},
+		&models.SpaceJoinRequest{},
 		&models.Transaction{},
 		&models.TransactionExpense{},
 		&models.TransactionExpenseItem{},
 		&models.TransactionDebt{},
+		&models.AIExtractionAttempt{},
+		&models.OrphanCandidate{},
+		&models.PendingUpload{},
 		&models.ComparisonStore{},
 		&models.ComparisonProduct{},
 		&models.InvMember{},
@@ -12,8 +12,11 @@ import (
 
 	"lovelion/internal/config"
 	"lovelion/internal/database"
+	"lovelion/internal/events"
 	"lovelion/internal/handlers"
+	"lovelion/internal/imaging"
 	"lovelion/internal/middleware"
+	"lovelion/internal/models"
 	"lovelion/internal/repositories"
 	"lovelion/internal/services"
 	"lovelion/internal/storage"
@@ -61,9 +64,20 @@ func main() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Space change events for SSE clients. With EVENTS_PG_NOTIFY the bus relays
+	// through Postgres LISTEN/NOTIFY so every replica sees every write.
+	eventBus := events.NewBus()
+	if cfg.EventsPGNotify {
+		if err := eventBus.UsePostgres(db, cfg.DatabaseURL); err != nil {
+			slog.Error("failed to start pg event relay, using in-process events only", "error", err)
+		}
+	}
+
 	// aiWorker is assigned inside the api block (where dependencies are in
 	// scope). It stays nil when receipt extraction is disabled.
 	var aiWorker *services.AIWorker
+	// imageGC is assigned next to the storage it cleans; nil when disabled.
+	var imageGC *services.ImageGC
 
 	// API routes
 	api := r.Group("/api")
@@ -72,13 +86,23 @@ func main() {
 		authRateLimit := middleware.RateLimit(cfg.AuthRateLimit, time.Minute)
 		users := api.Group("/users")
 		{
-			authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret, cfg.JWTExpiry)
+			sessions := services.NewSessions(db, cfg.JWTSecret, cfg.JWTExpiry).WithAccessTokenTTL(cfg.AccessTokenTTL)
+			authHandler := handlers.NewAuthHandler(db, sessions)
 			users.POST("/register", authRateLimit, authHandler.Register)
 			users.POST("/login", authRateLimit, authHandler.Login)
+			users.POST("/refresh", authRateLimit, authHandler.Refresh)
 
 			// Protected routes
 			users.GET("/me", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), authHandler.GetMe)
 			users.PUT("/me", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), authHandler.UpdateMe)
+			users.POST("/logout", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), authHandler.Logout)
+
+			// Signed-in devices
+			sessionHandler := handlers.NewSessionHandler(sessions)
+			users.GET("/me/sessions", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), sessionHandler.List)
+			users.DELETE("/me/sessions", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), sessionHandler.RevokeAll)
+			users.DELETE("/me/sessions/:session_id", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), sessionHandler.Revoke)
+			users.GET("/me/dashboard", middleware.AuthRequiredWithDB(cfg.JWTSecret, db), handlers.NewDashboardHandler(db).Get)
 		}
 
 		// Repositories
@@ -89,16 +113,40 @@ func main() {
 		expenseItemRepo := repositories.NewTransactionExpenseItemRepo(db)
 		debtRepo := repositories.NewTransactionDebtRepo(db)
 
-		// Shared R2 storage (used by ImageHandler and TransactionService)
-		r2Storage, err := storage.NewR2Storage(cfg)
+		// Shared image storage (used by ImageHandler, TransactionService and
+		// the AI worker). STORAGE_BACKEND picks R2/S3 or the local filesystem.
+		fileStorage, err := storage.New(cfg)
 		if err != nil {
-			slog.Error("failed to initialize R2 storage", "error", err)
+			slog.Error("failed to initialize storage", "backend", cfg.StorageBackend, "error", err)
 			os.Exit(1)
 		}
+		// The local backend is served by the API itself through signed URLs.
+		if local, ok := fileStorage.(*storage.LocalStorage); ok {
+			filesHandler := handlers.NewFilesHandler(local)
+			r.GET(storage.FilesRoute+"*key", filesHandler.Serve)
+			r.PUT(storage.FilesRoute+"*key", filesHandler.Upload)
+		}
+		// Images are private: responses carry short-lived signed URLs.
+		imageURLs := services.NewImageURLSigner(fileStorage, cfg.ImageURLTTL)
+		imageOpts := imaging.Options{MaxDimension: cfg.ImageMaxDimension, MaxMegapixels: cfg.ImageMaxMegapixels}
+		if cfg.ImageGCEnabled {
+			imageGC = services.NewImageGC(db, fileStorage, services.ImageGCConfig{
+				Interval: cfg.ImageGCInterval,
+				Grace:    cfg.ImageGCGrace,
+				DryRun:   cfg.ImageGCDryRun,
+			})
+		}
+
+		// Clients may also PUT images to storage themselves and finalize them.
+		directUploads := services.NewDirectUploads(db, fileStorage).WithImageOptions(imageOpts)
 
 		// Services
-		inviteService := services.NewInviteService(db, inviteRepo, memberRepo)
-		txnService := services.NewTransactionService(db, txnRepo, expenseRepo, expenseItemRepo, debtRepo, r2Storage)
+		inviteService := services.NewInviteService(db, inviteRepo, memberRepo, repositories.NewJoinRequestRepo(db))
+		txnService := services.NewTransactionService(db, txnRepo, expenseRepo, expenseItemRepo, debtRepo, fileStorage).
+			WithEvents(eventBus).
+			WithImageURLs(imageURLs).
+			WithImageOptions(imageOpts).
+			WithDirectUploads(directUploads)
 
 		// AI receipt extraction rate limiter (per-user daily cap).
 		// A zero/negative cap disables the check entirely.
@@ -112,8 +160,15 @@ func main() {
 				slog.Warn("receipt extraction enabled but GEMINI_API_KEY is empty — worker not started")
 			} else {
 				extractor := services.NewGeminiReceiptExtractor(cfg.GeminiAPIKey, cfg.GeminiModel, cfg.GeminiBaseURL)
-				aiWorker = services.NewAIWorker(db, extractor, r2Storage, services.AIWorkerConfig{}).
-					WithTextExtractor(extractor)
+				// Taiwan e-invoice QR codes are decoded locally first; the LLM
+				// only runs when no e-invoice QR code can be read.
+				var imageExtractor services.ReceiptExtractor = extractor
+				if cfg.ReceiptQREnabled {
+					imageExtractor = services.ChainReceiptExtractor{services.NewEInvoiceQRExtractor(), extractor}
+				}
+				aiWorker = services.NewAIWorker(db, imageExtractor, fileStorage, services.AIWorkerConfig{}).
+					WithTextExtractor(extractor).
+					WithEvents(eventBus)
 			}
 		} else {
 			slog.Info("receipt extraction disabled", "RECEIPT_EXTRACT_ENABLED", "false")
@@ -146,6 +201,11 @@ func main() {
 				adminAnnouncements.DELETE("/:id", adminAnnouncementHandler.Delete)
 				adminAnnouncements.POST("/generate", adminAnnouncementHandler.Generate)
 			}
+
+			// Stored AI extraction attempts (raw model output) and re-parse
+			adminAIAttemptHandler := handlers.NewAdminAIAttemptHandler(db, aiWorker)
+			adminGroup.GET("/transactions/:id/ai-attempts", adminAIAttemptHandler.List)
+			adminGroup.POST("/ai-attempts/:id/reparse", adminAIAttemptHandler.Reparse)
 		}
 
 		// Sharing routes (Public Info)
@@ -157,71 +217,114 @@ func main() {
 		spaces := api.Group("/spaces")
 		spaces.Use(middleware.AuthRequiredWithDB(cfg.JWTSecret, db))
 		{
-			spaceHandler := handlers.NewSpaceHandler(db)
+			spaceHandler := handlers.NewSpaceHandler(db).WithImageURLs(imageURLs)
 			spaces.GET("", spaceHandler.List)
 			spaces.POST("", spaceHandler.Create)
 
+			// Whole-space export / import
+			archiveHandler := handlers.NewSpaceArchiveHandler(services.NewSpaceArchiver(db, fileStorage).WithImageOptions(imageOpts)).
+				WithImageURLs(imageURLs)
+			spaces.POST("/import", archiveHandler.Import)
+
 			// Single space operations
 			spaceGroup := spaces.Group("/:id")
 			spaceGroup.Use(middleware.SpaceAccess(db))
 			{
 				spaceGroup.GET("", spaceHandler.Get)
 				spaceGroup.POST("/leave", spaceHandler.Leave)
+				spaceGroup.GET("/me", handlers.NewMemberSummaryHandler(db).Me)
+
+				// Ownership transfer: the owner offers, the nominee answers
+				transferHandler := handlers.NewSpaceTransferHandler(services.NewSpaceTransfers(db))
+				spaceGroup.POST("/transfer/accept", transferHandler.Accept)
+				spaceGroup.POST("/transfer/decline", transferHandler.Decline)
 
-				// Owner only operations
+				// Lifecycle: close / archive / reopen, and start a copy
+				lifecycleHandler := handlers.NewSpaceLifecycleHandler(services.NewSpaceLifecycle(db))
+				spaceGroup.POST("/duplicate", lifecycleHandler.Duplicate)
+
+				// Owner only operations. Settings and membership changes need a
+				// writable space; status, delete and export stay available so a
+				// closed or archived space can be reopened, removed or taken out.
 				ownerGroup := spaceGroup.Group("")
 				ownerGroup.Use(middleware.SpaceOwnerOnly())
+				writable := middleware.SpaceWritable()
 				{
-					ownerGroup.PUT("", spaceHandler.Update)
-					ownerGroup.PATCH("", spaceHandler.Update) // Add PATCH support
+					ownerGroup.PUT("", writable, spaceHandler.Update)
+					ownerGroup.PATCH("", writable, spaceHandler.Update) // Add PATCH support
 					ownerGroup.DELETE("", spaceHandler.Delete)
+					ownerGroup.GET("/export", archiveHandler.Export)
+					ownerGroup.POST("/transfer", transferHandler.Nominate)
+					ownerGroup.DELETE("/transfer", transferHandler.Cancel)
+					ownerGroup.PUT("/status", lifecycleHandler.UpdateStatus)
 
 					// Invitation management
-					ownerGroup.POST("/invites", sharingHandler.CreateInvite)
+					ownerGroup.POST("/invites", writable, sharingHandler.CreateInvite)
 					ownerGroup.GET("/invites", sharingHandler.ListInvites)
 					ownerGroup.DELETE("/invites/:invite_id", sharingHandler.RevokeInvite)
+					ownerGroup.GET("/join-requests", sharingHandler.ListJoinRequests)
+					ownerGroup.POST("/join-requests/:request_id/approve", writable, sharingHandler.ApproveJoinRequest)
+					ownerGroup.POST("/join-requests/:request_id/reject", sharingHandler.RejectJoinRequest)
 				}
 
 				// Member management
 				spaceGroup.GET("/members", sharingHandler.ListMembers)
-				spaceGroup.PATCH("/members/:user_id", sharingHandler.UpdateMemberAlias)
 				spaceGroup.DELETE("/members/:user_id", sharingHandler.RemoveMember)
+				spaceGroup.PATCH("/members/:user_id", writable, sharingHandler.UpdateMemberAlias) // self, or editor for others
+				ownerGroup.PATCH("/members/:user_id/role", writable, sharingHandler.UpdateMemberRole)
+
+				// Write access by role: contributors add transactions and
+				// change their own, editors change everything else. Closed
+				// and archived spaces are read-only for everyone.
+				contributorGroup := spaceGroup.Group("")
+				contributorGroup.Use(middleware.SpaceRoleRequired(models.SpaceRoleContributor), middleware.SpaceWritable())
+				editorGroup := spaceGroup.Group("")
+				editorGroup.Use(middleware.SpaceRoleRequired(models.SpaceRoleEditor), middleware.SpaceWritable())
+				txnEditor := middleware.SpaceTransactionEditor(db)
 
 				// Comparison routes (Integrated into space)
 				comparisonHandler := handlers.NewComparisonHandler(db)
 				spaceGroup.GET("/stores", comparisonHandler.ListStores)
-				spaceGroup.POST("/stores", comparisonHandler.CreateStore)
+				editorGroup.POST("/stores", comparisonHandler.CreateStore)
 				spaceGroup.GET("/stores/:store_id", comparisonHandler.GetStore)
-				spaceGroup.PUT("/stores/:store_id", comparisonHandler.UpdateStore)
-				spaceGroup.DELETE("/stores/:store_id", comparisonHandler.DeleteStore)
+				editorGroup.PUT("/stores/:store_id", comparisonHandler.UpdateStore)
+				editorGroup.DELETE("/stores/:store_id", comparisonHandler.DeleteStore)
 				spaceGroup.GET("/products", comparisonHandler.ListAllProducts)
-				spaceGroup.POST("/stores/:store_id/products", comparisonHandler.CreateProduct)
+				editorGroup.POST("/stores/:store_id/products", comparisonHandler.CreateProduct)
 				spaceGroup.GET("/stores/:store_id/products/:product_id", comparisonHandler.GetProduct)
-				spaceGroup.PUT("/stores/:store_id/products/:product_id", comparisonHandler.UpdateProduct)
-				spaceGroup.DELETE("/stores/:store_id/products/:product_id", comparisonHandler.DeleteProduct)
+				editorGroup.PUT("/stores/:store_id/products/:product_id", comparisonHandler.UpdateProduct)
+				editorGroup.DELETE("/stores/:store_id/products/:product_id", comparisonHandler.DeleteProduct)
+
+				// Server-Sent Events stream (AI status + transaction changes)
+				eventsHandler := handlers.NewEventsHandler(eventBus)
+				spaceGroup.GET("/events", eventsHandler.Stream)
 
 				// Transaction routes (shared: list, get, delete, ai-cancel)
 				transactionHandler := handlers.NewTransactionHandler(txnService)
 				spaceGroup.GET("/transactions", transactionHandler.List)
 				spaceGroup.GET("/transactions/:txn_id", transactionHandler.Get)
-				spaceGroup.DELETE("/transactions/:txn_id", transactionHandler.Delete)
-				spaceGroup.POST("/transactions/:txn_id/ai-cancel", transactionHandler.AICancel)
+				contributorGroup.DELETE("/transactions/:txn_id", txnEditor, transactionHandler.Delete)
+				contributorGroup.POST("/transactions/:txn_id/ai-cancel", txnEditor, transactionHandler.AICancel)
 
 				// Expense routes
 				expenseHandler := handlers.NewExpenseHandler(txnService, aiRateLimiter)
-				spaceGroup.POST("/expenses", expenseHandler.Create)
-				spaceGroup.PUT("/expenses/:txn_id", expenseHandler.Update)
+				contributorGroup.POST("/expenses", expenseHandler.Create)
+				contributorGroup.PUT("/expenses/:txn_id", txnEditor, expenseHandler.Update)
 
 				// Payment routes
 				paymentHandler := handlers.NewPaymentHandler(txnService)
-				spaceGroup.POST("/payments", paymentHandler.Create)
-				spaceGroup.PUT("/payments/:txn_id", paymentHandler.Update)
+				contributorGroup.POST("/payments", paymentHandler.Create)
+				contributorGroup.PUT("/payments/:txn_id", txnEditor, paymentHandler.Update)
+
+				// Category / payment-method suggestions learned from history
+				suggestionHandler := handlers.NewSuggestionHandler(services.NewSuggestionService(db))
+				spaceGroup.GET("/suggest", suggestionHandler.Suggest)
 
 				// Expense template routes
 				templateHandler := handlers.NewExpenseTemplateHandler(db)
 				spaceGroup.GET("/expense-templates", templateHandler.List)
-				spaceGroup.POST("/expense-templates", templateHandler.Create)
-				spaceGroup.DELETE("/expense-templates/:template_id", templateHandler.Delete)
+				editorGroup.POST("/expense-templates", templateHandler.Create)
+				editorGroup.DELETE("/expense-templates/:template_id", templateHandler.Delete)
 			}
 		}
 
@@ -229,8 +332,13 @@ func main() {
 		images := api.Group("/images")
 		images.Use(middleware.AuthRequiredWithDB(cfg.JWTSecret, db))
 		{
-			imageHandler := handlers.NewImageHandler(db, r2Storage)
+			imageHandler := handlers.NewImageHandler(db, fileStorage).
+				WithImageURLs(imageURLs).
+				WithImageOptions(imageOpts).
+				WithDirectUploads(directUploads)
 			images.POST("", imageHandler.Upload)
+			images.POST("/uploads", imageHandler.CreateUploadIntent)
+			images.POST("/uploads/:id/finalize", imageHandler.FinalizeUpload)
 			images.GET("", imageHandler.List)
 			images.PUT("/order", imageHandler.Reorder)
 			images.DELETE("/:id", imageHandler.Delete)
@@ -293,9 +401,10 @@ func main() {
 		Handler: r,
 	}
 
-	// Start AI worker (if enabled) with its own cancellable context so we can
-	// stop it independently of the HTTP server. We also hold a WaitGroup so
-	// shutdown blocks until the worker has returned from Run().
+	// Start the AI worker and image GC (if enabled) with their own
+	// cancellable context so we can stop them independently of the HTTP
+	// server. We also hold a WaitGroup so shutdown blocks until both have
+	// returned from Run().
 	workerCtx, cancelWorker := context.WithCancel(context.Background())
 	defer cancelWorker()
 	var workerWG sync.WaitGroup
@@ -306,6 +415,13 @@ func main() {
 			aiWorker.Run(workerCtx)
 		}()
 	}
+	if imageGC != nil {
+		workerWG.Add(1)
+		go func() {
+			defer workerWG.Done()
+			imageGC.Run(workerCtx)
+		}()
+	}
 
 	go func() {
 		slog.Info("server starting", "port", port)
@@ -325,11 +441,14 @@ func main() {
 	// HTTP drain window. A processing row at this point will be re-queued on
 	// the next boot via recoverStuck.
 	cancelWorker()
+	workerWG.Wait()
 	if aiWorker != nil {
-		workerWG.Wait()
 		slog.Info("ai worker stopped")
 	}
 
+	// End open SSE streams; otherwise Shutdown waits on them until the timeout.
+	eventBus.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
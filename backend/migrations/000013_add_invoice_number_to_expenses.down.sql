@@ -0,0 +1 @@
+ALTER TABLE transaction_expenses DROP COLUMN invoice_number;
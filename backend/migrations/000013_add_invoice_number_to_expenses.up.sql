@@ -0,0 +1 @@
+ALTER TABLE transaction_expenses ADD COLUMN invoice_number VARCHAR(20) DEFAULT '' NOT NULL;
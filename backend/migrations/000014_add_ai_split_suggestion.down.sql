@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN ai_split_suggestion;
+ALTER TABLE transactions DROP COLUMN created_by;
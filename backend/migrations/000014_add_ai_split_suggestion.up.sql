@@ -0,0 +1,2 @@
+ALTER TABLE transactions ADD COLUMN created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE transactions ADD COLUMN ai_split_suggestion JSONB;
@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN ai_prompt_version;
+ALTER TABLE spaces DROP COLUMN ai_instructions;
+ALTER TABLE spaces DROP COLUMN ai_locale;
@@ -0,0 +1,3 @@
+ALTER TABLE spaces ADD COLUMN ai_locale VARCHAR(10) NOT NULL DEFAULT 'zh-TW';
+ALTER TABLE spaces ADD COLUMN ai_instructions TEXT NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN ai_prompt_version VARCHAR(50);
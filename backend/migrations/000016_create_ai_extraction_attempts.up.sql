@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS ai_extraction_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id VARCHAR(21) NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    prompt_version VARCHAR(50) NOT NULL DEFAULT '',
+    request JSONB,
+    raw_response TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    reparsed_from UUID REFERENCES ai_extraction_attempts(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_extraction_attempts_transaction
+    ON ai_extraction_attempts (transaction_id, created_at);
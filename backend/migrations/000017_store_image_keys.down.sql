@@ -0,0 +1,8 @@
+-- Irreversible: the public domain a key was served from is not recorded, and
+-- code from before 000017 would treat the bare keys left behind as full URLs.
+-- Fail instead of silently rolling back to a state that can't load images.
+DO $$
+BEGIN
+    RAISE EXCEPTION '000017_store_image_keys cannot be rolled back: images.file_path holds storage keys, not URLs';
+END
+$$;
@@ -0,0 +1,11 @@
+-- Images used to store their public URL; keep only the storage key so URLs
+-- can be signed per request. Handles R2 public URLs
+-- (https://cdn.example.com/transaction/x.jpg) and local /files URLs
+-- (http://host/files/transaction/x.jpg?sig=...).
+UPDATE images
+SET file_path = regexp_replace(
+    regexp_replace(
+        regexp_replace(file_path, '^https?://[^/]+/', ''),
+        '^files/', ''),
+    '\?.*$', '')
+WHERE file_path ~ '^https?://';
@@ -0,0 +1,2 @@
+ALTER TABLE images DROP COLUMN medium_path;
+ALTER TABLE images DROP COLUMN thumb_path;
@@ -0,0 +1,2 @@
+ALTER TABLE images ADD COLUMN thumb_path TEXT;
+ALTER TABLE images ADD COLUMN medium_path TEXT;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS orphan_candidates (
+    kind VARCHAR(16) NOT NULL,
+    ref TEXT NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, ref)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_images_content_hash;
+ALTER TABLE images DROP COLUMN perceptual_hash;
+ALTER TABLE images DROP COLUMN content_hash;
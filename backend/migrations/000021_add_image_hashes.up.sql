@@ -0,0 +1,3 @@
+ALTER TABLE images ADD COLUMN content_hash VARCHAR(64);
+ALTER TABLE images ADD COLUMN perceptual_hash VARCHAR(16);
+CREATE INDEX idx_images_content_hash ON images (content_hash);
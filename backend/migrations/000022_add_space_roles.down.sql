@@ -0,0 +1,3 @@
+ALTER TABLE space_invites DROP COLUMN role;
+ALTER TABLE space_members ALTER COLUMN role SET DEFAULT 'member';
+UPDATE space_members SET role = 'member' WHERE role <> 'owner';
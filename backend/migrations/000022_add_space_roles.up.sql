@@ -0,0 +1,4 @@
+-- 'member' becomes 'editor'; 'contributor' and 'viewer' are new.
+UPDATE space_members SET role = 'editor' WHERE role = 'member';
+ALTER TABLE space_members ALTER COLUMN role SET DEFAULT 'editor';
+ALTER TABLE space_invites ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'editor';
@@ -0,0 +1 @@
+ALTER TABLE spaces DROP COLUMN pending_owner_id;
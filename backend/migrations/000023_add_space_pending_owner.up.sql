@@ -0,0 +1 @@
+ALTER TABLE spaces ADD COLUMN pending_owner_id UUID REFERENCES users(id) ON DELETE SET NULL;
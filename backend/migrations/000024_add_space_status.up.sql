@@ -0,0 +1 @@
+ALTER TABLE spaces ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS space_join_requests;
+ALTER TABLE space_invites DROP COLUMN requires_approval;
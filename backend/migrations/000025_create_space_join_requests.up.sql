@@ -0,0 +1,18 @@
+ALTER TABLE space_invites ADD COLUMN requires_approval BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS space_join_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    space_id UUID NOT NULL REFERENCES spaces(id) ON DELETE CASCADE,
+    -- Revoking the invite drops the requests made through it.
+    invite_id UUID NOT NULL REFERENCES space_invites(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'approved', 'rejected'
+    decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One open request per user and space.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_space_join_requests_pending
+    ON space_join_requests (space_id, user_id) WHERE status = 'pending';
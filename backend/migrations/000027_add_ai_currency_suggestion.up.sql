@@ -0,0 +1 @@
+ALTER TABLE transactions ADD COLUMN ai_currency_suggestion VARCHAR(3);
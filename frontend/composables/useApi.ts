@@ -1,5 +1,9 @@
 ﻿import { ref } from 'vue'
 
+// In-flight refresh shared by every useApi instance, so parallel requests
+// that all hit an expired access token only rotate the refresh token once.
+let refreshing: Promise<boolean> | null = null
+
 export function useApi() {
     const loading = ref(false)
     const error = ref<string | null>(null)
@@ -16,15 +20,51 @@ export function useApi() {
     const clearAuthAndRedirect = () => {
         if (typeof window !== 'undefined') {
             localStorage.removeItem('token')
+            localStorage.removeItem('refresh_token')
             localStorage.removeItem('user')
             // Redirect to login page
             window.location.href = '/login'
         }
     }
 
+    // refreshAccessToken trades the stored refresh token for a new pair and
+    // reports whether the request should be retried. Concurrent 401s share
+    // one refresh; a tab that loses the race picks up the token the winner
+    // stored instead of failing.
+    const refreshAccessToken = async (): Promise<boolean> => {
+        if (typeof window === 'undefined') return false
+        const refreshToken = localStorage.getItem('refresh_token')
+        if (!refreshToken) return false
+        if (!refreshing) {
+            refreshing = (async () => {
+                const url = apiBase ? `${apiBase}/api/users/refresh` : '/api/users/refresh'
+                try {
+                    const response = await fetch(url, {
+                        method: 'POST',
+                        headers: { 'Content-Type': 'application/json' },
+                        body: JSON.stringify({ refresh_token: refreshToken }),
+                    })
+                    if (!response.ok) {
+                        return localStorage.getItem('refresh_token') !== refreshToken
+                    }
+                    const data = await response.json()
+                    localStorage.setItem('token', data.token)
+                    localStorage.setItem('refresh_token', data.refresh_token)
+                    return true
+                } catch {
+                    return false
+                }
+            })().finally(() => {
+                refreshing = null
+            })
+        }
+        return refreshing
+    }
+
     const request = async <T>(
         endpoint: string,
-        options: RequestInit = {}
+        options: RequestInit = {},
+        retried = false
     ): Promise<T> => {
         loading.value = true
         error.value = null
@@ -51,8 +91,12 @@ export function useApi() {
                 headers,
             })
 
-            // Handle 401 Unauthorized - token is invalid or user doesn't exist
+            // Handle 401 Unauthorized - the access token expired, or the
+            // session was revoked and can no longer be refreshed
             if (response.status === 401) {
+                if (!retried && token && await refreshAccessToken()) {
+                    return await request<T>(endpoint, options, true)
+                }
                 clearAuthAndRedirect()
                 throw new Error('Session expired. Please login again.')
             }
@@ -88,6 +132,27 @@ export function useApi() {
 
     const del = <T>(endpoint: string) => request<T>(endpoint, { method: 'DELETE' })
 
+    // download fetches a binary response, such as a space export, as a Blob.
+    const download = async (endpoint: string, retried = false): Promise<Blob> => {
+        const url = (apiBase && endpoint.startsWith('/')) ? `${apiBase}${endpoint}` : endpoint
+        const token = getToken()
+        const response = await fetch(url, {
+            headers: token ? { Authorization: `Bearer ${token}` } : {},
+        })
+        if (response.status === 401) {
+            if (!retried && token && await refreshAccessToken()) {
+                return download(endpoint, true)
+            }
+            clearAuthAndRedirect()
+            throw new Error('Session expired. Please login again.')
+        }
+        if (!response.ok) {
+            const data = await response.json().catch(() => ({}))
+            throw new Error(data.error || 'Request failed')
+        }
+        return response.blob()
+    }
+
     return {
         loading,
         error,
@@ -97,5 +162,7 @@ export function useApi() {
         patch,
         del,
         upload,
+        download,
+        refreshAccessToken,
     }
 }
@@ -1,8 +1,8 @@
 ﻿import { useApi } from './useApi'
-import type { Image } from '~/types'
+import type { Image, UploadIntent } from '~/types'
 
 export function useImages() {
-    const { get, upload, put, del } = useApi()
+    const { get, post, put, del } = useApi()
 
     const getImages = async (entityId: string, entityType: string) => {
         return await get<Image[]>(`/api/images?entity_id=${entityId}&entity_type=${entityType}`)
@@ -14,10 +14,7 @@ export function useImages() {
         return await get<Image[]>(`/api/images?entity_ids=${ids}&entity_type=${entityType}`)
     }
 
-    const uploadImage = async (file: File, entityId: string, entityType: string) => {
-        let fileToUpload = file
-
-        // Compression Logic
+    const compressImage = async (file: File, entityType: string): Promise<Blob> => {
         try {
             const { default: imageCompression } = await import('browser-image-compression')
 
@@ -31,28 +28,55 @@ export function useImages() {
             if (entityType === 'transaction') {
                 // Receipts: Focus on clarity, resize only if huge, keep high quality
                 options.initialQuality = 1.0
-                // If file is already small (<1.5MB), maybe skip compression to avoid any specific artifacting?
-                // But browser-image-compression with quality 1.0 simply resizes if needed.
-                // Let's stick to the plan: MaxWidth 1280px, No Quality Compression (1.0).
             } else {
                 // Covers/Others: Standard Mobile First
                 options.initialQuality = 0.9
             }
 
-            // Only compress if it's an image
-            if (file.type.startsWith('image/')) {
-                fileToUpload = await imageCompression(file, options)
+            // Only compress formats the browser can decode; HEIC goes up as is
+            // and is transcoded by the server.
+            if (file.type.startsWith('image/') && !/hei[cf]/.test(file.type)) {
+                return await imageCompression(file, options)
             }
         } catch (error) {
             console.warn('Image compression failed, uploading original:', error)
         }
+        return file
+    }
+
+    // Some browsers leave File.type empty for HEIC photos.
+    const contentTypeOf = (file: File, blob: Blob) => {
+        if (blob.type) return blob.type
+        if (/\.hei[cf]$/i.test(file.name)) return 'image/heic'
+        return 'application/octet-stream'
+    }
 
-        const formData = new FormData()
-        // Ensure filename is preserved, even if compressed
-        formData.append('file', fileToUpload, file.name)
-        formData.append('entity_id', entityId)
-        formData.append('entity_type', entityType)
-        return await upload<Image>('/api/images', formData)
+    // Uploads a file straight to storage through a presigned PUT and returns
+    // the pending upload's ID. Without entityId the upload is meant for an
+    // expense about to be created in spaceId; pass the ID as image_upload_ids.
+    const stageImage = async (file: File, target: { entityType: string, entityId?: string, spaceId?: string }) => {
+        const blob = await compressImage(file, target.entityType)
+        const intent = await post<UploadIntent>('/api/images/uploads', {
+            entity_type: target.entityType,
+            entity_id: target.entityId,
+            space_id: target.spaceId,
+            content_type: contentTypeOf(file, blob),
+            size: blob.size
+        })
+        const response = await fetch(intent.upload_url, {
+            method: intent.method,
+            headers: intent.headers,
+            body: blob
+        })
+        if (!response.ok) {
+            throw new Error('圖片上傳失敗')
+        }
+        return intent.id
+    }
+
+    const uploadImage = async (file: File, entityId: string, entityType: string) => {
+        const id = await stageImage(file, { entityType, entityId })
+        return await post<Image>(`/api/images/uploads/${id}/finalize`, {})
     }
 
     const deleteImage = async (id: string) => {
@@ -63,13 +87,18 @@ export function useImages() {
         return await put<{ message: string }>('/api/images/order', { ids })
     }
 
-    const getImageUrl = (path: string) => {
-        return path
+    // Images are private: the API returns a signed URL that expires, so
+    // refetch the image list rather than caching URLs long-term.
+    const getImageUrl = (image: Pick<Image, 'url' | 'thumb_url' | 'medium_url'>, variant: 'original' | 'thumb' | 'medium' = 'original') => {
+        if (variant === 'thumb') return image.thumb_url || image.url || ''
+        if (variant === 'medium') return image.medium_url || image.url || ''
+        return image.url ?? ''
     }
 
     return {
         getImages,
         getImagesBatch,
+        stageImage,
         uploadImage,
         deleteImage,
         reorderImages,
@@ -0,0 +1,81 @@
+import { onUnmounted } from 'vue'
+import { useApi } from '~/composables/useApi'
+
+export interface SpaceEvent {
+  type: 'transaction.created' | 'transaction.updated' | 'transaction.deleted' | 'ai.status'
+  space_id: string
+  transaction_id?: string
+  ai_status?: string
+  ai_error?: string
+}
+
+const RECONNECT_DELAY = 5000
+
+/**
+ * Subscribes to GET /api/spaces/:id/events (Server-Sent Events).
+ * EventSource can't send the Authorization header, so the stream is read
+ * with fetch. Reconnects after a delay until the component unmounts.
+ */
+export function useSpaceEvents(spaceId: string, onEvent: (e: SpaceEvent) => void) {
+  const config = useRuntimeConfig()
+  const apiBase = config.public.apiBase || ''
+  const { refreshAccessToken } = useApi()
+  let controller: AbortController | null = null
+  let reconnectTimer: ReturnType<typeof setTimeout> | null = null
+  let stopped = false
+
+  const dispatch = (block: string) => {
+    let data = ''
+    for (const line of block.split('\n')) {
+      if (line.startsWith('data:')) data += line.slice(5).trim()
+    }
+    if (!data) return
+    try {
+      const event = JSON.parse(data) as SpaceEvent
+      if (event.type) onEvent(event)
+    } catch {
+      // ignore malformed frames
+    }
+  }
+
+  const connect = async () => {
+    if (stopped) return
+    controller = new AbortController()
+    try {
+      const response = await fetch(`${apiBase}/api/spaces/${spaceId}/events`, {
+        headers: { 'Authorization': `Bearer ${localStorage.getItem('token')}` },
+        signal: controller.signal,
+      })
+      // An expired access token is renewed before the next reconnect
+      if (response.status === 401) await refreshAccessToken()
+      if (!response.ok || !response.body) throw new Error(`events ${response.status}`)
+
+      const reader = response.body.pipeThrough(new TextDecoderStream()).getReader()
+      let buffer = ''
+      while (true) {
+        const { value, done } = await reader.read()
+        if (done) break
+        buffer += value
+        let idx
+        while ((idx = buffer.indexOf('\n\n')) >= 0) {
+          dispatch(buffer.slice(0, idx))
+          buffer = buffer.slice(idx + 2)
+        }
+      }
+    } catch (e) {
+      if (stopped) return
+      console.warn('Space event stream disconnected', e)
+    }
+    if (!stopped) reconnectTimer = setTimeout(connect, RECONNECT_DELAY)
+  }
+
+  const stop = () => {
+    stopped = true
+    if (reconnectTimer) clearTimeout(reconnectTimer)
+    controller?.abort()
+  }
+
+  onUnmounted(stop)
+
+  return { connect, stop }
+}
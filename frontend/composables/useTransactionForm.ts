@@ -40,6 +40,9 @@ export function useTransactionForm(spaceId: string) {
   // When true, the worker will fill in items/date for us, so we skip those
   // fields from validation and let the backend pick them up from the LLM.
   const aiExtract = ref(false)
+  // When true, the backend takes the date and location from the attached
+  // photos' EXIF (capture time and GPS) instead of the form.
+  const usePhotoMetadata = ref(false)
   const categories = ref<{ label: string; value: string }[]>([])
   const availableCurrencies = ref<{ label: string; value: string }[]>([])
   const paymentMethods = ref<{ label: string; value: string }[]>([])
@@ -216,19 +219,20 @@ export function useTransactionForm(spaceId: string) {
     if (aiExtract.value) {
       payload.ai_extract = true
     }
+    if (usePhotoMetadata.value) {
+      payload.use_photo_metadata = true
+    }
     return payload
   }
 
-  // Build a multipart/form-data body the backend Create handler accepts:
-  //   data   — JSON string matching buildExpensePayload()
-  //   images — N files attached under the same field name
-  const buildExpenseMultipart = (files: File[]): FormData => {
-    const fd = new FormData()
-    fd.append('data', JSON.stringify(buildExpensePayload()))
-    for (const f of files) {
-      fd.append('images', f, f.name)
+  // Build the create payload for photos already uploaded straight to
+  // storage (useImages().stageImage); the backend attaches them in order.
+  const buildExpenseWithUploads = (uploadIds: string[]) => {
+    const payload = buildExpensePayload()
+    if (uploadIds.length > 0) {
+      payload.image_upload_ids = uploadIds
     }
-    return fd
+    return payload
   }
 
   // Build payment API payload
@@ -284,11 +288,12 @@ export function useTransactionForm(spaceId: string) {
     expenseForm,
     paymentForm,
     aiExtract,
+    usePhotoMetadata,
     fetchSpaceConfig,
     populateFromTransaction,
     populateFromTemplate,
     buildExpensePayload,
-    buildExpenseMultipart,
+    buildExpenseWithUploads,
     buildPaymentPayload,
     validateExpense,
     validatePayment,
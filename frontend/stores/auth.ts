@@ -5,6 +5,8 @@ import type { User } from '~/types'
 
 interface AuthResponse {
   token: string
+  refresh_token: string
+  expires_at: string
   user: User
 }
 
@@ -41,6 +43,7 @@ export const useAuthStore = defineStore('auth', () => {
     user.value = response.user
     if (typeof window !== 'undefined') {
       localStorage.setItem('token', response.token)
+      localStorage.setItem('refresh_token', response.refresh_token)
       localStorage.setItem('user', JSON.stringify(response.user))
     }
     return response
@@ -56,20 +59,35 @@ export const useAuthStore = defineStore('auth', () => {
     user.value = response.user
     if (typeof window !== 'undefined') {
       localStorage.setItem('token', response.token)
+      localStorage.setItem('refresh_token', response.refresh_token)
       localStorage.setItem('user', JSON.stringify(response.user))
     }
     return response
   }
 
-  const logout = () => {
+  const clearAuth = () => {
     token.value = null
     user.value = null
     if (typeof window !== 'undefined') {
       localStorage.removeItem('token')
+      localStorage.removeItem('refresh_token')
       localStorage.removeItem('user')
     }
   }
 
+  // logout ends the session on the server as well, so its refresh token
+  // stops working; local state is cleared even if that request fails.
+  const logout = async () => {
+    if (token.value) {
+      try {
+        await api.post('/api/users/logout', {})
+      } catch {
+        // Already signed out or offline
+      }
+    }
+    clearAuth()
+  }
+
   const fetchUser = async () => {
     try {
       const response = await api.get<User>('/api/users/me')
@@ -79,7 +97,7 @@ export const useAuthStore = defineStore('auth', () => {
       }
       return response
     } catch (e) {
-      logout()
+      clearAuth()
       throw e
     }
   }
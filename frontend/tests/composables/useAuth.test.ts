@@ -48,6 +48,7 @@ describe('useAuth', () => {
   it('login stores token and user', async () => {
     const fakeResponse = {
       token: 'jwt-token-123',
+      refresh_token: 'refresh-123',
       user: { id: '1', username: 'dev', display_name: 'Dev' },
     }
     mockPost.mockResolvedValueOnce(fakeResponse)
@@ -63,6 +64,7 @@ describe('useAuth', () => {
     expect(user.value).toEqual(fakeResponse.user)
     expect(isAuthenticated.value).toBe(true)
     expect(localStorageMock.setItem).toHaveBeenCalledWith('token', 'jwt-token-123')
+    expect(localStorageMock.setItem).toHaveBeenCalledWith('refresh_token', 'refresh-123')
   })
 
   it('register stores token and user', async () => {
@@ -84,7 +86,7 @@ describe('useAuth', () => {
     expect(user.value).toEqual(fakeResponse.user)
   })
 
-  it('logout clears state and localStorage', async () => {
+  it('logout ends the session and clears state and localStorage', async () => {
     // First login
     mockPost.mockResolvedValueOnce({
       token: 'tok',
@@ -94,14 +96,30 @@ describe('useAuth', () => {
     await login('dev', 'dev123')
     expect(isAuthenticated.value).toBe(true)
 
-    logout()
+    mockPost.mockResolvedValueOnce({ message: 'Logged out' })
+    await logout()
+    expect(mockPost).toHaveBeenLastCalledWith('/api/users/logout', {})
     expect(token.value).toBeNull()
     expect(user.value).toBeNull()
     expect(isAuthenticated.value).toBe(false)
     expect(localStorageMock.removeItem).toHaveBeenCalledWith('token')
+    expect(localStorageMock.removeItem).toHaveBeenCalledWith('refresh_token')
     expect(localStorageMock.removeItem).toHaveBeenCalledWith('user')
   })
 
+  it('logout clears local state even if the server call fails', async () => {
+    mockPost.mockResolvedValueOnce({
+      token: 'tok',
+      user: { id: '1', username: 'dev', display_name: 'Dev' },
+    })
+    const { login, logout, isAuthenticated } = useAuth()
+    await login('dev', 'dev123')
+
+    mockPost.mockRejectedValueOnce(new Error('offline'))
+    await logout()
+    expect(isAuthenticated.value).toBe(false)
+  })
+
   it('fetchUser updates user and falls back to logout on error', async () => {
     // Setup authenticated state
     mockPost.mockResolvedValueOnce({
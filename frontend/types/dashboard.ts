@@ -0,0 +1,38 @@
+import type { Balance } from './space'
+import type { AiStatus, TransactionType } from './transaction'
+
+export interface DashboardSpace {
+  id: string
+  name: string
+  type: string
+  base_currency: string
+  month_spend: string
+  // null when no rate from the space's currency is known
+  month_converted: string | null
+  // foreign spend with no rate to base_currency, left out of month_spend
+  month_unconverted?: Record<string, string>
+  member_name: string
+  net: Balance
+  balances: Balance[]
+}
+
+export interface DashboardTxn {
+  id: string
+  space_id: string
+  space_name: string
+  type: TransactionType
+  title: string
+  currency: string
+  total_amount: string
+  ai_status?: AiStatus
+  created_by_name: string
+  created_at: string
+}
+
+export interface Dashboard {
+  currency: string
+  month_total: string
+  spaces: DashboardSpace[]
+  pending_ai: DashboardTxn[]
+  recent: DashboardTxn[]
+}
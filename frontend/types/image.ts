@@ -2,8 +2,35 @@ export interface Image {
   id: string
   entity_id: string
   entity_type: string
+  // Storage key; display the signed, short-lived `url` instead
   file_path: string
+  url?: string
+  // Resized variants; fall back to the original when it was already small
+  thumb_url?: string
+  medium_url?: string
   blur_hash?: string
   sort_order: number
   created_at: string
+  /** Set on upload responses when another transaction has the same photo. */
+  duplicates?: DuplicateImage[]
+}
+
+// A transaction in the same space that already has the uploaded photo;
+// exact means the same file, otherwise a perceptually similar picture.
+export interface DuplicateImage {
+  image_id: string
+  transaction_id: string
+  title: string
+  date: string
+  exact: boolean
+}
+
+// Returned by POST /api/images/uploads: PUT the file to upload_url with
+// these headers, then finalize it or pass the id as image_upload_ids.
+export interface UploadIntent {
+  id: string
+  upload_url: string
+  method: string
+  headers: Record<string, string>
+  expires_at: string
 }
@@ -1,6 +1,7 @@
-export type { User, Announcement } from './user'
-export type { Image } from './image'
-export type { Space, Member, Invite, InviteInfo } from './space'
-export type { Transaction, TransactionType, AiStatus, TransactionExpense, TransactionExpenseItem, TransactionDebt, ExpenseTemplate, ExpenseTemplateData } from './transaction'
+export type { User, Announcement, Session } from './user'
+export type { Image, UploadIntent, DuplicateImage } from './image'
+export type { Space, SpaceRole, SpaceStatus, Member, Invite, InviteInfo, JoinRequest, JoinRequestStatus, Balance, CategorySpend, MemberSummary } from './space'
+export type { Transaction, TransactionType, AiStatus, AiSplitSuggestion, AiExtractionAttempt, TransactionExpense, TransactionExpenseItem, TransactionDebt, ExpenseTemplate, ExpenseTemplateData } from './transaction'
+export type { Dashboard, DashboardSpace, DashboardTxn } from './dashboard'
 export type { ComparisonStore, ComparisonProduct } from './comparison'
 export type { InvMember, InvSettlement, InvMemberTransaction, InvSettlementAllocation, InvFuturesStatement, InvStockStatement, InvStockHolding, InvStockTrade, InvSettlementDetail, AllocationPreview } from './investment'
@@ -1,6 +1,12 @@
 import type { User } from './user'
 import type { Image } from './image'
 
+// owner > editor > contributor (only their own transactions) > viewer
+export type SpaceRole = 'owner' | 'editor' | 'contributor' | 'viewer'
+
+// closed: read-only with every balance settled; archived: also hidden from the space list
+export type SpaceStatus = 'active' | 'closed' | 'archived'
+
 export interface Space {
   id: string
   user_id: string
@@ -16,9 +22,13 @@ export interface Space {
   end_date: string | null
   cover_image: string
   is_pinned: boolean
+  status: SpaceStatus
+  ai_locale?: 'zh-TW' | 'en' | 'ja'
+  ai_instructions?: string
+  pending_owner_id?: string | null
   created_at: string
   updated_at: string
-  my_role?: 'owner' | 'member'
+  my_role?: SpaceRole
   member_count?: number
   user?: User
   members?: Member[]
@@ -30,7 +40,7 @@ export interface Member {
   id: string
   space_id: string
   user_id: string
-  role: 'owner' | 'member'
+  role: SpaceRole
   alias: string
   weight?: number
   created_at: string
@@ -42,12 +52,16 @@ export interface InviteInfo {
   space_name: string
   creator_name: string
   is_one_time: boolean
+  role: SpaceRole
+  requires_approval: boolean
 }
 
 export interface Invite {
   id: string
   space_id: string
   token: string
+  role: SpaceRole
+  requires_approval: boolean
   is_one_time: boolean
   max_uses: number
   use_count: number
@@ -57,3 +71,42 @@ export interface Invite {
   updated_at: string
   creator?: User
 }
+
+export type JoinRequestStatus = 'pending' | 'approved' | 'rejected'
+
+export interface JoinRequest {
+  id: string
+  space_id: string
+  invite_id: string
+  user_id: string
+  status: JoinRequestStatus
+  decided_by?: string
+  decided_at?: string
+  created_at: string
+  updated_at: string
+  user?: User
+}
+
+export interface Balance {
+  name: string
+  base_amount: string
+  foreign_amounts: Record<string, string>
+}
+
+export interface CategorySpend {
+  category: string
+  amount: string
+}
+
+export interface MemberSummary {
+  member_name: string
+  currency: string
+  total_paid: string
+  share: string
+  // Foreign amounts with no known rate, per currency; not in total_paid/share
+  total_paid_unconverted?: Record<string, string>
+  share_unconverted?: Record<string, string>
+  net: Balance
+  balances: Balance[]
+  categories: CategorySpend[]
+}
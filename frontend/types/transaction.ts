@@ -1,8 +1,9 @@
-import type { Image } from './image'
+import type { Image, DuplicateImage } from './image'
 
 export type TransactionType = 'expense' | 'payment'
 
-export type AiStatus = 'pending' | 'processing' | 'completed' | 'failed'
+/** 'skipped': extraction was not run, e.g. the photo duplicates another receipt. */
+export type AiStatus = 'pending' | 'processing' | 'completed' | 'failed' | 'skipped'
 
 export interface Transaction {
   id: string
@@ -20,8 +21,24 @@ export interface Transaction {
   images?: Image[]
   /** AI receipt extraction status; undefined when the row never went through AI. */
   ai_status?: AiStatus
-  /** User-facing failure message when ai_status === 'failed'. */
+  /** User-facing message when ai_status is 'failed' or 'skipped'. */
   ai_error?: string
+  created_by?: string
+  /** Payer / split parsed from quick text entry that still needs confirming. */
+  ai_split_suggestion?: AiSplitSuggestion
+  /** Receipt currency the AI couldn't apply because debts were already entered. */
+  ai_currency_suggestion?: string
+  /** Prompt used for the AI extraction, e.g. "v1/zh-TW". */
+  ai_prompt_version?: string
+  /** Only on the create response: transactions with the same receipt photo. */
+  duplicates?: DuplicateImage[]
+}
+
+export interface AiSplitSuggestion {
+  payer_name: string
+  participants: string[]
+  /** Names that didn't match exactly one split member. */
+  unmatched: string[]
 }
 
 export interface TransactionExpense {
@@ -33,6 +50,7 @@ export interface TransactionExpense {
   handling_fee: string
   payment_method: string
   location_url: string
+  invoice_number: string
   items?: TransactionExpenseItem[]
 }
 
@@ -90,3 +108,18 @@ export interface TransactionDebt {
   settled_amount: string
   is_spot_paid: boolean
 }
+
+/** One stored AI extraction call; admin only. */
+export interface AiExtractionAttempt {
+  id: string
+  transaction_id: string
+  source: 'image' | 'text'
+  status: 'completed' | 'retry' | 'failed'
+  prompt_version: string
+  request: Record<string, unknown> | null
+  raw_response: string
+  error: string
+  duration_ms: number
+  reparsed_from?: string
+  created_at: string
+}
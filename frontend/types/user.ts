@@ -19,3 +19,14 @@ export interface Announcement {
   created_at: string
   updated_at: string
 }
+
+export interface Session {
+  id: string
+  user_id: string
+  user_agent: string
+  ip: string
+  last_seen_at: string
+  expires_at: string
+  created_at: string
+  current: boolean
+}